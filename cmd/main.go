@@ -1,11 +1,14 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -14,6 +17,7 @@ import (
 	"github.com/anurag-bit/goetl/pkg/load"
 	"github.com/anurag-bit/goetl/pkg/parser"
 	"github.com/anurag-bit/goetl/pkg/processor"
+	"github.com/anurag-bit/goetl/utils"
 	"github.com/gin-gonic/gin"
 )
 
@@ -41,12 +45,105 @@ type ETLRequest struct {
 	OutputPath  string `json:"output"`
 	ChunkSize   int    `json:"chunksize"`
 	Overlap     int    `json:"overlap"`
+	Chunker     string `json:"chunker"`
+	Tail        string `json:"tail"`
 	Format      string `json:"format"`
 	DBURL       string `json:"dburl"`
 	Instruction string `json:"instruction"`
 	Parse       bool   `json:"parse"`
 	Semantic    bool   `json:"semantic"`
 	SemanticOut string `json:"semanticout"`
+	// Secrets is the secret policy (off, redact, drop, fail); empty means redact.
+	Secrets       string `json:"secrets"`
+	SecretsReport string `json:"secretsreport"`
+	// Dedup is the dedup mode (off, exact, near); DedupThreshold defaults to 0.8.
+	Dedup          string  `json:"dedup"`
+	DedupThreshold float64 `json:"dedupthreshold"`
+	DedupIndex     string  `json:"dedupindex"`
+	// Quality enables the chunk quality filter; QualityThresholds overrides
+	// individual defaults and rejected chunks are written to Rejected.
+	Quality           bool            `json:"quality"`
+	QualityThresholds json.RawMessage `json:"qualitythresholds"`
+	Rejected          string          `json:"rejected"`
+	// Languages is a comma-separated list of ISO codes to keep; LangRoute
+	// writes each language to its own output file.
+	Languages string `json:"languages"`
+	LangRoute bool   `json:"langroute"`
+	// Split ("0.8,0.1,0.1") writes train, val and test files, assigning
+	// whole source documents by a hash seeded with Seed; Stratify (none,
+	// dir, language) keeps the ratios within each directory or language.
+	Split    string `json:"split"`
+	Stratify string `json:"stratify"`
+	// ShardRecords and ShardSize ("256MB") roll file output over into
+	// numbered shards listed in data.manifest.json; Compress (none, gzip,
+	// zstd) compresses jsonl, csv and tsv output.
+	ShardRecords int    `json:"shardrecords"`
+	ShardSize    string `json:"shardsize"`
+	Compress     string `json:"compress"`
+	// Tokenizer selects the unit of ChunkSize and Overlap: "words" (default)
+	// or "bpe"/"bytebpe" with the merges file in TokenizerFile; "unigram", "hf" and
+	// "tiktoken" read a tokenizer.json or .tiktoken file from TokenizerFile.
+	Tokenizer     string `json:"tokenizer"`
+	TokenizerFile string `json:"tokenizerfile"`
+	// Embedder, EmbedURL, EmbedModel, EmbedBatch and Breakpoint configure
+	// the semantic chunker; EmbedURL must be one of the server's
+	// GOETL_EMBED_URLS (empty picks the first), EmbedBatch is the number of
+	// texts per embedding request and Breakpoint the distance percentile,
+	// which defaults to 95.
+	Embedder   string  `json:"embedder"`
+	EmbedURL   string  `json:"embedurl"`
+	EmbedModel string  `json:"embedmodel"`
+	EmbedBatch int     `json:"embedbatch"`
+	Breakpoint float64 `json:"breakpoint"`
+	// OverlapPercent, if set, replaces Overlap with a percentage of ChunkSize;
+	// a last chunk below MinChunkSize is handled per Tail (keep, drop, merge).
+	OverlapPercent float64 `json:"overlappercent"`
+	MinChunkSize   int     `json:"minchunksize"`
+	// TableFormat serializes detected tables as "markdown" (default) or "csv".
+	TableFormat string `json:"tableformat"`
+	// BinMode ("chunk" or "document") and EOS configure the "bin" format,
+	// which writes token IDs and needs a Tokenizer.
+	BinMode string `json:"binmode"`
+	EOS     string `json:"eos"`
+	// Pack, if positive, packs chunks into sequences of that many tokens for
+	// jsonl or bin output; PackBoundaries adds per-document segment IDs.
+	Pack           int  `json:"pack"`
+	PackBoundaries bool `json:"packboundaries"`
+	// ParquetCodec (snappy, zstd, none) and RowGroupSize (rows, default
+	// 65536) configure the "parquet" format.
+	ParquetCodec string `json:"parquetcodec"`
+	RowGroupSize int    `json:"rowgroup"`
+	// CSVColumns (e.g. "id,source,page,text,tokens,hash"), CSVDelimiter
+	// ("," by default, "tab" for tabs) and CSVBOM configure the "csv" format;
+	// "tsv" is csv with tabs.
+	CSVColumns   string `json:"csvcolumns"`
+	CSVDelimiter string `json:"csvdelimiter"`
+	CSVBOM       bool   `json:"csvbom"`
+	// Schema is the jsonl sample layout (alpaca, sharegpt, chat, text,
+	// completion); Fields renames its keys and roles, e.g. {"output": "response"}.
+	Schema string            `json:"schema"`
+	Fields map[string]string `json:"fields"`
+	// Instruction is a text/template over the chunk (see
+	// formatter.InstructionData); InstructionFile holds several templates
+	// separated by "---" lines, one picked per sample with Seed.
+	InstructionFile string `json:"instructionfile"`
+	Seed            int64  `json:"seed"`
+	// Generate (off, qa, summary, dialogue) has the chat completions
+	// endpoint GenURL write the samples. GenURL must be one of the server's
+	// GOETL_GENERATE_URLS; empty picks the first.
+	// GenPairs and GenConcurrency default to 3 and 4; GenPrompt is a
+	// prompt template file and GenCache a directory for cached answers.
+	Generate       string  `json:"generate"`
+	GenURL         string  `json:"genurl"`
+	GenModel       string  `json:"genmodel"`
+	GenPrompt      string  `json:"genprompt"`
+	GenSystem      string  `json:"gensystem"`
+	GenCache       string  `json:"gencache"`
+	GenPairs       int     `json:"genpairs"`
+	GenConcurrency int     `json:"genconcurrency"`
+	GenRetries     int     `json:"genretries"`
+	GenTemperature float64 `json:"gentemperature"`
+	GenContext     bool    `json:"gencontext"`
 }
 
 // ETLResponse defines the JSON structure for API responses.
@@ -56,6 +153,26 @@ type ETLResponse struct {
 	OutputPath string `json:"output,omitempty"`
 	Elapsed    string `json:"elapsed,omitempty"`
 	Error      string `json:"error,omitempty"`
+	// Field names the offending option when the request is rejected as invalid.
+	Field string `json:"field,omitempty"`
+
+	Chunks         int                       `json:"chunks,omitempty"`
+	Duplicates     int                       `json:"duplicates,omitempty"`
+	Rejected       int                       `json:"rejected,omitempty"`
+	Languages      map[string]int            `json:"languages,omitempty"`
+	SecretFindings []processor.SecretFinding `json:"secret_findings,omitempty"`
+	// CodeFallbacks lists the Go sources chunked as text because they do not parse.
+	CodeFallbacks []string `json:"code_fallbacks,omitempty"`
+	// PackedSequences and PaddingWaste (percent) report sequence packing.
+	PackedSequences int     `json:"packed_sequences,omitempty"`
+	PaddingWaste    float64 `json:"padding_waste,omitempty"`
+	// GeneratedSamples, GenerationFailed (chunks dropped) and
+	// GenerationCacheHits report sample generation.
+	GeneratedSamples    int `json:"generated_samples,omitempty"`
+	GenerationFailed    int `json:"generation_failed,omitempty"`
+	GenerationCacheHits int `json:"generation_cache_hits,omitempty"`
+	// Splits counts the chunks and documents of each split.
+	Splits map[string]processor.SplitStats `json:"splits,omitempty"`
 }
 
 // etlHandler handles ETL jobs via API.
@@ -76,39 +193,223 @@ func etlHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, ETLResponse{Status: "error", Message: "Input path does not exist"})
 		return
 	}
+	secretPolicy, err := processor.ParseSecretPolicy(req.Secrets)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ETLResponse{Status: "error", Message: "Invalid secret policy", Error: err.Error()})
+		return
+	}
+	secretReport := processor.NewSecretReport(secretPolicy)
 
 	// Semantic codebase analysis mode
 	if req.Semantic {
 		fm := load.NewFileManager(req.InputPath)
+		fm.SecretScanner = processor.NewSecretScanner()
+		fm.SecretPolicy = secretPolicy
+		fm.SecretReport = secretReport
 		err := fm.ProcessCodebase(req.SemanticOut)
+		if err == nil {
+			err = saveSecretReport(req.SecretsReport, secretReport)
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, ETLResponse{Status: "error", Message: "Semantic analysis error", Error: err.Error()})
+			c.JSON(secretErrorStatus(err), ETLResponse{Status: "error", Message: "Semantic analysis error", Error: err.Error(), SecretFindings: secretReport.Findings})
 			return
 		}
 		c.JSON(http.StatusOK, ETLResponse{
-			Status:     "success",
-			Message:    "Semantic graph saved",
-			OutputPath: req.SemanticOut,
-			Elapsed:    time.Since(startTime).Truncate(time.Millisecond).String(),
+			Status:         "success",
+			Message:        "Semantic graph saved",
+			OutputPath:     req.SemanticOut,
+			Elapsed:        time.Since(startTime).Truncate(time.Millisecond).String(),
+			SecretFindings: secretReport.Findings,
 		})
 		return
 	}
 
+	inputs, err := collectInputs(req.InputPath)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ETLResponse{Status: "error", Message: "Invalid input path", Error: err.Error()})
+		return
+	}
+	dedupMode, err := processor.ParseDedupMode(req.Dedup)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ETLResponse{Status: "error", Message: "Invalid dedup mode", Error: err.Error()})
+		return
+	}
+	if req.DedupThreshold == 0 {
+		req.DedupThreshold = 0.8
+	}
+	dedup, err := processor.NewDeduplicator(dedupMode, req.DedupThreshold)
+	if err == nil && req.DedupIndex != "" {
+		err = dedup.LoadIndex(req.DedupIndex)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ETLResponse{Status: "error", Message: "Dedup setup error", Error: err.Error()})
+		return
+	}
+	counter, err := processor.LoadTokenCounter(req.Tokenizer, req.TokenizerFile)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ETLResponse{Status: "error", Message: "Invalid tokenizer", Error: err.Error()})
+		return
+	}
+	if req.Breakpoint == 0 {
+		req.Breakpoint = 95
+	}
+	var semantic *processor.SemanticChunker
+	embedURL := req.EmbedURL
+	if strings.EqualFold(strings.TrimSpace(req.Embedder), "http") {
+		embedURL, err = serverEndpoint(embedURLsEnv, req.EmbedURL)
+	}
+	if err == nil {
+		semantic, err = newSemanticChunker(req.Embedder, embedURL, req.EmbedModel, req.EmbedBatch, req.Breakpoint, req.ChunkSize, counter)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ETLResponse{Status: "error", Message: "Invalid embedder", Error: err.Error()})
+		return
+	}
+	chunking := processor.ChunkOptions{
+		Chunker:        req.Chunker,
+		Size:           req.ChunkSize,
+		Overlap:        req.Overlap,
+		OverlapPercent: req.OverlapPercent,
+		MinSize:        req.MinChunkSize,
+		Tail:           processor.TailPolicy(req.Tail),
+		Counter:        counter,
+		Semantic:       semantic,
+	}
+	if err := chunking.Validate(); err != nil {
+		resp := ETLResponse{Status: "error", Message: "Invalid chunk options", Error: err.Error()}
+		var optErr *processor.ChunkOptionsError
+		if errors.As(err, &optErr) {
+			resp.Field = optErr.Field
+		}
+		c.JSON(http.StatusBadRequest, resp)
+		return
+	}
+	tableFormat, err := extractor.ParseTableFormat(req.TableFormat)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ETLResponse{Status: "error", Message: "Invalid table format", Error: err.Error()})
+		return
+	}
+	p := &pipeline{
+		Chunking:     chunking,
+		TableFormat:  tableFormat,
+		SecretPolicy: secretPolicy,
+		SecretReport: secretReport,
+		Dedup:        dedup,
+	}
+	p.Languages, err = parseLanguages(req.Languages)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ETLResponse{Status: "error", Message: "Invalid languages", Error: err.Error()})
+		return
+	}
+	p.Samples, err = newSampleFormat(req.Schema, req.Fields)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ETLResponse{Status: "error", Message: "Invalid sample schema", Error: err.Error()})
+		return
+	}
+	p.Instructions, err = newInstructions(req.Instruction, req.InstructionFile, req.Seed)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ETLResponse{Status: "error", Message: "Invalid instruction template", Error: err.Error()})
+		return
+	}
+	if req.GenPairs == 0 {
+		req.GenPairs = 3
+	}
+	if req.GenConcurrency == 0 {
+		req.GenConcurrency = 4
+	}
+	genURL := ""
+	if task, _ := processor.ParseGenerationTask(req.Generate); task != "" {
+		genURL, err = serverEndpoint(generateURLsEnv, req.GenURL)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ETLResponse{Status: "error", Message: "Invalid generation options", Error: err.Error()})
+			return
+		}
+	}
+	generator, err := newGenerator(generationOptions{
+		Task:        req.Generate,
+		URL:         genURL,
+		Model:       req.GenModel,
+		PromptFile:  req.GenPrompt,
+		System:      req.GenSystem,
+		CacheDir:    req.GenCache,
+		Pairs:       req.GenPairs,
+		Concurrency: req.GenConcurrency,
+		Retries:     req.GenRetries,
+		Temperature: req.GenTemperature,
+		WithContext: req.GenContext,
+		APIKey:      os.Getenv("OPENAI_API_KEY"),
+	}, req.Format, req.Pack > 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ETLResponse{Status: "error", Message: "Invalid generation options", Error: err.Error()})
+		return
+	}
+	p.Generator = generator
+	p.Parquet, err = newParquetOptions(req.ParquetCodec, req.RowGroupSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ETLResponse{Status: "error", Message: "Invalid parquet options", Error: err.Error()})
+		return
+	}
+	p.CSV, err = newCSVOptions(req.CSVColumns, req.CSVDelimiter, req.CSVBOM)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ETLResponse{Status: "error", Message: "Invalid csv options", Error: err.Error()})
+		return
+	}
+	p.Split, err = newSplitOptions(req.Split, req.Stratify, req.Seed)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ETLResponse{Status: "error", Message: "Invalid split options", Error: err.Error()})
+		return
+	}
+	p.Shards, err = newShardOptions(req.Format, req.ShardRecords, req.ShardSize, req.Compress, req.Pack > 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ETLResponse{Status: "error", Message: "Invalid shard options", Error: err.Error()})
+		return
+	}
+	if strings.EqualFold(req.Format, "bin") {
+		p.Binary, err = newBinaryOptions(counter, req.Tokenizer+":"+req.TokenizerFile, req.BinMode, req.EOS)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ETLResponse{Status: "error", Message: "Invalid binary output options", Error: err.Error()})
+			return
+		}
+	}
+	if req.Pack > 0 {
+		p.Packing, err = newPackOptions(req.Format, counter, req.Pack, req.EOS, req.PackBoundaries)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ETLResponse{Status: "error", Message: "Invalid packing options", Error: err.Error()})
+			return
+		}
+	}
+	if req.Quality {
+		thresholds := processor.DefaultQualityThresholds()
+		if len(req.QualityThresholds) > 0 {
+			thresholds, err = processor.ParseQualityThresholds(req.QualityThresholds)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, ETLResponse{Status: "error", Message: "Invalid quality thresholds", Error: err.Error()})
+				return
+			}
+		}
+		p.Quality = &thresholds
+	}
+
 	// Extraction
-	ext := strings.ToLower(filepath.Ext(req.InputPath))
-	var rawText string
-	var err error
-	switch ext {
-	case ".txt":
-		rawText, err = extractor.ExtractTextFile(req.InputPath)
-	case ".pdf":
-		rawText, err = extractor.ExtractPDFText(req.InputPath)
-	default:
+	docs, err := p.extract(inputs)
+	if err == nil {
+		err = saveSecretReport(req.SecretsReport, secretReport)
+	}
+	if errors.Is(err, extractor.ErrUnsupportedFileType) {
 		c.JSON(http.StatusBadRequest, ETLResponse{Status: "error", Message: "Unsupported file type"})
 		return
 	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ETLResponse{Status: "error", Message: "Extraction error", Error: err.Error()})
+		c.JSON(secretErrorStatus(err), ETLResponse{Status: "error", Message: "Extraction error", Error: err.Error(), SecretFindings: secretReport.Findings})
+		return
+	}
+	if len(docs) == 0 {
+		c.JSON(http.StatusOK, ETLResponse{
+			Status:         "success",
+			Message:        "Input dropped: secrets detected",
+			Elapsed:        time.Since(startTime).Truncate(time.Millisecond).String(),
+			SecretFindings: secretReport.Findings,
+		})
 		return
 	}
 
@@ -117,28 +418,35 @@ func etlHandler(c *gin.Context) {
 		// No-op for API, but could return stats if needed
 	}
 
-	// Transform (Clean & Chunk)
-	cleanText := processor.CleanText(rawText)
-	chunks := processor.ChunkTextBySearchableTokens(cleanText, req.ChunkSize, req.Overlap)
+	// Transform (Clean, Chunk, Filter & Deduplicate)
+	chunks, err := p.transform(docs)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ETLResponse{Status: "error", Message: "Chunking error", Error: err.Error()})
+		return
+	}
+	if req.Quality && req.Rejected != "" {
+		if err := processor.WriteRejectedChunks(req.Rejected, p.Rejected); err != nil {
+			c.JSON(http.StatusInternalServerError, ETLResponse{Status: "error", Message: "Rejected file error", Error: err.Error()})
+			return
+		}
+	}
+	if req.DedupIndex != "" {
+		if err := dedup.SaveIndex(req.DedupIndex); err != nil {
+			c.JSON(http.StatusInternalServerError, ETLResponse{Status: "error", Message: "Dedup index error", Error: err.Error()})
+			return
+		}
+	}
+
+	chunks, err = p.generate(chunks)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, ETLResponse{Status: "error", Message: "Generation error", Error: err.Error()})
+		return
+	}
 
 	// Load/Format
-	switch strings.ToLower(req.Format) {
-	case "jsonl":
-		err = formatter.FormatToJSONL(chunks, req.OutputPath, req.Instruction)
-	case "csv":
-		err = load.LoadToCSV(chunks, req.OutputPath)
-	case "postgres":
-		err = load.LoadToPostgres(chunks, req.DBURL)
-	case "mysql":
-		err = load.LoadToMySQL(chunks, req.DBURL)
-	case "sqlite":
-		err = load.LoadToSQLite(chunks, req.OutputPath)
-	case "mongodb":
-		err = load.LoadToMongoDB(chunks, req.DBURL)
-	case "redis":
-		err = load.LoadToRedis(chunks, req.DBURL)
-	default:
-		c.JSON(http.StatusBadRequest, ETLResponse{Status: "error", Message: "Unsupported output format"})
+	outputs, err := p.writeOutput(req.Format, chunks, req.OutputPath, req.DBURL, req.LangRoute)
+	if errors.Is(err, errUnsupportedFormat) {
+		c.JSON(http.StatusBadRequest, ETLResponse{Status: "error", Message: "Unsupported output format", Error: err.Error()})
 		return
 	}
 	if err != nil {
@@ -147,13 +455,79 @@ func etlHandler(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, ETLResponse{
-		Status:     "success",
-		Message:    "ETL completed",
-		OutputPath: req.OutputPath,
-		Elapsed:    time.Since(startTime).Truncate(time.Millisecond).String(),
+		Status:         "success",
+		Message:        "ETL completed",
+		OutputPath:     strings.Join(outputs, ","),
+		Elapsed:        time.Since(startTime).Truncate(time.Millisecond).String(),
+		Chunks:         len(chunks),
+		Duplicates:     dedup.ExactRemoved + dedup.NearRemoved,
+		Rejected:       len(p.Rejected),
+		Languages:      languageCounts(chunks),
+		SecretFindings: secretReport.Findings,
+		CodeFallbacks:  p.CodeFallbacks,
+
+		PackedSequences: p.PackStats.Sequences,
+		PaddingWaste:    p.PackStats.PaddingWaste(),
+
+		GeneratedSamples:    p.GenStats.Samples,
+		GenerationFailed:    p.GenStats.Failed,
+		GenerationCacheHits: p.GenStats.CacheHits,
+
+		Splits: p.SplitStats,
 	})
 }
 
+// newSemanticChunker configures the semantic chunker. The API key for an
+// http embedder is read from OPENAI_API_KEY.
+func newSemanticChunker(kind, url, model string, batchSize int, breakpoint float64, maxTokens int, counter processor.TokenCounter) (*processor.SemanticChunker, error) {
+	if breakpoint < 0 || breakpoint > 100 {
+		return nil, fmt.Errorf("breakpoint percentile must be between 0 and 100, got %v", breakpoint)
+	}
+	embedder, err := processor.LoadEmbedder(kind, url, model, os.Getenv("OPENAI_API_KEY"), batchSize)
+	if err != nil {
+		return nil, err
+	}
+	s := processor.NewSemanticChunker(embedder, maxTokens, counter)
+	s.Percentile = breakpoint
+	return s, nil
+}
+
+// languageCounts returns the number of chunks per detected language.
+func languageCounts(chunks []processor.Chunk) map[string]int {
+	counts := make(map[string]int)
+	for _, c := range chunks {
+		counts[c.Language]++
+	}
+	return counts
+}
+
+// formatLanguageCounts renders language counts as "de=3, en=12".
+func formatLanguageCounts(counts map[string]int) string {
+	parts := make([]string, 0, len(counts))
+	for lang, n := range counts {
+		parts = append(parts, fmt.Sprintf("%s=%d", lang, n))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+// secretErrorStatus maps a secret policy failure to 422 and anything else to 500.
+func secretErrorStatus(err error) int {
+	var secretErr *processor.SecretError
+	if errors.As(err, &secretErr) {
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+// saveSecretReport writes the secret findings report if a path was given.
+func saveSecretReport(path string, report *processor.SecretReport) error {
+	if path == "" {
+		return nil
+	}
+	return processor.WriteSecretReport(path, report)
+}
+
 func main() {
 	// If run with no CLI flags, start API server
 	if len(os.Args) == 1 {
@@ -170,27 +544,82 @@ func main() {
 		r.Run(":8081")
 		return
 	}
+	if os.Args[1] == "tokenizer" {
+		os.Exit(runTokenizer(os.Args[2:]))
+	}
 
 	startTime := time.Now()
 
 	// CLI flags
-	inputPath := flag.String("input", "", "Path to input file (.pdf or .txt) or directory")
+	inputPath := flag.String("input", "", "Path to input file (.pdf, .docx, .html, .txt, ...) or directory")
 	outputPath := flag.String("output", "output/data.jsonl", "Output file path (JSONL/CSV/DB)")
 	chunkSize := flag.Int("chunksize", 200, "Chunk size (tokens)")
-	overlap := flag.Int("overlap", 20, "Token overlap between chunks")
-	format := flag.String("format", "jsonl", "Output format: jsonl, csv, postgres, mysql, sqlite, mongodb, redis")
+	overlap := flag.String("overlap", "20", "Token overlap between chunks, as a count or a percentage of -chunksize (e.g. 15%)")
+	minChunk := flag.Int("minchunk", 0, "Minimum size of a document's last chunk; smaller ones are handled per -tail")
+	tailFlag := flag.String("tail", "keep", "Small trailing chunks: keep, drop, merge (into the previous chunk)")
+	embedderFlag := flag.String("embedder", "hashing", "Sentence embedder for -chunker semantic: hashing (offline), http")
+	embedURL := flag.String("embedurl", "", "OpenAI-compatible embeddings endpoint for -embedder http")
+	embedModel := flag.String("embedmodel", "", "Embedding model name for -embedder http")
+	embedBatch := flag.Int("embedbatch", processor.DefaultEmbedBatchSize, "Texts per request for -embedder http")
+	breakpoint := flag.Float64("breakpoint", 95, "Distance percentile (0-100) at which the semantic chunker splits")
+	tokenizerFlag := flag.String("tokenizer", "words", "Unit for -chunksize/-overlap: words, bpe, bytebpe (byte-level), unigram, hf (tokenizer.json), tiktoken")
+	tokenizerFile := flag.String("tokenizerfile", "", "BPE merges file for -tokenizer bpe or bytebpe, saved .json tokenizer for unigram, tokenizer.json for hf, .tiktoken file for tiktoken")
+	chunkerFlag := flag.String("chunker", "token", "Chunker: fixed (characters), token (words), paragraph, recursive (paragraph/sentence aware), semantic (topic shifts), code (Go declarations)")
+	tableFormatFlag := flag.String("tableformat", "markdown", "Serialization of tables found in PDF, DOCX and HTML inputs: markdown, csv")
+	format := flag.String("format", "jsonl", "Output format: jsonl, csv, tsv, parquet, bin (token IDs, needs -tokenizer), postgres, mysql, sqlite, mongodb, redis")
+	csvColumns := flag.String("csvcolumns", formatter.DefaultCSVColumns, "Columns for -format csv/tsv: id, source, page, section, language, text, tokens, hash")
+	csvDelimiter := flag.String("csvdelimiter", ",", "Field delimiter for -format csv (\\t or tab for tabs)")
+	csvBOM := flag.Bool("csvbom", false, "Start csv/tsv output with a UTF-8 byte order mark for Excel")
+	parquetCodec := flag.String("parquetcodec", "snappy", "Page compression for -format parquet: snappy, zstd, none")
+	rowGroupSize := flag.Int("rowgroup", formatter.DefaultRowGroupSize, "Rows per row group for -format parquet; bounds memory while writing")
+	binMode := flag.String("binmode", "chunk", "Sequences for -format bin: chunk (EOS after every chunk), document (chunks of a file back to back, EOS after the last; use -overlap 0)")
+	eosToken := flag.String("eos", utils.EndOfTextToken, "Separator token for -format bin")
+	packLength := flag.Int("pack", 0, "Pack chunks into sequences of exactly this many tokens for -format jsonl or bin, separated by -eos (0: off)")
+	packBoundaries := flag.Bool("packboundaries", false, "Record per-document segment IDs with packed sequences")
 	dbURL := flag.String("dburl", "", "Database URL (for DB targets)")
-	instruction := flag.String("instruction", "Please summarize the following text chunk #%d.", "Instruction template for JSONL")
+	instruction := flag.String("instruction", formatter.DefaultInstruction, "Instruction template for JSONL (text/template over .Index, .Text, .Source, .File, .Page, .Section, ...)")
+	instructionFile := flag.String("instructionfile", "", "File of instruction templates separated by --- lines, one picked at random per sample")
+	seed := flag.Int64("seed", 0, "Random seed for sampling instruction templates and assigning -split")
+	generateFlag := flag.String("generate", "off", "Have a chat model write the JSONL samples of every chunk: off, qa (question/answer pairs), summary, dialogue (multi-turn)")
+	genURL := flag.String("genurl", "http://localhost:8080/v1/chat/completions", "OpenAI-compatible chat completions endpoint for -generate (API key from OPENAI_API_KEY)")
+	genModel := flag.String("genmodel", "", "Model name for -generate")
+	genPrompt := flag.String("genprompt", "", "File with the user prompt template for -generate (text/template over .Text, .Source, .Page, .Section, .N)")
+	genSystem := flag.String("gensystem", "", "System prompt for -generate (default: one per task)")
+	genCache := flag.String("gencache", "", "Directory caching model answers for -generate")
+	genPairs := flag.Int("genpairs", 3, "Question/answer pairs or dialogue exchanges per chunk")
+	genConcurrency := flag.Int("genconcurrency", 4, "Concurrent requests for -generate")
+	genRetries := flag.Int("genretries", 3, "Retries of a failed request or unusable answer, with exponential backoff")
+	genTemperature := flag.Float64("gentemperature", 0.7, "Sampling temperature for -generate")
+	genContext := flag.Bool("gencontext", false, "Include the chunk as input of generated qa and dialogue samples")
+	schemaFlag := flag.String("schema", "alpaca", "Layout of JSONL samples: alpaca, sharegpt, chat (OpenAI messages), text (pretraining), completion (prompt/completion)")
+	fieldsFlag := flag.String("fields", "", "Comma-separated renames of JSONL schema fields and roles, e.g. output=response,meta= (empty name drops the field)")
 	parseFlag := flag.Bool("parse", false, "Parse and analyze extracted text")
 	semanticFlag := flag.Bool("semantic", false, "Analyze codebase and output semantic graph (for directories)")
 	semanticOut := flag.String("semanticout", "output/semantic_graph.json", "Output path for semantic graph JSON")
+	secretsFlag := flag.String("secrets", "redact", "Secret handling for extracted documents: off, redact, drop, fail")
+	secretsReport := flag.String("secretsreport", "", "Write a JSON report of secret findings to this path")
+	dedupFlag := flag.String("dedup", "off", "Chunk deduplication across all input files: off, exact, near")
+	dedupThreshold := flag.Float64("dedupthreshold", 0.8, "Jaccard similarity threshold for near-duplicate removal")
+	dedupIndex := flag.String("dedupindex", "", "Dedup index file to load before and save after the run")
+	qualityFlag := flag.Bool("quality", false, "Filter chunks with Gopher/C4-style quality heuristics")
+	qualityConfig := flag.String("qualityconfig", "", "JSON file overriding quality filter thresholds")
+	rejectedPath := flag.String("rejected", "output/rejected.jsonl", "Output path for chunks rejected by the quality filter")
+	langsFlag := flag.String("langs", "", "Comma-separated ISO language codes to keep, und for unclassified text (default: all)")
+	langRoute := flag.Bool("langroute", false, "Write each detected language to its own output file")
+	splitFlag := flag.String("split", "", "Train,val[,test] ratios, e.g. 0.8,0.1,0.1; writes data.train.jsonl, data.val.jsonl, data.test.jsonl with whole documents per split")
+	shardRecords := flag.Int("shardrecords", 0, "Roll file output over into a new shard every N chunks (data-00000-of-00042.jsonl) with a data.manifest.json (default: off)")
+	shardSize := flag.String("shardsize", "", "Roll file output over into a new shard at about this much text, e.g. 256MB (default: off)")
+	compressFlag := flag.String("compress", "none", "Compress jsonl, csv and tsv output: none, gzip, zstd")
+	stratifyFlag := flag.String("stratify", "none", "Keep -split ratios within each stratum: none, dir (source directory), language")
 	showVersion := flag.Bool("version", false, "Show version and exit")
 	flag.Usage = func() {
 		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
 		flag.PrintDefaults()
 		fmt.Println("\nExamples:")
 		fmt.Println("  goetl -input samples/demo.pdf -output output/data.jsonl -format jsonl")
+		fmt.Println("  goetl -input docs/ -dedup near -dedupindex output/dedup.idx")
 		fmt.Println("  goetl -input mydir/ -semantic -semanticout output/graph.json")
+		fmt.Println("  goetl tokenizer train -input corpus/ -model bytebpe -vocabsize 8000")
 	}
 	flag.Parse()
 
@@ -215,17 +644,30 @@ func main() {
 		fmt.Printf("❌ Input path does not exist: %s\n", *inputPath)
 		os.Exit(2)
 	}
+	secretPolicy, err := processor.ParseSecretPolicy(*secretsFlag)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(2)
+	}
+	secretReport := processor.NewSecretReport(secretPolicy)
 
 	// Semantic codebase analysis mode
 	if *semanticFlag {
 		fmt.Println("🔎 [1/1] Semantic codebase analysis enabled.")
 		fm := load.NewFileManager(*inputPath)
+		fm.SecretScanner = processor.NewSecretScanner()
+		fm.SecretPolicy = secretPolicy
+		fm.SecretReport = secretReport
 		fmt.Printf("    Analyzing directory: %s\n", *inputPath)
 		err := fm.ProcessCodebase(*semanticOut)
+		if reportErr := saveSecretReport(*secretsReport, secretReport); reportErr != nil {
+			fmt.Printf("❌ %v\n", reportErr)
+		}
 		if err != nil {
 			fmt.Printf("❌ Semantic analysis error: %v\n", err)
 			os.Exit(3)
 		}
+		fmt.Printf("    Secret findings: %d (policy: %s, dropped files: %d)\n", len(secretReport.Findings), secretPolicy, len(secretReport.Dropped))
 		fmt.Printf("✅ Semantic graph saved to: %s\n", *semanticOut)
 		fmt.Printf("⏱️  Elapsed: %s\n", time.Since(startTime).Truncate(time.Millisecond))
 		return
@@ -234,41 +676,191 @@ func main() {
 	fmt.Printf("Input: %s\nOutput: %s\nFormat: %s\n", *inputPath, *outputPath, *format)
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 
+	inputs, err := collectInputs(*inputPath)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(2)
+	}
+	dedupMode, err := processor.ParseDedupMode(*dedupFlag)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(2)
+	}
+	dedup, err := processor.NewDeduplicator(dedupMode, *dedupThreshold)
+	if err == nil && *dedupIndex != "" {
+		err = dedup.LoadIndex(*dedupIndex)
+	}
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(2)
+	}
+	counter, err := processor.LoadTokenCounter(*tokenizerFlag, *tokenizerFile)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(2)
+	}
+	semantic, err := newSemanticChunker(*embedderFlag, *embedURL, *embedModel, *embedBatch, *breakpoint, *chunkSize, counter)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(2)
+	}
+	overlapTokens, overlapPercent, err := processor.ParseOverlap(*overlap)
+	chunking := processor.ChunkOptions{
+		Chunker:        *chunkerFlag,
+		Size:           *chunkSize,
+		Overlap:        overlapTokens,
+		OverlapPercent: overlapPercent,
+		MinSize:        *minChunk,
+		Tail:           processor.TailPolicy(*tailFlag),
+		Counter:        counter,
+		Semantic:       semantic,
+	}
+	if err == nil {
+		err = chunking.Validate()
+	}
+	if err != nil {
+		fmt.Printf("❌ Invalid chunk options: %v\n", err)
+		os.Exit(2)
+	}
+	tableFormat, err := extractor.ParseTableFormat(*tableFormatFlag)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(2)
+	}
+	p := &pipeline{
+		Chunking:     chunking,
+		TableFormat:  tableFormat,
+		SecretPolicy: secretPolicy,
+		SecretReport: secretReport,
+		Dedup:        dedup,
+		Progress:     printProgressBar,
+	}
+	p.Languages, err = parseLanguages(*langsFlag)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(2)
+	}
+	fields, err := formatter.ParseFieldNames(*fieldsFlag)
+	if err == nil {
+		p.Samples, err = newSampleFormat(*schemaFlag, fields)
+	}
+	if err == nil {
+		p.Instructions, err = newInstructions(*instruction, *instructionFile, *seed)
+	}
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(2)
+	}
+	p.Parquet, err = newParquetOptions(*parquetCodec, *rowGroupSize)
+	if err == nil {
+		p.CSV, err = newCSVOptions(*csvColumns, *csvDelimiter, *csvBOM)
+	}
+	if err == nil {
+		p.Split, err = newSplitOptions(*splitFlag, *stratifyFlag, *seed)
+	}
+	if err == nil {
+		p.Shards, err = newShardOptions(*format, *shardRecords, *shardSize, *compressFlag, *packLength > 0)
+	}
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(2)
+	}
+	if strings.EqualFold(*format, "bin") {
+		p.Binary, err = newBinaryOptions(counter, *tokenizerFlag+":"+*tokenizerFile, *binMode, *eosToken)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(2)
+		}
+	}
+	if *packLength > 0 {
+		p.Packing, err = newPackOptions(*format, counter, *packLength, *eosToken, *packBoundaries)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(2)
+		}
+	}
+	generator, err := newGenerator(generationOptions{
+		Task:        *generateFlag,
+		URL:         *genURL,
+		Model:       *genModel,
+		PromptFile:  *genPrompt,
+		System:      *genSystem,
+		CacheDir:    *genCache,
+		Pairs:       *genPairs,
+		Concurrency: *genConcurrency,
+		Retries:     *genRetries,
+		Temperature: *genTemperature,
+		WithContext: *genContext,
+		APIKey:      os.Getenv("OPENAI_API_KEY"),
+	}, *format, p.Packing != nil)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(2)
+	}
+	p.Generator = generator
+	if *qualityFlag {
+		thresholds := processor.DefaultQualityThresholds()
+		if *qualityConfig != "" {
+			thresholds, err = processor.LoadQualityThresholds(*qualityConfig)
+			if err != nil {
+				fmt.Printf("❌ %v\n", err)
+				os.Exit(2)
+			}
+		}
+		p.Quality = &thresholds
+	}
+
 	// Extraction
-	fmt.Println("🔍 [1/4] Extracting text...")
-	var rawText string
-	var err error
-	ext := strings.ToLower(filepath.Ext(*inputPath))
-	switch ext {
-	case ".txt":
-		rawText, err = extractor.ExtractTextFile(*inputPath)
-	case ".pdf":
-		rawText, err = extractor.ExtractPDFText(*inputPath)
-	default:
-		fmt.Printf("❌ Unsupported file type: %s\n", ext)
+	fmt.Printf("🔍 [1/4] Extracting text from %d file(s)...\n", len(inputs))
+	docs, err := p.extract(inputs)
+	if reportErr := saveSecretReport(*secretsReport, secretReport); reportErr != nil {
+		fmt.Printf("❌ %v\n", reportErr)
+	}
+	if errors.Is(err, extractor.ErrUnsupportedFileType) {
+		fmt.Printf("❌ Unsupported file type: %s\n", strings.ToLower(filepath.Ext(*inputPath)))
 		os.Exit(4)
 	}
+	var secretErr *processor.SecretError
+	if errors.As(err, &secretErr) {
+		fmt.Printf("❌ Secret scan failed the job: %v\n", err)
+		os.Exit(6)
+	}
 	if err != nil {
 		fmt.Printf("❌ Error during extraction: %v\n", err)
 		os.Exit(4)
 	}
-	fmt.Printf("    Extracted %d bytes.\n", len(rawText))
-	printProgressBar("Extracting", 1, 1)
+	rawBytes := 0
+	for _, doc := range docs {
+		rawBytes += len(doc.Text)
+	}
+	fmt.Printf("    Extracted %d bytes.\n", rawBytes)
+	docLanguages := make(map[string]int)
+	for _, doc := range docs {
+		docLanguages[doc.Language]++
+	}
+	fmt.Printf("    Document languages: %s\n", formatLanguageCounts(docLanguages))
+	if secretPolicy != processor.SecretPolicyOff {
+		fmt.Printf("    Secret findings: %d (policy: %s, dropped files: %d)\n", len(secretReport.Findings), secretPolicy, len(secretReport.Dropped))
+	}
+	if len(docs) == 0 {
+		fmt.Println("⚠️  All input dropped: secrets detected.")
+		return
+	}
 
 	// Optional: Parse and analyze extracted text using parser modules
 	if *parseFlag {
 		fmt.Println("🔎 [2/4] Parsing and analyzing extracted text...")
+		lineCount, wordCount := 0, 0
 		var lines []string
-		var wordCount int
-		if ext == ".txt" {
-			lines = parser.SplitWords(rawText)
-			wordCount = parser.CountWords(rawText)
-		} else if ext == ".pdf" {
-			lines = parser.SplitWords(rawText)
-			wordCount = parser.CountWords(rawText)
+		for _, doc := range docs {
+			lineCount += len(strings.Split(doc.Text, "\n"))
+			wordCount += parser.CountWords(doc.Text)
+			if len(lines) == 0 {
+				lines = parser.SplitWords(doc.Text)
+			}
 		}
 		printProgressBar("Parsing", 1, 1)
-		fmt.Printf("    Line count: %d\n", len(strings.Split(rawText, "\n")))
+		fmt.Printf("    Line count: %d\n", lineCount)
 		fmt.Printf("    Word count: %d\n", wordCount)
 		if len(lines) > 0 {
 			fmt.Printf("    First word (upper): %s\n", parser.ToUpper(lines[0]))
@@ -276,64 +868,61 @@ func main() {
 		}
 	}
 
-	// Transform (Clean & Chunk)
+	// Transform (Clean, Chunk, Filter & Deduplicate)
 	fmt.Println("🧹 [3/4] Cleaning and chunking text...")
-	cleanText := processor.CleanText(rawText)
-	chunks := processor.ChunkTextBySearchableTokens(cleanText, *chunkSize, *overlap)
-	fmt.Printf("    Cleaned text length: %d bytes\n", len(cleanText))
-	fmt.Printf("    Chunks created: %d (chunk size: %d, overlap: %d)\n", len(chunks), *chunkSize, *overlap)
-	for i := 1; i <= len(chunks); i++ {
-		printProgressBar("Chunking", i, len(chunks))
-		time.Sleep(5 * time.Millisecond) // Simulate progress for UX
+	chunks, err := p.transform(docs)
+	if err != nil {
+		fmt.Printf("❌ Error during chunking: %v\n", err)
+		os.Exit(4)
 	}
-
-	// Load/Format
-	fmt.Println("💾 [4/4] Formatting and loading output...")
-	switch strings.ToLower(*format) {
-	case "jsonl":
-		for i := 1; i <= len(chunks); i++ {
-			printProgressBar("Writing JSONL", i, len(chunks))
-			// Simulate progress; actual writing is done in one call below
-			time.Sleep(2 * time.Millisecond)
-		}
-		err = formatter.FormatToJSONL(chunks, *outputPath, *instruction)
-	case "csv":
-		for i := 1; i <= len(chunks); i++ {
-			printProgressBar("Writing CSV", i, len(chunks))
-			time.Sleep(2 * time.Millisecond)
-		}
-		err = load.LoadToCSV(chunks, *outputPath)
-	case "postgres":
-		for i := 1; i <= len(chunks); i++ {
-			printProgressBar("Writing Postgres", i, len(chunks))
-			time.Sleep(2 * time.Millisecond)
-		}
-		err = load.LoadToPostgres(chunks, *dbURL)
-	case "mysql":
-		for i := 1; i <= len(chunks); i++ {
-			printProgressBar("Writing MySQL", i, len(chunks))
-			time.Sleep(2 * time.Millisecond)
+	fmt.Printf("    Chunks created: %d (chunker: %s, chunk size: %d, overlap: %d)\n", len(chunks), chunking.Chunker, chunking.Size, chunking.Overlap)
+	totalTokens := 0
+	for _, c := range chunks {
+		totalTokens += c.Tokens
+	}
+	fmt.Printf("    Tokens: %d (tokenizer: %s)\n", totalTokens, *tokenizerFlag)
+	fmt.Printf("    Languages: %s\n", formatLanguageCounts(languageCounts(chunks)))
+	if len(p.Languages) > 0 {
+		fmt.Printf("    Chunks removed by language filter: %d\n", p.LanguageFiltered)
+	}
+	for _, fallback := range p.CodeFallbacks {
+		fmt.Printf("⚠️  Go source chunked as text, it does not parse: %s\n", fallback)
+	}
+	if p.Quality != nil {
+		fmt.Printf("    Chunks rejected by quality filter: %d (see %s)\n", len(p.Rejected), *rejectedPath)
+		if err := processor.WriteRejectedChunks(*rejectedPath, p.Rejected); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(5)
 		}
-		err = load.LoadToMySQL(chunks, *dbURL)
-	case "sqlite":
-		for i := 1; i <= len(chunks); i++ {
-			printProgressBar("Writing SQLite", i, len(chunks))
-			time.Sleep(2 * time.Millisecond)
+	}
+	if dedupMode != processor.DedupOff {
+		fmt.Printf("    Duplicates removed: %d exact, %d near (mode: %s)\n", dedup.ExactRemoved, dedup.NearRemoved, dedupMode)
+		if *dedupIndex != "" {
+			if err := dedup.SaveIndex(*dedupIndex); err != nil {
+				fmt.Printf("❌ %v\n", err)
+				os.Exit(5)
+			}
 		}
-		err = load.LoadToSQLite(chunks, *outputPath)
-	case "mongodb":
-		for i := 1; i <= len(chunks); i++ {
-			printProgressBar("Writing MongoDB", i, len(chunks))
-			time.Sleep(2 * time.Millisecond)
+	}
+
+	if p.Generator != nil {
+		fmt.Printf("    Generating %s samples with %s...\n", p.Generator.Task, *genURL)
+		chunks, err = p.generate(chunks)
+		if err != nil {
+			fmt.Printf("❌ Error during generation: %v\n", err)
+			os.Exit(4)
 		}
-		err = load.LoadToMongoDB(chunks, *dbURL)
-	case "redis":
-		for i := 1; i <= len(chunks); i++ {
-			printProgressBar("Writing Redis", i, len(chunks))
-			time.Sleep(2 * time.Millisecond)
+		fmt.Printf("    Generated samples: %d from %d chunks (cached: %d, failed: %d)\n",
+			p.GenStats.Samples, p.GenStats.Chunks, p.GenStats.CacheHits, p.GenStats.Failed)
+		if p.GenStats.FirstError != "" {
+			fmt.Printf("⚠️  First generation failure: %s\n", p.GenStats.FirstError)
 		}
-		err = load.LoadToRedis(chunks, *dbURL)
-	default:
+	}
+
+	// Load/Format
+	fmt.Println("💾 [4/4] Formatting and loading output...")
+	outputs, err := p.writeOutput(*format, chunks, *outputPath, *dbURL, *langRoute)
+	if errors.Is(err, errUnsupportedFormat) {
 		fmt.Printf("❌ Unsupported output format: %s\n", *format)
 		os.Exit(5)
 	}
@@ -342,7 +931,16 @@ func main() {
 		os.Exit(5)
 	}
 
-	fmt.Printf("✅ Finished. Data saved to: %s\n", *outputPath)
+	if p.Packing != nil {
+		fmt.Printf("    Packed %d chunks into %d sequences of %d tokens (padding waste: %.2f%%, chunks split: %d)\n",
+			p.PackStats.Chunks, p.PackStats.Sequences, p.Packing.Length, p.PackStats.PaddingWaste(), p.PackStats.Split)
+	}
+	for _, name := range processor.SplitNames {
+		if st, ok := p.SplitStats[name]; ok {
+			fmt.Printf("    Split %s: %d chunks from %d documents\n", name, st.Chunks, st.Documents)
+		}
+	}
+	fmt.Printf("✅ Finished. Data saved to: %s\n", strings.Join(outputs, ", "))
 	fmt.Printf("⏱️  Elapsed: %s\n", time.Since(startTime).Truncate(time.Millisecond))
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 }
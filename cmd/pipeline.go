@@ -0,0 +1,708 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/anurag-bit/goetl/pkg/extractor"
+	"github.com/anurag-bit/goetl/pkg/formatter"
+	"github.com/anurag-bit/goetl/pkg/load"
+	"github.com/anurag-bit/goetl/pkg/processor"
+	"github.com/anurag-bit/goetl/utils"
+)
+
+// errUnsupportedFormat is returned by loadTexts for unknown output formats.
+var errUnsupportedFormat = errors.New("unsupported output format")
+
+// document is the extracted text of one input file.
+type document struct {
+	Source             string
+	Text               string
+	Language           string
+	LanguageConfidence float64
+}
+
+// pipeline holds the extract and transform settings shared by the CLI and the API.
+type pipeline struct {
+	// Chunking must have been validated before the pipeline runs.
+	Chunking     processor.ChunkOptions
+	TableFormat  extractor.TableFormat
+	SecretPolicy processor.SecretPolicy
+	SecretReport *processor.SecretReport
+	Dedup        *processor.Deduplicator
+	// Quality, if set, filters chunks before deduplication. Rejected chunks
+	// are collected in Rejected together with the reason.
+	Quality  *processor.QualityThresholds
+	Rejected []processor.RejectedChunk
+	// Languages, if non-empty, keeps only chunks detected as one of these
+	// ISO codes; LanguageFiltered counts the chunks removed.
+	Languages        map[string]bool
+	LanguageFiltered int
+	// CodeFallbacks lists the Go sources that the code chunker chunked as
+	// text because they do not parse.
+	CodeFallbacks []string
+	// Samples selects the schema and field names of jsonl samples and
+	// Instructions renders their instructions.
+	Samples      formatter.SampleFormat
+	Instructions *formatter.Instructions
+	// Generator, if set, has a model write the samples of every chunk;
+	// GenStats reports on the run.
+	Generator *processor.Generator
+	GenStats  processor.GenerationStats
+	// Parquet and CSV configure the parquet and csv/tsv output formats.
+	Parquet formatter.ParquetOptions
+	CSV     formatter.CSVOptions
+	// Binary configures the bin output format; see newBinaryOptions.
+	Binary formatter.BinaryOptions
+	// Packing, if set, packs chunks into fixed-length token sequences for
+	// jsonl and bin output; PackStats sums up every packing run.
+	Packing   *processor.PackOptions
+	PackStats processor.PackStats
+	// Split, if set, writes train, val and test files next to the output
+	// path; SplitStats counts what went into each.
+	Split      *processor.SplitOptions
+	SplitStats map[string]processor.SplitStats
+	// Shards, if set, rolls file output over into numbered shards listed
+	// in a manifest and/or compresses it.
+	Shards *formatter.ShardOptions
+
+	detector *processor.LanguageDetector
+
+	// Progress, if set, is called after each file of a stage.
+	Progress func(stage string, current, total int)
+}
+
+func (p *pipeline) progress(stage string, current, total int) {
+	if p.Progress != nil {
+		p.Progress(stage, current, total)
+	}
+}
+
+// collectInputs returns path itself for a file, or every file below a
+// directory that the extractor supports, in lexical order.
+func collectInputs(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	var inputs []string
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && extractor.IsSupported(p) {
+			inputs = append(inputs, p)
+		}
+		return nil
+	})
+	if err == nil && len(inputs) == 0 {
+		err = fmt.Errorf("no supported files found in %s", path)
+	}
+	return inputs, err
+}
+
+// extract extracts the text of every input and applies the secret policy.
+// Documents dropped by the policy are left out of the result.
+func (p *pipeline) extract(inputs []string) ([]document, error) {
+	scanner := processor.NewSecretScanner()
+	var docs []document
+	for i, path := range inputs {
+		extracted, err := extractor.ExtractDocument(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		text := extracted.Text(p.TableFormat)
+		// Secret scanning runs on the raw text, before cleaning flattens line structure
+		text, keep, err := scanner.Apply(p.SecretPolicy, p.SecretReport, path, text)
+		if err != nil {
+			return nil, err
+		}
+		if keep {
+			lang, conf := p.languageDetector().Detect(text)
+			docs = append(docs, document{Source: path, Text: text, Language: lang, LanguageConfidence: conf})
+		}
+		p.progress("Extracting", i+1, len(inputs))
+	}
+	return docs, nil
+}
+
+func (p *pipeline) counter() processor.TokenCounter {
+	if p.Chunking.Counter == nil {
+		return processor.WordCounter{}
+	}
+	return p.Chunking.Counter
+}
+
+func (p *pipeline) languageDetector() *processor.LanguageDetector {
+	if p.detector == nil {
+		p.detector = processor.NewLanguageDetector()
+	}
+	return p.detector
+}
+
+// parseLanguages turns a comma-separated list of ISO codes into a set. Codes
+// the detector never returns, other than "und" for undetected text, are
+// rejected, since they would silently filter out every chunk.
+func parseLanguages(list string) (map[string]bool, error) {
+	known := map[string]bool{processor.LanguageUnknown: true}
+	codes := []string{processor.LanguageUnknown}
+	for _, code := range processor.NewLanguageDetector().Languages() {
+		if !known[code] {
+			known[code] = true
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	langs := make(map[string]bool)
+	for _, code := range strings.Split(list, ",") {
+		if code = strings.ToLower(strings.TrimSpace(code)); code == "" {
+			continue
+		}
+		if !known[code] {
+			return nil, fmt.Errorf("unknown language %q (want one of %s)", code, strings.Join(codes, ", "))
+		}
+		langs[code] = true
+	}
+	return langs, nil
+}
+
+// transform cleans and chunks every document, keeping tables whole, tags
+// each chunk with its language, drops low-quality and unwanted-language
+// chunks and removes duplicate chunks across all documents.
+func (p *pipeline) transform(docs []document) ([]processor.Chunk, error) {
+	var chunks []processor.Chunk
+	chunking := p.Chunking
+	chunking.CodeFallback = func(source string, err error) {
+		p.CodeFallbacks = append(p.CodeFallbacks, fmt.Sprintf("%s: %v", source, err))
+	}
+	for i, doc := range docs {
+		docChunks, err := chunking.ChunkFile(doc.Source, doc.Text)
+		if err != nil {
+			return nil, err
+		}
+		// Line-based checks need the line breaks that chunking removes.
+		docReason := ""
+		if p.Quality != nil {
+			docReason = p.Quality.CheckDocument(doc.Text)
+		}
+		key := processor.DocumentKey(doc.Text)
+		for _, chunk := range docChunks {
+			text := chunk.Text
+			chunk.Tokens = p.counter().CountTokens(text)
+			chunk.Source = doc.Source
+			chunk.Document = key
+			chunk.Language, chunk.LanguageConfidence = p.languageDetector().Detect(text)
+			if len(p.Languages) > 0 && !p.Languages[chunk.Language] {
+				p.LanguageFiltered++
+				continue
+			}
+			// The quality heuristics are written for prose and would reject most tables.
+			if p.Quality != nil && !chunk.Table {
+				reason := docReason
+				if reason == "" {
+					reason = p.Quality.CheckQuality(text, chunk.Language)
+				}
+				if reason != "" {
+					p.Rejected = append(p.Rejected, processor.RejectedChunk{Source: doc.Source, Reason: reason, Text: text})
+					continue
+				}
+			}
+			if p.Dedup.Keep(text) {
+				chunk.Index = len(chunks) + 1
+				chunks = append(chunks, chunk)
+			}
+		}
+		p.progress("Chunking", i+1, len(docs))
+	}
+	return chunks, nil
+}
+
+// generate replaces the default instruction samples of chunks with the
+// ones the Generator writes, if one is set.
+func (p *pipeline) generate(chunks []processor.Chunk) ([]processor.Chunk, error) {
+	if p.Generator == nil {
+		return chunks, nil
+	}
+	chunks, stats, err := p.Generator.Generate(chunks, func(done, total int) {
+		p.progress("Generating", done, total)
+	})
+	p.GenStats = stats
+	return chunks, err
+}
+
+// hasLanguages reports whether any chunk has a detected language.
+func hasLanguages(chunks []processor.Chunk) bool {
+	for _, c := range chunks {
+		if c.Language != "" && c.Language != processor.LanguageUnknown {
+			return true
+		}
+	}
+	return false
+}
+
+// routeByLanguage groups chunks by language and derives one output path per
+// group by inserting the ISO code before the extension (data.jsonl becomes
+// data.de.jsonl). The returned languages are sorted.
+func routeByLanguage(chunks []processor.Chunk, outputPath string) ([]string, map[string][]processor.Chunk, map[string]string) {
+	groups := make(map[string][]processor.Chunk)
+	for _, c := range chunks {
+		groups[c.Language] = append(groups[c.Language], c)
+	}
+	ext := filepath.Ext(outputPath)
+	base := strings.TrimSuffix(outputPath, ext)
+	paths := make(map[string]string, len(groups))
+	langs := make([]string, 0, len(groups))
+	for lang := range groups {
+		langs = append(langs, lang)
+		paths[lang] = base + "." + lang + ext
+	}
+	sort.Strings(langs)
+	return langs, groups, paths
+}
+
+// newBinaryOptions checks the settings of the bin output format: the
+// token counter must be a tokenizer with a vocabulary that holds eos, and
+// mode is "chunk" or "document".
+func newBinaryOptions(counter processor.TokenCounter, tokenizerName, mode, eos string) (formatter.BinaryOptions, error) {
+	opts := formatter.BinaryOptions{TokenizerName: tokenizerName, EOS: eos}
+	t, ok := counter.(*utils.Tokenizer)
+	if !ok {
+		return opts, fmt.Errorf("bin format needs a tokenizer with a vocabulary (bpe, bytebpe, unigram, hf or tiktoken)")
+	}
+	opts.Tokenizer = t
+	switch strings.ToLower(mode) {
+	case "", "chunk":
+	case "document":
+		opts.Documents = true
+	default:
+		return opts, fmt.Errorf("unknown bin mode %q (want chunk or document)", mode)
+	}
+	if opts.EOS == "" {
+		opts.EOS = utils.EndOfTextToken
+	}
+	if _, ok := t.TokenID(opts.EOS); !ok {
+		return opts, fmt.Errorf("EOS token %q is not in the vocabulary", opts.EOS)
+	}
+	return opts, nil
+}
+
+// newPackOptions checks the settings of sequence packing, which writes
+// jsonl or bin and like the bin format needs a tokenizer with a vocabulary
+// that holds the separator.
+func newPackOptions(format string, counter processor.TokenCounter, length int, separator string, boundaries bool) (*processor.PackOptions, error) {
+	if f := strings.ToLower(format); f != "jsonl" && f != "bin" {
+		return nil, fmt.Errorf("packing writes jsonl or bin, not %s", format)
+	}
+	t, ok := counter.(*utils.Tokenizer)
+	if !ok {
+		return nil, fmt.Errorf("packing needs a tokenizer with a vocabulary (bpe, bytebpe, unigram, hf or tiktoken)")
+	}
+	if length < 2 {
+		return nil, fmt.Errorf("packed sequence length must be at least 2, got %d", length)
+	}
+	if separator == "" {
+		separator = utils.EndOfTextToken
+	}
+	if _, ok := t.TokenID(separator); !ok {
+		return nil, fmt.Errorf("separator token %q is not in the vocabulary", separator)
+	}
+	return &processor.PackOptions{Length: length, Tokenizer: t, Separator: separator, Boundaries: boundaries}, nil
+}
+
+// generationOptions are the sample generation settings of the CLI and the API.
+type generationOptions struct {
+	Task        string
+	URL         string
+	Model       string
+	PromptFile  string
+	System      string
+	CacheDir    string
+	Pairs       int
+	Concurrency int
+	Retries     int
+	Temperature float64
+	WithContext bool
+	// APIKey is sent as a bearer token; see serverEndpoint for the API.
+	APIKey string
+}
+
+// newGenerator returns a Generator for o.Task, or nil if it is off.
+// Generated samples are written as jsonl only.
+func newGenerator(o generationOptions, format string, packing bool) (*processor.Generator, error) {
+	task, err := processor.ParseGenerationTask(o.Task)
+	if err != nil || task == "" {
+		return nil, err
+	}
+	if !strings.EqualFold(format, "jsonl") {
+		return nil, fmt.Errorf("%w for generation: %s", errUnsupportedFormat, format)
+	}
+	if packing {
+		return nil, fmt.Errorf("generated samples cannot be packed")
+	}
+	g := processor.NewGenerator(task, o.URL, o.Model, o.APIKey)
+	if o.PromptFile != "" {
+		prompt, err := os.ReadFile(o.PromptFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read generation prompt: %v", err)
+		}
+		g.Prompt = string(prompt)
+	}
+	g.System, g.CacheDir, g.WithContext = o.System, o.CacheDir, o.WithContext
+	g.Pairs, g.Concurrency, g.Retries, g.Temperature = o.Pairs, o.Concurrency, o.Retries, o.Temperature
+	if err := g.Validate(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// generateURLsEnv and embedURLsEnv hold the chat completions and
+// embeddings endpoints API requests may use.
+const (
+	generateURLsEnv = "GOETL_GENERATE_URLS"
+	embedURLsEnv    = "GOETL_EMBED_URLS"
+)
+
+// serverEndpoint returns the endpoint an API request may send data to:
+// requested if it is in the comma-separated allow-list of the environment
+// variable env, or the list's first entry if requested is empty. API
+// callers cannot name other URLs, so the server's API key only ever goes
+// to endpoints its operator chose.
+func serverEndpoint(env, requested string) (string, error) {
+	var allowed []string
+	for _, u := range strings.Split(os.Getenv(env), ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			allowed = append(allowed, u)
+		}
+	}
+	if len(allowed) == 0 {
+		return "", fmt.Errorf("no endpoint is configured on this server (set %s)", env)
+	}
+	if requested == "" {
+		return allowed[0], nil
+	}
+	for _, u := range allowed {
+		if u == requested {
+			return u, nil
+		}
+	}
+	return "", fmt.Errorf("endpoint %q is not allowed on this server (see %s)", requested, env)
+}
+
+// newParquetOptions checks the settings of the parquet output format.
+func newParquetOptions(codec string, rowGroupSize int) (formatter.ParquetOptions, error) {
+	c, err := formatter.ParseParquetCodec(codec)
+	if err != nil {
+		return formatter.ParquetOptions{}, err
+	}
+	if rowGroupSize < 0 {
+		return formatter.ParquetOptions{}, fmt.Errorf("row group size must not be negative, got %d", rowGroupSize)
+	}
+	return formatter.ParquetOptions{Codec: c, RowGroupSize: rowGroupSize}, nil
+}
+
+// newSplitOptions parses train,val[,test] split ratios; empty ratios turn
+// splitting off.
+func newSplitOptions(ratios, stratify string, seed int64) (*processor.SplitOptions, error) {
+	if ratios == "" {
+		if stratify != "" && !strings.EqualFold(stratify, "none") {
+			return nil, fmt.Errorf("stratification needs split ratios")
+		}
+		return nil, nil
+	}
+	r, err := processor.ParseSplitRatios(ratios)
+	if err != nil {
+		return nil, err
+	}
+	key, err := processor.ParseStratify(stratify)
+	if err != nil {
+		return nil, err
+	}
+	return &processor.SplitOptions{Ratios: r, Stratify: key, Seed: seed}, nil
+}
+
+// newShardOptions checks the shard limits and compression of file output;
+// it returns nil if all are off. Only text formats are compressed, since
+// parquet compresses its pages (-parquetcodec) and bin files must stay
+// memory-mappable. A size limit needs output that is written chunk by
+// chunk, so it does not apply to bin, sqlite or packed output.
+func newShardOptions(format string, records int, size, compression string, packing bool) (*formatter.ShardOptions, error) {
+	if records < 0 {
+		return nil, fmt.Errorf("shard record count must not be negative, got %d", records)
+	}
+	bytes, err := formatter.ParseByteSize(size)
+	if err != nil {
+		return nil, err
+	}
+	c, err := formatter.ParseCompression(compression)
+	if err != nil {
+		return nil, err
+	}
+	opts := &formatter.ShardOptions{Records: records, Bytes: bytes, Compression: c}
+	if !opts.Sharded() && c == formatter.CompressNone {
+		return nil, nil
+	}
+	if !isFileFormat(format) {
+		return nil, fmt.Errorf("%w for sharding or compression: %s", errUnsupportedFormat, format)
+	}
+	switch strings.ToLower(format) {
+	case "jsonl", "csv", "tsv":
+	default:
+		if c != formatter.CompressNone {
+			return nil, fmt.Errorf("compression applies to jsonl, csv and tsv output, not %s", format)
+		}
+	}
+	if bytes > 0 && (packing || !streamsChunks(format)) {
+		return nil, fmt.Errorf("shard size applies to unpacked jsonl, csv, tsv and parquet output; use shard record counts for %s", format)
+	}
+	return opts, nil
+}
+
+// newCSVOptions checks the columns and delimiter of the csv output format.
+func newCSVOptions(columns, delimiter string, bom bool) (formatter.CSVOptions, error) {
+	cols, err := formatter.ParseCSVColumns(columns)
+	if err != nil {
+		return formatter.CSVOptions{}, err
+	}
+	delim, err := formatter.ParseDelimiter(delimiter)
+	if err != nil {
+		return formatter.CSVOptions{}, err
+	}
+	return formatter.CSVOptions{Columns: cols, Delimiter: delim, BOM: bom}, nil
+}
+
+// newSampleFormat validates the jsonl sample schema and field renames.
+func newSampleFormat(schema string, fields map[string]string) (formatter.SampleFormat, error) {
+	s, err := formatter.ParseSchema(schema)
+	if err != nil {
+		return formatter.SampleFormat{}, err
+	}
+	f := formatter.SampleFormat{Schema: s, Fields: fields}
+	return f, f.Validate()
+}
+
+// newInstructions parses the instruction template, or the templates of
+// file if it is set; an empty template means formatter.DefaultInstruction.
+func newInstructions(template, file string, seed int64) (*formatter.Instructions, error) {
+	if file != "" {
+		return formatter.LoadInstructions(file, seed)
+	}
+	if template == "" {
+		template = formatter.DefaultInstruction
+	}
+	return formatter.NewInstructions([]string{template}, seed)
+}
+
+// loadChunks writes chunks to the target selected by format, sharded and
+// compressed as configured by Shards, and returns the files written.
+func (p *pipeline) loadChunks(format string, chunks []processor.Chunk, outputPath, dbURL string) ([]string, error) {
+	if p.Shards == nil {
+		return p.loadFile(format, chunks, outputPath, dbURL)
+	}
+	if p.Packing == nil && streamsChunks(format) {
+		return p.loadStreamed(format, chunks, outputPath)
+	}
+	if !p.Shards.Sharded() {
+		return p.loadFile(format, chunks, outputPath, dbURL)
+	}
+	shards := formatter.ShardChunks(chunks, *p.Shards)
+	manifest := formatter.Manifest{Format: strings.ToLower(format), Compression: p.Shards.Compression}
+	var written []string
+	for i, shard := range shards {
+		files, err := p.loadFile(format, shard, formatter.ShardPath(outputPath, i, len(shards)), dbURL)
+		written = append(written, files...)
+		if err != nil {
+			return written, err
+		}
+		if err := manifest.AddShard(files, len(shard)); err != nil {
+			return written, err
+		}
+	}
+	manifestPath := formatter.ManifestPath(outputPath)
+	if err := formatter.WriteManifest(manifestPath, manifest); err != nil {
+		return written, err
+	}
+	return append(written, manifestPath), nil
+}
+
+// streamsChunks reports whether format is written one chunk at a time,
+// so that shards can be cut by the bytes written.
+func streamsChunks(format string) bool {
+	switch strings.ToLower(format) {
+	case "jsonl", "csv", "tsv", "parquet":
+		return true
+	}
+	return false
+}
+
+// loadStreamed writes chunks through a formatter.ShardWriter, which
+// compresses them as they are written and starts a new shard once one is
+// full.
+func (p *pipeline) loadStreamed(format string, chunks []processor.Chunk, outputPath string) ([]string, error) {
+	w := formatter.NewShardWriter(outputPath, format, *p.Shards, func(w io.Writer) (formatter.ChunkWriter, error) {
+		switch strings.ToLower(format) {
+		case "jsonl":
+			return formatter.NewSampleWriter(w, p.Instructions, p.Samples, true)
+		case "csv":
+			return formatter.NewCSVWriter(w, p.CSV)
+		case "tsv":
+			opts := p.CSV
+			opts.Delimiter = '\t'
+			return formatter.NewCSVWriter(w, opts)
+		default:
+			return formatter.NewParquetWriter(w, p.Parquet)
+		}
+	})
+	for _, c := range chunks {
+		if err := w.Write(c); err != nil {
+			files, _ := w.Close()
+			return files, err
+		}
+	}
+	return w.Close()
+}
+
+// loadFile writes chunks to the target selected by format and returns
+// the files written (outputPath itself for single-file and database targets).
+func (p *pipeline) loadFile(format string, chunks []processor.Chunk, outputPath, dbURL string) ([]string, error) {
+	if p.Packing != nil {
+		return p.loadPacked(format, chunks, outputPath)
+	}
+	if strings.ToLower(format) == "bin" {
+		return formatter.FormatChunksToBinary(chunks, outputPath, p.Binary)
+	}
+	return []string{outputPath}, p.loadTexts(format, chunks, outputPath, dbURL)
+}
+
+// loadPacked packs chunks into sequences and writes them as jsonl or bin.
+func (p *pipeline) loadPacked(format string, chunks []processor.Chunk, outputPath string) ([]string, error) {
+	switch strings.ToLower(format) {
+	case "bin":
+		written, stats, err := formatter.FormatPackedToBinary(chunks, outputPath, p.Binary, *p.Packing)
+		p.PackStats.Add(stats)
+		return written, err
+	case "jsonl":
+		seqs, stats, err := processor.PackChunks(chunks, *p.Packing)
+		if err != nil {
+			return nil, err
+		}
+		p.PackStats.Add(stats)
+		compression := formatter.CompressNone
+		if p.Shards != nil {
+			compression = p.Shards.Compression
+		}
+		w, path, err := formatter.CreateCompressed(outputPath, compression)
+		if err != nil {
+			return nil, err
+		}
+		if err := formatter.WritePackedJSONL(w, seqs); err != nil {
+			w.Close()
+			return []string{path}, err
+		}
+		return []string{path}, w.Close()
+	default:
+		return nil, fmt.Errorf("%w for packing: %s", errUnsupportedFormat, format)
+	}
+}
+
+// loadTexts writes chunks to a single-file or database target.
+func (p *pipeline) loadTexts(format string, chunks []processor.Chunk, outputPath, dbURL string) error {
+	texts := processor.ChunkTexts(chunks)
+	switch strings.ToLower(format) {
+	case "jsonl":
+		return formatter.FormatChunksToSchema(chunks, outputPath, p.Instructions, p.Samples)
+	case "csv":
+		return formatter.FormatChunksToCSV(chunks, outputPath, p.CSV)
+	case "tsv":
+		opts := p.CSV
+		opts.Delimiter = '\t'
+		return formatter.FormatChunksToCSV(chunks, outputPath, opts)
+	case "parquet":
+		return formatter.FormatChunksToParquet(chunks, outputPath, p.Parquet)
+	case "postgres":
+		return load.LoadToPostgres(texts, dbURL)
+	case "mysql":
+		return load.LoadToMySQL(texts, dbURL)
+	case "sqlite":
+		return load.LoadToSQLite(texts, outputPath)
+	case "mongodb":
+		return load.LoadToMongoDB(texts, dbURL)
+	case "redis":
+		return load.LoadToRedis(texts, dbURL)
+	default:
+		return errUnsupportedFormat
+	}
+}
+
+// isFileFormat reports whether format writes to outputPath rather than a database.
+func isFileFormat(format string) bool {
+	switch strings.ToLower(format) {
+	case "jsonl", "csv", "tsv", "parquet", "sqlite", "bin":
+		return true
+	}
+	return false
+}
+
+// writeOutput loads chunks into the target selected by format. With
+// Split set each split is written to its own file next to outputPath
+// (data.jsonl becomes data.train.jsonl, data.val.jsonl and data.test.jsonl);
+// with routeLanguages each language is. It returns the output paths written.
+func (p *pipeline) writeOutput(format string, chunks []processor.Chunk, outputPath, dbURL string, routeLanguages bool) ([]string, error) {
+	if p.Split == nil {
+		return p.writeRouted(format, chunks, outputPath, dbURL, routeLanguages)
+	}
+	if !isFileFormat(format) {
+		return nil, fmt.Errorf("%w for splitting: %s", errUnsupportedFormat, format)
+	}
+	if p.Split.Stratify == processor.StratifyLanguage && !hasLanguages(chunks) {
+		return nil, fmt.Errorf("stratifying by language needs detected languages, but no chunk has one")
+	}
+	names, groups, stats := processor.SplitChunks(chunks, *p.Split)
+	p.SplitStats = stats
+	ext := filepath.Ext(outputPath)
+	base := strings.TrimSuffix(outputPath, ext)
+	var written []string
+	for _, name := range names {
+		files, err := p.writeRouted(format, groups[name], base+"."+name+ext, dbURL, routeLanguages)
+		written = append(written, files...)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// writeRouted writes chunks to outputPath or, with routeLanguages, each
+// language to its own file next to it.
+func (p *pipeline) writeRouted(format string, chunks []processor.Chunk, outputPath, dbURL string, routeLanguages bool) ([]string, error) {
+	if !routeLanguages {
+		written, err := p.loadChunks(format, chunks, outputPath, dbURL)
+		if err != nil {
+			return nil, err
+		}
+		p.progress("Writing", 1, 1)
+		return written, nil
+	}
+	if !isFileFormat(format) {
+		return nil, fmt.Errorf("%w for language routing: %s", errUnsupportedFormat, format)
+	}
+	langs, groups, paths := routeByLanguage(chunks, outputPath)
+	written := make([]string, 0, len(langs))
+	for i, lang := range langs {
+		files, err := p.loadChunks(format, groups[lang], paths[lang], dbURL)
+		written = append(written, files...)
+		if err != nil {
+			return written, err
+		}
+		p.progress("Writing", i+1, len(langs))
+	}
+	return written, nil
+}
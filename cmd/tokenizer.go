@@ -0,0 +1,390 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/anurag-bit/goetl/pkg/extractor"
+	"github.com/anurag-bit/goetl/pkg/processor"
+	"github.com/anurag-bit/goetl/utils"
+)
+
+const tokenizerUsage = `Usage: goetl tokenizer <command> [flags]
+
+Commands:
+  train   Train a tokenizer on the cleaned text of the input and save it
+  encode  Encode the cleaned text of the input to token IDs
+  decode  Decode token IDs back to text
+  stats   Report compression and vocabulary coverage on a held-out input
+
+Run "goetl tokenizer <command> -h" for the flags of a command.
+`
+
+// runTokenizer runs a "goetl tokenizer" subcommand and returns the exit code.
+func runTokenizer(args []string) int {
+	if len(args) == 0 {
+		fmt.Print(tokenizerUsage)
+		return 2
+	}
+	commands := map[string]func([]string) int{
+		"train":  tokenizerTrain,
+		"encode": tokenizerEncode,
+		"decode": tokenizerDecode,
+		"stats":  tokenizerStats,
+	}
+	run, ok := commands[args[0]]
+	if !ok {
+		fmt.Printf("❌ Unknown tokenizer command: %s\n\n", args[0])
+		fmt.Print(tokenizerUsage)
+		return 2
+	}
+	return run(args[1:])
+}
+
+// tokenizerFlags are the flags shared by encode, decode and stats.
+type tokenizerFlags struct {
+	kind, file, input, output *string
+}
+
+func newTokenizerFlagSet(name, inputHelp string) (*flag.FlagSet, tokenizerFlags) {
+	fs := flag.NewFlagSet("goetl tokenizer "+name, flag.ExitOnError)
+	return fs, tokenizerFlags{
+		kind:   fs.String("tokenizer", "bpe", "Tokenizer type: bpe, bytebpe, unigram, hf, tiktoken (saved .json tokenizers load as any of bpe, bytebpe, unigram)"),
+		file:   fs.String("tokenizerfile", "output/tokenizer.json", "Tokenizer file, as written by goetl tokenizer train"),
+		input:  fs.String("input", "", inputHelp),
+		output: fs.String("output", "", "Output file (default: stdout)"),
+	}
+}
+
+// load returns the tokenizer selected by the flags.
+func (f tokenizerFlags) load() (*utils.Tokenizer, error) {
+	counter, err := processor.LoadTokenCounter(*f.kind, *f.file)
+	if err != nil {
+		return nil, err
+	}
+	t, ok := counter.(*utils.Tokenizer)
+	if !ok {
+		return nil, fmt.Errorf("tokenizer %q has no vocabulary", *f.kind)
+	}
+	return t, nil
+}
+
+// create opens the output file, or stdout if none was given.
+func (f tokenizerFlags) create() (io.WriteCloser, error) {
+	if *f.output == "" {
+		return nopCloser{os.Stdout}, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(*f.output), 0755); err != nil {
+		return nil, err
+	}
+	return os.Create(*f.output)
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// cleanedTexts extracts every supported file below path, applies the secret
+// policy, removes the page, section and table markers and cleans the text
+// as the chunkers do, keeping paragraph breaks.
+// With progress set, a progress bar is printed for multi-file inputs.
+func cleanedTexts(path, secrets string, progress bool) ([]string, error) {
+	policy, err := processor.ParseSecretPolicy(secrets)
+	if err != nil {
+		return nil, err
+	}
+	inputs, err := collectInputs(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &pipeline{
+		TableFormat:  extractor.TableMarkdown,
+		SecretPolicy: policy,
+		SecretReport: processor.NewSecretReport(policy),
+	}
+	if progress && len(inputs) > 1 {
+		p.Progress = printProgressBar
+	}
+	docs, err := p.extract(inputs)
+	if err != nil {
+		return nil, err
+	}
+	texts := make([]string, len(docs))
+	for i, doc := range docs {
+		texts[i] = processor.CleanTextKeepParagraphs(processor.StripMarkers(doc.Text))
+	}
+	return texts, nil
+}
+
+func tokenizerTrain(args []string) int {
+	fs := flag.NewFlagSet("goetl tokenizer train", flag.ExitOnError)
+	input := fs.String("input", "", "Training input file or directory (any supported format)")
+	output := fs.String("output", "output/tokenizer.json", "Path of the saved tokenizer")
+	model := fs.String("model", "bytebpe", "Tokenizer model: bpe, bytebpe (byte-level BPE), unigram")
+	vocabSize := fs.Int("vocabsize", 8000, "Vocabulary size, including special tokens")
+	secrets := fs.String("secrets", "redact", "Secret handling for training documents: off, redact, drop, fail")
+	fs.Parse(args)
+	if *input == "" {
+		fmt.Println("❌ Please provide training data with -input.")
+		fs.Usage()
+		return 2
+	}
+
+	fmt.Printf("🔍 Extracting training text from %s...\n", *input)
+	texts, err := cleanedTexts(*input, *secrets, true)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 4
+	}
+	counts := make(map[string]int)
+	for _, text := range texts {
+		for w, c := range utils.CountWords(text, *model == "bytebpe") {
+			counts[w] += c
+		}
+	}
+	fmt.Printf("    Documents: %d, distinct words: %d\n", len(texts), len(counts))
+
+	fmt.Printf("🧠 Training %s tokenizer with %d tokens...\n", *model, *vocabSize)
+	t, err := trainTokenizer(*model, counts, *vocabSize)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 2
+	}
+	if err := os.MkdirAll(filepath.Dir(*output), 0755); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 5
+	}
+	if err := utils.SaveTokenizer(*output, t); err != nil {
+		fmt.Printf("❌ Failed to save tokenizer: %v\n", err)
+		return 5
+	}
+	fmt.Printf("✅ Saved tokenizer with %d tokens to: %s\n", t.VocabSize(), *output)
+	return 0
+}
+
+// trainTokenizer trains a tokenizer of the given model on word counts from
+// utils.CountWords. For BPE, vocabSize is met by learning as many merges as
+// there are IDs left after the special tokens and the base alphabet.
+func trainTokenizer(model string, counts map[string]int, vocabSize int) (*utils.Tokenizer, error) {
+	if len(counts) == 0 {
+		return nil, fmt.Errorf("no training text")
+	}
+	switch model {
+	case "unigram":
+		return utils.TrainUnigramFromCounts(counts, vocabSize)
+	case "bytebpe":
+		base := utils.NewByteLevelTokenizer(nil).VocabSize()
+		if vocabSize <= base {
+			return nil, fmt.Errorf("vocab size %d must exceed the %d base tokens", vocabSize, base)
+		}
+		t := utils.NewByteLevelTokenizer(utils.TrainByteLevelBPEFromCounts(counts, vocabSize-base))
+		t.BuildVocab(nil)
+		return t, nil
+	case "bpe":
+		seen := make(map[rune]bool)
+		alphabet := []string{"</w>"}
+		for w := range counts {
+			for _, r := range w {
+				if !seen[r] {
+					seen[r] = true
+					alphabet = append(alphabet, string(r))
+				}
+			}
+		}
+		t := utils.NewTokenizer(nil, "</w>")
+		t.BuildVocab(alphabet)
+		base := t.VocabSize()
+		if vocabSize <= base {
+			return nil, fmt.Errorf("vocab size %d must exceed the %d base tokens", vocabSize, base)
+		}
+		t = utils.NewTokenizer(utils.TrainBPEFromCounts(counts, vocabSize-base, "</w>"), "</w>")
+		t.BuildVocab(alphabet)
+		return t, nil
+	default:
+		return nil, fmt.Errorf("unknown tokenizer model %q (want bpe, bytebpe or unigram)", model)
+	}
+}
+
+func tokenizerEncode(args []string) int {
+	fs, f := newTokenizerFlagSet("encode", "Input file or directory to encode (any supported format)")
+	secrets := fs.String("secrets", "redact", "Secret handling for input documents: off, redact, drop, fail")
+	fs.Parse(args)
+	if *f.input == "" {
+		fmt.Fprintln(os.Stderr, "❌ Please provide the text to encode with -input.")
+		fs.Usage()
+		return 2
+	}
+	t, err := f.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 2
+	}
+	texts, err := cleanedTexts(*f.input, *secrets, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 4
+	}
+	out, err := f.create()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 5
+	}
+	w := bufio.NewWriter(out)
+	// One line of space-separated IDs per input document.
+	for _, text := range texts {
+		ids, err := t.Encode(text)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			return 4
+		}
+		for i, id := range ids {
+			if i > 0 {
+				w.WriteByte(' ')
+			}
+			w.WriteString(strconv.Itoa(id))
+		}
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 5
+	}
+	if err := out.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 5
+	}
+	return 0
+}
+
+func tokenizerDecode(args []string) int {
+	fs, f := newTokenizerFlagSet("decode", "File of space-separated token IDs, one sequence per line (default: stdin)")
+	fs.Parse(args)
+	t, err := f.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 2
+	}
+	in := os.Stdin
+	if *f.input != "" {
+		if in, err = os.Open(*f.input); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			return 2
+		}
+		defer in.Close()
+	}
+	out, err := f.create()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 5
+	}
+	w := bufio.NewWriter(out)
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		ids := make([]int, len(fields))
+		for i, field := range fields {
+			if ids[i], err = strconv.Atoi(field); err != nil {
+				fmt.Fprintf(os.Stderr, "❌ Line %d: invalid token ID %q\n", line, field)
+				return 4
+			}
+		}
+		text, err := t.Decode(ids)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Line %d: %v\n", line, err)
+			return 4
+		}
+		w.WriteString(text)
+		w.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 4
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 5
+	}
+	if err := out.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 5
+	}
+	return 0
+}
+
+// tokenizerReport summarizes how a tokenizer encodes a held-out text.
+type tokenizerReport struct {
+	Documents int
+	Bytes     int
+	Chars     int
+	Words     int
+	Tokens    int
+	// Unknown counts tokens outside the vocabulary or equal to the unknown token.
+	Unknown int
+	// Used is the number of distinct vocabulary IDs that occur.
+	Used      int
+	VocabSize int
+}
+
+func measureTokenizer(t *utils.Tokenizer, texts []string) tokenizerReport {
+	r := tokenizerReport{Documents: len(texts), VocabSize: t.VocabSize()}
+	used := make(map[int]bool)
+	for _, text := range texts {
+		r.Bytes += len(text)
+		r.Chars += utf8.RuneCountInString(text)
+		r.Words += len(strings.Fields(text))
+		for _, tok := range t.Tokenize(text) {
+			r.Tokens++
+			id, ok := t.TokenID(tok)
+			if !ok || tok == utils.UnknownToken {
+				r.Unknown++
+				continue
+			}
+			used[id] = true
+		}
+	}
+	r.Used = len(used)
+	return r
+}
+
+func tokenizerStats(args []string) int {
+	fs, f := newTokenizerFlagSet("stats", "Held-out input file or directory (any supported format)")
+	secrets := fs.String("secrets", "redact", "Secret handling for input documents: off, redact, drop, fail")
+	fs.Parse(args)
+	if *f.input == "" {
+		fmt.Println("❌ Please provide held-out text with -input.")
+		fs.Usage()
+		return 2
+	}
+	t, err := f.load()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 2
+	}
+	texts, err := cleanedTexts(*f.input, *secrets, true)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 4
+	}
+	r := measureTokenizer(t, texts)
+	ratio := func(a, b int) float64 {
+		if b == 0 {
+			return 0
+		}
+		return float64(a) / float64(b)
+	}
+	fmt.Printf("📊 Tokenizer: %s (%d tokens)\n", *f.file, r.VocabSize)
+	fmt.Printf("    Held-out text: %d document(s), %d bytes, %d characters, %d words\n", r.Documents, r.Bytes, r.Chars, r.Words)
+	fmt.Printf("    Tokens: %d\n", r.Tokens)
+	fmt.Printf("    Compression: %.2f bytes/token, %.2f characters/token, %.2f tokens/word\n", ratio(r.Bytes, r.Tokens), ratio(r.Chars, r.Tokens), ratio(r.Tokens, r.Words))
+	fmt.Printf("    Vocabulary coverage: %.2f%% of tokens known (%d unknown), %.2f%% of the vocabulary used (%d IDs)\n",
+		100*(1-ratio(r.Unknown, r.Tokens)), r.Unknown, 100*ratio(r.Used, r.VocabSize), r.Used)
+	return 0
+}
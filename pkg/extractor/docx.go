@@ -0,0 +1,135 @@
+// extractor/docx.go
+package extractor
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ExtractDOCXDocument extracts the paragraphs and tables of a Word (.docx)
+// file from its word/document.xml part. Tables nested in a cell are
+// flattened into the cell's text.
+func ExtractDOCXDocument(path string) (*Document, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open docx: %v", err)
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		if f.Name != "word/document.xml" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read docx: %v", err)
+		}
+		defer rc.Close()
+		doc, err := parseDOCX(rc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse docx: %v", err)
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("failed to read docx: word/document.xml not found")
+}
+
+// parseDOCX walks the WordprocessingML body. Only local element names are
+// compared, so the w: namespace prefix does not matter.
+func parseDOCX(r io.Reader) (*Document, error) {
+	doc := &Document{}
+	dec := xml.NewDecoder(r)
+
+	var (
+		para      strings.Builder // text of the current paragraph
+		style     string          // pStyle of the current paragraph
+		inText    bool
+		depth     int // table nesting depth
+		table     *Table
+		row       []string
+		cellParas []string
+	)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				para.Reset()
+				style = ""
+			case "pStyle":
+				for _, a := range t.Attr {
+					if a.Name.Local == "val" {
+						style = a.Value
+					}
+				}
+			case "t":
+				inText = true
+			case "tab":
+				para.WriteString("\t")
+			case "br", "cr":
+				para.WriteString("\n")
+			case "tbl":
+				depth++
+				if depth == 1 {
+					table = &Table{}
+				}
+			case "tr":
+				if depth == 1 {
+					row = nil
+				}
+			case "tc":
+				if depth == 1 {
+					cellParas = nil
+				}
+			}
+		case xml.CharData:
+			if inText {
+				para.Write(t)
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				text := strings.TrimSpace(para.String())
+				switch {
+				case depth > 0:
+					if text != "" {
+						cellParas = append(cellParas, text)
+					}
+				case text != "":
+					lower := strings.ToLower(style)
+					doc.Blocks = append(doc.Blocks, Block{
+						Text:    text,
+						Heading: strings.HasPrefix(lower, "heading") || lower == "title",
+						caption: strings.Contains(lower, "caption"),
+					})
+				}
+			case "tc":
+				if depth == 1 {
+					row = append(row, strings.Join(cellParas, " "))
+				}
+			case "tr":
+				if depth == 1 && len(row) > 0 {
+					table.Rows = append(table.Rows, row)
+				}
+			case "tbl":
+				if depth == 1 && len(table.Rows) > 0 {
+					doc.Blocks = append(doc.Blocks, Block{Table: table})
+				}
+				depth--
+			}
+		}
+	}
+	doc.attachCaptions()
+	return doc, nil
+}
@@ -0,0 +1,61 @@
+// extractor/extract.go
+package extractor
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnsupportedFileType is returned by ExtractFile for inputs it has no extractor for.
+var ErrUnsupportedFileType = errors.New("unsupported file type")
+
+// textExtensions lists plain-text, source code and config formats that are read verbatim.
+var textExtensions = map[string]bool{
+	".txt": true, ".md": true, ".rst": true, ".log": true,
+	".go": true, ".py": true, ".js": true, ".ts": true, ".java": true, ".rs": true,
+	".c": true, ".h": true, ".cpp": true, ".rb": true, ".php": true, ".sh": true, ".sql": true,
+	".json": true, ".yaml": true, ".yml": true, ".toml": true, ".ini": true, ".cfg": true,
+	".conf": true, ".env": true, ".properties": true, ".xml": true,
+}
+
+// documentExtractors handles formats with structure beyond plain text.
+var documentExtractors = map[string]func(string) (*Document, error){
+	".pdf":  ExtractPDFDocument,
+	".docx": ExtractDOCXDocument,
+	".html": ExtractHTMLDocument,
+	".htm":  ExtractHTMLDocument,
+}
+
+// IsSupported reports whether ExtractFile can handle the file at path.
+func IsSupported(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return documentExtractors[ext] != nil || textExtensions[ext]
+}
+
+// ExtractFile extracts the text of the file at path, choosing the extractor
+// from the file extension. Tables are serialized as Markdown.
+func ExtractFile(path string) (string, error) {
+	doc, err := ExtractDocument(path)
+	if err != nil {
+		return "", err
+	}
+	return doc.Text(TableMarkdown), nil
+}
+
+// ExtractDocument extracts the prose and tables of the file at path. Plain
+// text formats yield a single block.
+func ExtractDocument(path string) (*Document, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if extract := documentExtractors[ext]; extract != nil {
+		return extract(path)
+	}
+	if !textExtensions[ext] {
+		return nil, ErrUnsupportedFileType
+	}
+	text, err := ExtractTextFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Document{Blocks: []Block{{Text: text}}}, nil
+}
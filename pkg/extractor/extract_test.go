@@ -0,0 +1,61 @@
+package extractor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/anurag-bit/goetl/pkg/processor"
+)
+
+// Marker syntax written in a plain-text input is text, not a marker.
+func TestExtractTextKeepsLiteralMarkers(t *testing.T) {
+	content := "How to mark pages:\n[[page 2]]\nand sections:\n[[section: Notes]]\n\nTables go between\n[[table: Totals]]\nand\n[[/table]]\n"
+	path := filepath.Join(t.TempDir(), "markers.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := ExtractDocument(path)
+	if err != nil {
+		t.Fatalf("ExtractDocument: %v", err)
+	}
+	text := doc.Text(TableMarkdown)
+	if got := processor.StripMarkers(text); got != content {
+		t.Errorf("StripMarkers(Text) = %q, want %q", got, content)
+	}
+
+	chunks, err := processor.ChunkOptions{Chunker: processor.ChunkerParagraph}.ChunkDocument(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var words []string
+	for _, c := range chunks {
+		if c.Table || c.Page != 0 || c.Section != "" {
+			t.Errorf("chunk %q has table %v, page %d, section %q", c.Text, c.Table, c.Page, c.Section)
+		}
+		words = append(words, strings.Fields(c.Text)...)
+	}
+	if !reflect.DeepEqual(words, strings.Fields(content)) {
+		t.Errorf("chunks hold %q, want every word of the file", words)
+	}
+}
+
+// A table cell holding marker syntax does not end the table early.
+func TestDocumentTextEscapesTables(t *testing.T) {
+	table := &Table{Rows: [][]string{{"marker"}, {"[[/table]]"}, {"[[page 7]]"}}}
+	doc := &Document{Blocks: []Block{{Text: "Before.", Page: 1}, {Table: table, Page: 1}, {Text: "[[page 3]]", Page: 2}}}
+	chunks, err := processor.ChunkOptions{Chunker: processor.ChunkerParagraph}.ChunkDocument(doc.Text(TableCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []processor.Chunk{
+		{Text: "Before.", Page: 1},
+		{Text: "marker\n[[/table]]\n[[page 7]]", Table: true, Page: 1},
+		{Text: "[[page 3]]", Page: 2},
+	}
+	if !reflect.DeepEqual(chunks, want) {
+		t.Errorf("chunks = %+v, want %+v", chunks, want)
+	}
+}
@@ -0,0 +1,140 @@
+// extractor/html.go
+package extractor
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// htmlBlockElements end the current paragraph.
+var htmlBlockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "hr": true, "li": true, "ul": true, "ol": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"section": true, "article": true, "header": true, "footer": true, "nav": true, "aside": true,
+	"main": true, "blockquote": true, "pre": true, "dl": true, "dt": true, "dd": true,
+	"figure": true, "figcaption": true, "form": true, "address": true,
+}
+
+// htmlHeadingElements title the section after them.
+var htmlHeadingElements = map[string]bool{
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// htmlSkipElements hold no readable text.
+var htmlSkipElements = map[string]bool{
+	"head": true, "script": true, "style": true, "noscript": true, "template": true, "svg": true,
+}
+
+// ExtractHTMLDocument extracts the paragraphs and tables of an HTML file.
+// A table's <caption> becomes its caption; tables nested in a cell are
+// flattened into the cell's text.
+func ExtractHTMLDocument(path string) (*Document, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	root, err := html.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse html: %v", err)
+	}
+
+	doc := &Document{}
+	var para strings.Builder
+	heading := false
+	flush := func() {
+		if text := strings.Join(strings.Fields(para.String()), " "); text != "" {
+			doc.Blocks = append(doc.Blocks, Block{Text: text, Heading: heading})
+		}
+		para.Reset()
+		heading = false
+	}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			para.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			if htmlSkipElements[n.Data] {
+				return
+			}
+			if n.Data == "table" {
+				flush()
+				if t := htmlTable(n); len(t.Rows) > 0 {
+					doc.Blocks = append(doc.Blocks, Block{Table: t})
+				}
+				return
+			}
+			if htmlBlockElements[n.Data] {
+				flush()
+				heading = htmlHeadingElements[n.Data]
+				defer flush()
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+	flush()
+	doc.attachCaptions()
+	return doc, nil
+}
+
+// htmlTable collects the caption and the rows of a table element, skipping
+// rows that belong to nested tables.
+func htmlTable(table *html.Node) *Table {
+	t := &Table{}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch c.Data {
+			case "caption":
+				t.Caption = htmlText(c)
+			case "tr":
+				var row []string
+				for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type == html.ElementNode && (cell.Data == "td" || cell.Data == "th") {
+						row = append(row, htmlText(cell))
+					}
+				}
+				if len(row) > 0 {
+					t.Rows = append(t.Rows, row)
+				}
+			case "table":
+			default:
+				walk(c)
+			}
+		}
+	}
+	walk(table)
+	return t
+}
+
+// htmlText returns the text below n with whitespace collapsed.
+func htmlText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			b.WriteString(" ")
+			return
+		}
+		if n.Type == html.ElementNode && htmlSkipElements[n.Data] {
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
@@ -4,7 +4,11 @@
 package extractor
 
 import (
+	"math"
+	"sort"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"rsc.io/pdf"
 )
@@ -12,7 +16,7 @@ import (
 // ExtractPDFText extracts text from all pages of a PDF file.
 // ExtractPDFText extracts text content from a PDF file at the given path.
 // It processes all pages in the PDF and concatenates their text content,
-// separated by newlines.
+// separated by blank lines. Tables are serialized as Markdown.
 //
 // Parameters:
 //   - pdfPath: The file path to the PDF to be processed
@@ -21,12 +25,22 @@ import (
 //   - string: The extracted text content from all PDF pages
 //   - error: An error if the PDF file cannot be opened or processed
 func ExtractPDFText(pdfPath string) (string, error) {
-	r, err := pdf.Open(pdfPath)
+	doc, err := ExtractPDFDocument(pdfPath)
 	if err != nil {
 		return "", err
 	}
+	return doc.Text(TableMarkdown), nil
+}
 
-	var output strings.Builder
+// ExtractPDFDocument extracts the text of every page of a PDF, rebuilding
+// lines from glyph positions and detecting tables from column alignment.
+func ExtractPDFDocument(pdfPath string) (*Document, error) {
+	r, err := pdf.Open(pdfPath)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Document{}
 	numPages := r.NumPage()
 
 	for i := 1; i <= numPages; i++ {
@@ -35,21 +49,219 @@ func ExtractPDFText(pdfPath string) (string, error) {
 			continue
 		}
 
-		content := extractTextFromPage(page.Content())
-		output.WriteString(content + "\n")
+		blocks := layoutBlocks(page.Content().Text)
+		for j := range blocks {
+			blocks[j].Page = i
+		}
+		doc.Blocks = append(doc.Blocks, blocks...)
 	}
+	doc.attachCaptions()
 
-	return output.String(), nil
+	return doc, nil
 }
 
-// extractTextFromPage parses and extracts text content from PDF operations.
-func extractTextFromPage(content pdf.Content) string {
-	var text strings.Builder
+// pdfCell is a run of glyphs on a line with no column-sized gap inside.
+type pdfCell struct {
+	text   string
+	x0, x1 float64
+}
+
+// pdfLine is the glyphs sharing a baseline, split into cells.
+type pdfLine struct {
+	size  float64
+	cells []pdfCell
+}
+
+// Gaps between glyphs are measured in multiples of the font size: a gap
+// wider than wordGap is a space, one wider than columnGap separates cells.
+const (
+	wordGap   = 0.15
+	columnGap = 1.0
+)
+
+// proseWords is the average number of words per cell from which an aligned
+// column holds running text rather than table cells.
+const proseWords = 4
 
-	for _, txt := range content.Text {
-		text.WriteString(txt.S)
-		text.WriteString(" ")
+// proseLines is the fewest lines an aligned run needs to be read as columns
+// of prose; shorter runs are tables.
+const proseLines = 3
+
+// layoutBlocks groups the glyphs of a page into lines, then into prose
+// blocks and the tables formed by runs of lines with aligned columns. A run
+// whose every column holds running text, as on a two-column page, is
+// prose and is read column by column.
+func layoutBlocks(glyphs []pdf.Text) []Block {
+	lines := groupLines(glyphs)
+	var blocks []Block
+	var prose []string
+	flush := func() {
+		if len(prose) > 0 {
+			blocks = append(blocks, Block{Text: strings.Join(prose, "\n")})
+			prose = nil
+		}
 	}
+	for i := 0; i < len(lines); {
+		n := alignedRun(lines[i:])
+		if n >= 2 && proseColumns(lines[i:i+n]) {
+			for j := range lines[i].cells {
+				for _, l := range lines[i : i+n] {
+					prose = append(prose, l.cells[j].text)
+				}
+			}
+			i += n
+			continue
+		}
+		if n >= 2 {
+			flush()
+			t := &Table{}
+			for _, l := range lines[i : i+n] {
+				row := make([]string, len(l.cells))
+				for j, c := range l.cells {
+					row[j] = c.text
+				}
+				t.Rows = append(t.Rows, row)
+			}
+			blocks = append(blocks, Block{Table: t})
+			i += n
+			continue
+		}
+		texts := make([]string, len(lines[i].cells))
+		for j, c := range lines[i].cells {
+			texts[j] = c.text
+		}
+		prose = append(prose, strings.Join(texts, " "))
+		i++
+	}
+	flush()
+	return blocks
+}
+
+// groupLines sorts glyphs top to bottom, left to right and joins glyphs on
+// the same baseline into lines.
+func groupLines(glyphs []pdf.Text) []pdfLine {
+	sorted := make([]pdf.Text, 0, len(glyphs))
+	for _, g := range glyphs {
+		if strings.TrimSpace(g.S) != "" {
+			sorted = append(sorted, g)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Y > sorted[j].Y })
+
+	var lines []pdfLine
+	var current []pdf.Text
+	for _, g := range sorted {
+		if len(current) > 0 && math.Abs(g.Y-current[0].Y) > lineTolerance(g, current[0]) {
+			lines = append(lines, buildLine(current))
+			current = nil
+		}
+		current = append(current, g)
+	}
+	if len(current) > 0 {
+		lines = append(lines, buildLine(current))
+	}
+	return lines
+}
+
+func lineTolerance(a, b pdf.Text) float64 {
+	return math.Max(1, 0.3*math.Min(a.FontSize, b.FontSize))
+}
+
+// buildLine orders the glyphs of a line by X and splits them into words
+// and cells by the gaps between them. Glyphs without width information
+// keep their content order.
+func buildLine(glyphs []pdf.Text) pdfLine {
+	sort.SliceStable(glyphs, func(i, j int) bool { return glyphs[i].X < glyphs[j].X })
+	line := pdfLine{size: glyphs[0].FontSize}
+	var b strings.Builder
+	cur := pdfCell{x0: glyphs[0].X}
+	for i, g := range glyphs {
+		if i > 0 {
+			gap := g.X - cur.x1
+			size := math.Max(g.FontSize, 1)
+			switch {
+			case gap > columnGap*size:
+				cur.text = b.String()
+				line.cells = append(line.cells, cur)
+				b.Reset()
+				cur = pdfCell{x0: g.X}
+			case gap > wordGap*size:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString(g.S)
+		cur.x1 = math.Max(cur.x1, g.X+g.W)
+	}
+	cur.text = b.String()
+	line.cells = append(line.cells, cur)
+	return line
+}
+
+// alignedRun returns how many lines starting at lines[0] have the same
+// number (at least two) of cells whose horizontal extents line up column
+// by column.
+func alignedRun(lines []pdfLine) int {
+	cols := len(lines[0].cells)
+	if cols < 2 {
+		return 0
+	}
+	x0 := make([]float64, cols)
+	x1 := make([]float64, cols)
+	for j, c := range lines[0].cells {
+		x0[j], x1[j] = c.x0, c.x1
+	}
+	n := 1
+	for ; n < len(lines); n++ {
+		l := lines[n]
+		if len(l.cells) != cols {
+			break
+		}
+		slack := 0.5 * l.size
+		aligned := true
+		for j, c := range l.cells {
+			if c.x1 < x0[j]-slack || c.x0 > x1[j]+slack {
+				aligned = false
+				break
+			}
+		}
+		if !aligned {
+			break
+		}
+		for j, c := range l.cells {
+			x0[j], x1[j] = math.Min(x0[j], c.x0), math.Max(x1[j], c.x1)
+		}
+	}
+	return n
+}
+
+// proseColumns reports whether every column of an aligned run holds
+// running text: table cells are mostly short, while a column of a
+// multi-column layout holds line after line of words. A table whose cells
+// are sentences has long cells too, but its lines each start a sentence,
+// while at least half the lines of a prose column carry on the one above.
+func proseColumns(lines []pdfLine) bool {
+	if len(lines) < proseLines {
+		return false
+	}
+	for j := range lines[0].cells {
+		words, continued := 0, 0
+		for k, l := range lines {
+			words += len(strings.Fields(l.cells[j].text))
+			if k > 0 && continuesLine(lines[k-1].cells[j].text, l.cells[j].text) {
+				continued++
+			}
+		}
+		if words < proseWords*len(lines) || 2*continued < len(lines)-1 {
+			return false
+		}
+	}
+	return true
+}
 
-	return text.String()
+// continuesLine reports whether next reads as the continuation of the
+// sentence on prev: it starts in lower case, or prev breaks off after a
+// hyphen or comma.
+func continuesLine(prev, next string) bool {
+	first, _ := utf8.DecodeRuneInString(next)
+	return unicode.IsLower(first) || strings.HasSuffix(prev, "-") || strings.HasSuffix(prev, ",")
 }
@@ -0,0 +1,154 @@
+package extractor
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"rsc.io/pdf"
+)
+
+// testdata/twocolumn.pdf is a hand-written page in Courier with explicit
+// glyph widths: a title, six lines of two-column prose, a three-column
+// table and a closing paragraph.
+func TestExtractPDFTwoColumns(t *testing.T) {
+	doc, err := ExtractPDFDocument("testdata/twocolumn.pdf")
+	if err != nil {
+		t.Fatalf("ExtractPDFDocument: %v", err)
+	}
+	var prose []string
+	var tables []*Table
+	for _, b := range doc.Blocks {
+		if b.Page != 1 {
+			t.Errorf("block on page %d, want 1", b.Page)
+		}
+		if b.Table != nil {
+			tables = append(tables, b.Table)
+		} else {
+			prose = append(prose, b.Text)
+		}
+	}
+
+	wantRows := [][]string{{"Item", "Qty", "Price"}, {"Widget", "4", "2.50"}, {"Gadget", "10", "1.25"}, {"Gizmo", "1", "9.99"}}
+	if len(tables) != 1 || !reflect.DeepEqual(tables[0].Rows, wantRows) {
+		t.Fatalf("tables = %+v, want one with rows %q", tables, wantRows)
+	}
+
+	text := strings.Join(prose, "\n")
+	for _, s := range []string{"A Two-Column Page", "deduplicated before they are used.", "only then continue with the right.", "spans the whole page width."} {
+		if !strings.Contains(text, s) {
+			t.Errorf("prose lacks %q:\n%s", s, text)
+		}
+	}
+	// The left column is read to its end before the right one starts.
+	if l, r := strings.Index(text, "deduplicated before"), strings.Index(text, "Scientific papers"); l < 0 || r < l {
+		t.Errorf("left column does not come first:\n%s", text)
+	}
+	if strings.Contains(text, "trained on Scientific") {
+		t.Errorf("columns are interleaved line by line:\n%s", text)
+	}
+}
+
+// courierLines lays out rows of cells in 10 pt Courier, one glyph per
+// character 6 pt wide, with rows 14 pt apart. Each cell starts at the x
+// given for its column.
+func courierLines(x []float64, rows ...[]string) []pdf.Text {
+	var glyphs []pdf.Text
+	for i, row := range rows {
+		y := 700 - 14*float64(i)
+		for j, cell := range row {
+			for k, r := range cell {
+				glyphs = append(glyphs, pdf.Text{Font: "Courier", FontSize: 10, X: x[j] + 6*float64(k), Y: y, W: 6, S: string(r)})
+			}
+		}
+	}
+	return glyphs
+}
+
+func TestPDFColumnLayout(t *testing.T) {
+	twoColumns := [][]string{
+		{"Large language models are trained on", "Scientific papers are often set in"},
+		{"text gathered from many sources, and", "two columns, which a naive reader"},
+		{"the quality of that text matters as", "would take for a table with two"},
+		{"much as its quantity. Documents are", "cells on every line. The extractor"},
+		{"cleaned, split into chunks and then", "must read the left column first and"},
+		{"deduplicated before they are used.", "only then continue with the right."},
+	}
+	for _, tc := range []struct {
+		name  string
+		x     []float64
+		rows  [][]string
+		prose bool
+	}{
+		{"table", []float64{72, 250, 400}, [][]string{
+			{"Item", "Qty", "Price"},
+			{"Widget", "4", "2.50"},
+			{"Gadget", "10", "1.25"},
+			{"Gizmo", "1", "9.99"},
+		}, false},
+		{"two-column page", []float64{72, 320}, twoColumns, true},
+		{"table of sentences", []float64{72, 330}, [][]string{
+			{"Fast mode skips all of the checks.", "Use it only when the input is trusted."},
+			{"Safe mode validates every record.", "It is slower but reports all errors."},
+			{"Strict mode also rejects warnings.", "Pick it for data that gets published."},
+			{"Dry runs write nothing at all here.", "They show what a real run would do."},
+		}, false},
+		{"two lines of columns", []float64{72, 320}, twoColumns[:2], false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			lines := groupLines(courierLines(tc.x, tc.rows...))
+			if n := alignedRun(lines); n != len(tc.rows) {
+				t.Fatalf("alignedRun = %d, want %d", n, len(tc.rows))
+			}
+			if got := proseColumns(lines); got != tc.prose {
+				t.Errorf("proseColumns = %v, want %v", got, tc.prose)
+			}
+
+			blocks := layoutBlocks(courierLines(tc.x, tc.rows...))
+			if len(blocks) != 1 {
+				t.Fatalf("layoutBlocks = %+v, want one block", blocks)
+			}
+			if !tc.prose {
+				if blocks[0].Table == nil || !reflect.DeepEqual(blocks[0].Table.Rows, tc.rows) {
+					t.Errorf("layoutBlocks = %+v, want a table of the rows", blocks[0])
+				}
+				return
+			}
+			// Columns are read one after the other.
+			var want []string
+			for j := range tc.rows[0] {
+				for _, row := range tc.rows {
+					want = append(want, row[j])
+				}
+			}
+			if blocks[0].Table != nil || blocks[0].Text != strings.Join(want, "\n") {
+				t.Errorf("layoutBlocks = %+v, want the columns in turn", blocks[0])
+			}
+		})
+	}
+
+	// A line that spans both columns ends the run.
+	rows := append(append([][]string(nil), twoColumns[:3]...), []string{"A closing line across the page."})
+	if n := alignedRun(groupLines(courierLines([]float64{72, 320}, rows...))); n != 3 {
+		t.Errorf("alignedRun over a full-width line = %d, want 3", n)
+	}
+}
+
+func TestContinuesLine(t *testing.T) {
+	for _, tc := range []struct {
+		prev, next string
+		want       bool
+	}{
+		{"trained on", "text gathered", true},
+		{"as it is used.", "the next one", true},
+		{"are trained on", "Wikipedia and", false},
+		{"models are pre-", "Trained first", true},
+		{"in Berlin,", "Paris and Rome", true},
+		{"Fast mode skips the checks.", "Safe mode validates", false},
+		{"über", "äußerst", true},
+	} {
+		if got := continuesLine(tc.prev, tc.next); got != tc.want {
+			t.Errorf("continuesLine(%q, %q) = %v, want %v", tc.prev, tc.next, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+// extractor/table.go
+package extractor
+
+import (
+	"encoding/csv"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/anurag-bit/goetl/pkg/processor"
+)
+
+// Table is a table found in a document. The first row is the header.
+type Table struct {
+	Caption string
+	Rows    [][]string
+}
+
+// TableFormat selects how tables are serialized into the extracted text.
+type TableFormat string
+
+const (
+	TableMarkdown TableFormat = "markdown"
+	TableCSV      TableFormat = "csv"
+)
+
+// ParseTableFormat parses a flag or API value; empty selects TableMarkdown.
+func ParseTableFormat(s string) (TableFormat, error) {
+	switch f := TableFormat(strings.ToLower(strings.TrimSpace(s))); f {
+	case "", "md", TableMarkdown:
+		return TableMarkdown, nil
+	case TableCSV:
+		return TableCSV, nil
+	default:
+		return "", fmt.Errorf("unknown table format %q (want markdown or csv)", s)
+	}
+}
+
+// columns returns the width of the widest row.
+func (t Table) columns() int {
+	n := 0
+	for _, row := range t.Rows {
+		n = max(n, len(row))
+	}
+	return n
+}
+
+// cell returns the text of a cell on a single line, or "" past the end of a ragged row.
+func cell(row []string, i int) string {
+	if i >= len(row) {
+		return ""
+	}
+	return strings.Join(strings.Fields(row[i]), " ")
+}
+
+// Markdown serializes the table as a GitHub-flavored Markdown table.
+func (t Table) Markdown() string {
+	cols := t.columns()
+	var b strings.Builder
+	writeRow := func(row []string) {
+		b.WriteString("|")
+		for i := 0; i < cols; i++ {
+			b.WriteString(" " + strings.ReplaceAll(cell(row, i), "|", `\|`) + " |")
+		}
+		b.WriteString("\n")
+	}
+	for i, row := range t.Rows {
+		writeRow(row)
+		if i == 0 {
+			b.WriteString("|" + strings.Repeat(" --- |", cols) + "\n")
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// CSV serializes the table as RFC 4180 CSV.
+func (t Table) CSV() string {
+	cols := t.columns()
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	for _, row := range t.Rows {
+		record := make([]string, cols)
+		for i := range record {
+			record[i] = cell(row, i)
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Serialize returns the table in format, wrapped in the block markers the
+// chunker keeps intact.
+func (t Table) Serialize(format TableFormat) string {
+	body := t.Markdown()
+	if format == TableCSV {
+		body = t.CSV()
+	}
+	return processor.FormatTableBlock(body, t.Caption)
+}
+
+// Block is a paragraph of prose or a table of a document.
+type Block struct {
+	Text  string
+	Table *Table
+	// Page is the 1-based page of the block in paged formats, else 0.
+	Page int
+	// Heading marks a paragraph that titles the section after it.
+	Heading bool
+
+	// caption marks a paragraph the source styled as a caption.
+	caption bool
+}
+
+// Document is the content of a file as prose blocks and tables in reading order.
+type Document struct {
+	Blocks []Block
+}
+
+// Text renders the document as plain text with every table serialized in
+// format. Blocks are separated by blank lines. Page changes and headings
+// are preceded by position markers the chunker reads; prose lines that
+// read as markers are escaped with processor.EscapeMarkers.
+func (d *Document) Text(format TableFormat) string {
+	parts := make([]string, 0, len(d.Blocks))
+	page := 0
+	for _, b := range d.Blocks {
+		if b.Page > 0 && b.Page != page {
+			parts = append(parts, processor.FormatPageMarker(b.Page))
+			page = b.Page
+		}
+		if b.Heading && strings.TrimSpace(b.Text) != "" {
+			parts = append(parts, processor.FormatSectionMarker(b.Text))
+		}
+		if b.Table != nil {
+			parts = append(parts, b.Table.Serialize(format))
+		} else if strings.TrimSpace(b.Text) != "" {
+			parts = append(parts, processor.EscapeMarkers(b.Text))
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// Tables returns the tables of the document.
+func (d *Document) Tables() []Table {
+	var tables []Table
+	for _, b := range d.Blocks {
+		if b.Table != nil {
+			tables = append(tables, *b.Table)
+		}
+	}
+	return tables
+}
+
+// captionPattern matches caption lines such as "Table 2: Results" or "Tab. IV".
+var captionPattern = regexp.MustCompile(`(?i)^(table|tab\.)\s*[0-9ivxlc]+\b`)
+
+// attachCaptions gives every table without a caption the line directly above
+// it, or failing that directly below it, if that line is styled as a caption
+// or reads like one. The line is removed from the prose.
+func (d *Document) attachCaptions() {
+	for i := range d.Blocks {
+		t := d.Blocks[i].Table
+		if t == nil || t.Caption != "" {
+			continue
+		}
+		if i > 0 && d.Blocks[i-1].Table == nil {
+			if c, ok := takeCaption(&d.Blocks[i-1], false); ok {
+				t.Caption = c
+				continue
+			}
+		}
+		if i+1 < len(d.Blocks) && d.Blocks[i+1].Table == nil {
+			if c, ok := takeCaption(&d.Blocks[i+1], true); ok {
+				t.Caption = c
+			}
+		}
+	}
+	blocks := d.Blocks[:0]
+	for _, b := range d.Blocks {
+		if b.Table != nil || strings.TrimSpace(b.Text) != "" {
+			blocks = append(blocks, b)
+		}
+	}
+	d.Blocks = blocks
+}
+
+// takeCaption removes and returns the first (or last) line of a prose block
+// if it is a caption.
+func takeCaption(b *Block, first bool) (string, bool) {
+	lines := strings.Split(strings.TrimSpace(b.Text), "\n")
+	idx := len(lines) - 1
+	if first {
+		idx = 0
+	}
+	line := strings.TrimSpace(lines[idx])
+	if line == "" || !(b.caption && len(lines) == 1 || captionPattern.MatchString(line)) {
+		return "", false
+	}
+	lines = append(lines[:idx], lines[idx+1:]...)
+	b.Text = strings.Join(lines, "\n")
+	return line, true
+}
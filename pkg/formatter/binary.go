@@ -0,0 +1,348 @@
+// formatter/binary.go
+package formatter
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/anurag-bit/goetl/pkg/processor"
+	"github.com/anurag-bit/goetl/utils"
+)
+
+// BinaryOptions configures FormatChunksToBinary.
+type BinaryOptions struct {
+	Tokenizer *utils.Tokenizer
+	// TokenizerName identifies the tokenizer in the metadata sidecar,
+	// e.g. "bytebpe:output/tokenizer.json".
+	TokenizerName string
+	// Documents writes the chunks of a source back to back, separated by a
+	// paragraph break, with one EOS after the last chunk. Otherwise every
+	// chunk is followed by an EOS.
+	Documents bool
+	// EOS is the separator token; it defaults to utils.EndOfTextToken.
+	EOS string
+}
+
+// BinaryMeta is the metadata sidecar written next to the .bin/.idx files.
+type BinaryMeta struct {
+	Tokenizer string `json:"tokenizer"`
+	VocabSize int    `json:"vocab_size"`
+	DType     string `json:"dtype"`
+	EOS       string `json:"eos_token"`
+	EOSID     int    `json:"eos_id"`
+	Mode      string `json:"mode"`
+	// SequenceLength is the length of every sequence in packed mode.
+	SequenceLength int `json:"sequence_length,omitempty"`
+	BinaryFiles
+}
+
+// BinaryFiles describes the files of a binary dataset and what they hold.
+type BinaryFiles struct {
+	Bin       string `json:"bin"`
+	Index     string `json:"idx"`
+	Segments  string `json:"segments,omitempty"`
+	Sequences int    `json:"sequences"`
+	Documents int    `json:"documents"`
+	Tokens    int64  `json:"tokens"`
+	Padding   int    `json:"padding,omitempty"`
+}
+
+// Megatron indexed dataset header and dtype codes.
+const (
+	idxMagic    = "MMIDIDX\x00\x00"
+	idxVersion  = 1
+	dtypeInt32  = 4
+	dtypeUint16 = 8
+)
+
+// FormatChunksToBinary tokenizes chunks and writes them as a packed token
+// stream (.bin) with a Megatron-style index (.idx), ready for nanoGPT or
+// Megatron-LM. Tokens are uint16 if the vocabulary fits, else 32-bit
+// (recorded as int32 in the index, as Megatron has no uint32 type). Each
+// chunk is one sequence of the index; sequences of the same source form
+// one document. A data.meta.json sidecar records the tokenizer, vocabulary
+// size and counts. It returns the paths written.
+func FormatChunksToBinary(chunks []processor.Chunk, outputPath string, opts BinaryOptions) ([]string, error) {
+	mode := "chunk"
+	if opts.Documents {
+		mode = "document"
+	}
+	d, err := newBinDataset(outputPath, opts, mode, false)
+	if err != nil {
+		return nil, err
+	}
+	defer d.abort()
+
+	for i, chunk := range chunks {
+		w := d.writer
+		newDoc := w.sequences() == 0 || chunk.Source != w.source
+		if newDoc && w.sequences() > 0 {
+			w.endDocument()
+		}
+		w.source = chunk.Source
+
+		text := chunk.Text
+		if opts.Documents && !newDoc {
+			text = "\n\n" + text
+		}
+		ids, err := opts.Tokenizer.Encode(text)
+		if err != nil {
+			return d.written, err
+		}
+		lastOfDoc := i+1 == len(chunks) || chunks[i+1].Source != chunk.Source
+		if !opts.Documents || lastOfDoc {
+			ids = append(ids, d.meta.EOSID)
+		}
+		if err := w.writeSequence(ids, nil); err != nil {
+			return d.written, err
+		}
+	}
+	return d.finish()
+}
+
+// FormatPackedToBinary packs chunks with processor.PackChunks and writes
+// every packed sequence as one sequence and document of the index. The EOS token separates chunks. With
+// pack.Boundaries, the segment IDs of every token are written as uint16 to
+// a .seg file next to each .bin file.
+func FormatPackedToBinary(chunks []processor.Chunk, outputPath string, opts BinaryOptions, pack processor.PackOptions) ([]string, processor.PackStats, error) {
+	var stats processor.PackStats
+	d, err := newBinDataset(outputPath, opts, "packed", pack.Boundaries)
+	if err != nil {
+		return nil, stats, err
+	}
+	defer d.abort()
+	d.meta.SequenceLength = pack.Length
+	pack.Tokenizer, pack.Separator = opts.Tokenizer, d.meta.EOS
+
+	seqs, stats, err := processor.PackChunks(chunks, pack)
+	if err != nil {
+		return d.written, stats, err
+	}
+	d.writer.padding = stats.Padding
+	for _, seq := range seqs {
+		if err := d.writer.writeSequence(seq.Tokens, seq.Segments); err != nil {
+			return d.written, stats, err
+		}
+		d.writer.endDocument()
+	}
+	written, err := d.finish()
+	return written, stats, err
+}
+
+// binDataset holds the writer of a binary dataset and its metadata.
+type binDataset struct {
+	base    string
+	meta    BinaryMeta
+	writer  *binWriter
+	written []string
+}
+
+func newBinDataset(outputPath string, opts BinaryOptions, mode string, segments bool) (*binDataset, error) {
+	if opts.Tokenizer == nil {
+		return nil, fmt.Errorf("binary output needs a tokenizer with a vocabulary")
+	}
+	if opts.EOS == "" {
+		opts.EOS = utils.EndOfTextToken
+	}
+	eos, ok := opts.Tokenizer.TokenID(opts.EOS)
+	if !ok {
+		return nil, fmt.Errorf("EOS token %q is not in the vocabulary", opts.EOS)
+	}
+	d := &binDataset{
+		base: strings.TrimSuffix(outputPath, filepath.Ext(outputPath)),
+		meta: BinaryMeta{
+			Tokenizer: opts.TokenizerName,
+			VocabSize: opts.Tokenizer.VocabSize(),
+			DType:     "uint16",
+			EOS:       opts.EOS,
+			EOSID:     eos,
+			Mode:      mode,
+		},
+	}
+	if d.meta.VocabSize > math.MaxUint16+1 {
+		d.meta.DType = "uint32"
+	}
+	w, err := newBinWriter(d.base, d.meta.DType, segments)
+	if err != nil {
+		return nil, err
+	}
+	d.writer = w
+	d.written = append(d.written, w.binPath, w.idxPath)
+	if segments {
+		d.written = append(d.written, w.segPath)
+	}
+	return d, nil
+}
+
+// abort closes the data files; it is a no-op after finish.
+func (d *binDataset) abort() {
+	d.writer.file.Close()
+	if d.writer.segFile != nil {
+		d.writer.segFile.Close()
+	}
+}
+
+// finish closes the data files, writes the index and the metadata sidecar
+// and returns the paths written.
+func (d *binDataset) finish() ([]string, error) {
+	w := d.writer
+	if w.sequences() > 0 && w.docIndices[len(w.docIndices)-1] != int64(w.sequences()) {
+		w.endDocument()
+	}
+	if err := w.close(); err != nil {
+		return d.written, err
+	}
+	d.meta.BinaryFiles = BinaryFiles{
+		Bin:       w.binPath,
+		Index:     w.idxPath,
+		Segments:  w.segPath,
+		Sequences: w.sequences(),
+		Documents: len(w.docIndices) - 1,
+		Tokens:    w.tokens,
+		Padding:   w.padding,
+	}
+
+	metaPath := d.base + ".meta.json"
+	f, err := os.Create(metaPath)
+	if err != nil {
+		return d.written, err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(d.meta); err != nil {
+		return d.written, err
+	}
+	return append(d.written, metaPath), f.Close()
+}
+
+// binWriter streams tokens to a .bin file and collects the index entries.
+type binWriter struct {
+	binPath, idxPath, segPath string
+	file, segFile             *os.File
+	buf, segBuf               *bufio.Writer
+	wide                      bool
+	dtype                     byte
+
+	lengths    []int32
+	pointers   []int64
+	docIndices []int64
+	offset     int64
+	tokens     int64
+	padding    int
+	source     string
+}
+
+func newBinWriter(prefix, dtype string, segments bool) (*binWriter, error) {
+	w := &binWriter{
+		binPath:    prefix + ".bin",
+		idxPath:    prefix + ".idx",
+		wide:       dtype == "uint32",
+		dtype:      dtypeUint16,
+		docIndices: []int64{0},
+	}
+	if w.wide {
+		w.dtype = dtypeInt32
+	}
+	f, err := os.Create(w.binPath)
+	if err != nil {
+		return nil, err
+	}
+	w.file, w.buf = f, bufio.NewWriter(f)
+	if segments {
+		w.segPath = prefix + ".seg"
+		if w.segFile, err = os.Create(w.segPath); err != nil {
+			f.Close()
+			return nil, err
+		}
+		w.segBuf = bufio.NewWriter(w.segFile)
+	}
+	return w, nil
+}
+
+func (w *binWriter) sequences() int { return len(w.lengths) }
+
+// writeSequence appends one sequence, and its segment IDs if the writer
+// has a .seg file.
+func (w *binWriter) writeSequence(ids, segments []int) error {
+	var scratch [4]byte
+	if w.segBuf != nil {
+		for _, s := range segments {
+			binary.LittleEndian.PutUint16(scratch[:], uint16(s))
+			if _, err := w.segBuf.Write(scratch[:2]); err != nil {
+				return err
+			}
+		}
+	}
+	for _, id := range ids {
+		var err error
+		if w.wide {
+			binary.LittleEndian.PutUint32(scratch[:], uint32(id))
+			_, err = w.buf.Write(scratch[:4])
+		} else {
+			binary.LittleEndian.PutUint16(scratch[:], uint16(id))
+			_, err = w.buf.Write(scratch[:2])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	size := int64(2)
+	if w.wide {
+		size = 4
+	}
+	w.lengths = append(w.lengths, int32(len(ids)))
+	w.pointers = append(w.pointers, w.offset)
+	w.offset += int64(len(ids)) * size
+	w.tokens += int64(len(ids))
+	return nil
+}
+
+func (w *binWriter) endDocument() {
+	w.docIndices = append(w.docIndices, int64(len(w.lengths)))
+}
+
+// close flushes the .bin and .seg files and writes the .idx file.
+func (w *binWriter) close() error {
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	if w.segBuf != nil {
+		if err := w.segBuf.Flush(); err != nil {
+			return err
+		}
+		if err := w.segFile.Close(); err != nil {
+			return err
+		}
+	}
+	f, err := os.Create(w.idxPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	b := bufio.NewWriter(f)
+	b.WriteString(idxMagic)
+	for _, v := range []interface{}{
+		uint64(idxVersion), w.dtype,
+		uint64(len(w.lengths)), uint64(len(w.docIndices)),
+		w.lengths, w.pointers, w.docIndices,
+	} {
+		if err := binary.Write(b, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+	if err := b.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,250 @@
+package formatter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/anurag-bit/goetl/pkg/processor"
+	"github.com/anurag-bit/goetl/utils"
+)
+
+// megatronIndex is what readIndex found in an .idx file, read as Megatron's
+// MMapIndexedDataset does.
+type megatronIndex struct {
+	version    uint64
+	dtype      byte
+	lengths    []int32
+	pointers   []int64
+	docIndices []int64
+}
+
+func readIndex(t *testing.T, path string) megatronIndex {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte("MMIDIDX\x00\x00")) {
+		t.Fatalf("%s starts with %q, want the MMIDIDX magic", path, data[:min(9, len(data))])
+	}
+	r := bytes.NewReader(data[9:])
+	var idx megatronIndex
+	var sequences, documents uint64
+	for _, v := range []interface{}{&idx.version, &idx.dtype, &sequences, &documents} {
+		if err := binary.Read(r, binary.LittleEndian, v); err != nil {
+			t.Fatalf("reading header: %v", err)
+		}
+	}
+	idx.lengths = make([]int32, sequences)
+	idx.pointers = make([]int64, sequences)
+	idx.docIndices = make([]int64, documents)
+	for _, v := range []interface{}{idx.lengths, idx.pointers, idx.docIndices} {
+		if err := binary.Read(r, binary.LittleEndian, v); err != nil {
+			t.Fatalf("reading index: %v", err)
+		}
+	}
+	if r.Len() != 0 {
+		t.Fatalf("%d bytes after the index", r.Len())
+	}
+	return idx
+}
+
+// readTokens decodes a .bin or .seg file of little-endian integers.
+func readTokens(t *testing.T, path string, size int) []int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data)%size != 0 {
+		t.Fatalf("%s is %d bytes, not a multiple of %d", path, len(data), size)
+	}
+	ids := make([]int, len(data)/size)
+	for i := range ids {
+		if size == 2 {
+			ids[i] = int(binary.LittleEndian.Uint16(data[i*2:]))
+		} else {
+			ids[i] = int(binary.LittleEndian.Uint32(data[i*4:]))
+		}
+	}
+	return ids
+}
+
+func readBinaryMeta(t *testing.T, path string) BinaryMeta {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m BinaryMeta
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// binaryTokenizer returns a byte-level tokenizer without merges. If wide,
+// <|endoftext|> is moved to ID 70000 so that the vocabulary needs 32 bits.
+func binaryTokenizer(wide bool) *utils.Tokenizer {
+	tok := utils.NewByteLevelTokenizer(nil)
+	tok.BuildVocab(nil)
+	if !wide {
+		return tok
+	}
+	vocab := make(map[string]int, len(tok.Vocab))
+	for s, id := range tok.Vocab {
+		vocab[s] = id
+	}
+	vocab[utils.EndOfTextToken] = 70000
+	tok = utils.NewByteLevelTokenizer(nil)
+	tok.Vocab = vocab
+	return tok
+}
+
+func TestFormatChunksToBinary(t *testing.T) {
+	chunks := []processor.Chunk{
+		{Text: "alpha", Source: "a.md"},
+		{Text: "beta", Source: "a.md"},
+		{Text: "gamma", Source: "b.md"},
+		{Text: "delta", Source: "c.md"},
+		{Text: "epsilon", Source: "c.md"},
+	}
+	for _, tc := range []struct {
+		name      string
+		wide      bool
+		documents bool
+		dtype     byte
+		// texts are the sequences before tokenization and eos whether each
+		// ends in the EOS token.
+		texts []string
+		eos   []bool
+	}{
+		{"chunks", false, false, 8, []string{"alpha", "beta", "gamma", "delta", "epsilon"}, []bool{true, true, true, true, true}},
+		{"documents", false, true, 8, []string{"alpha", "\n\nbeta", "gamma", "delta", "\n\nepsilon"}, []bool{false, true, true, false, true}},
+		{"wide chunks", true, false, 4, []string{"alpha", "beta", "gamma", "delta", "epsilon"}, []bool{true, true, true, true, true}},
+		{"wide documents", true, true, 4, []string{"alpha", "\n\nbeta", "gamma", "delta", "\n\nepsilon"}, []bool{false, true, true, false, true}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tok := binaryTokenizer(tc.wide)
+			eos, _ := tok.TokenID(utils.EndOfTextToken)
+			path := filepath.Join(t.TempDir(), "data.bin")
+			files, err := FormatChunksToBinary(chunks, path, BinaryOptions{Tokenizer: tok, TokenizerName: "test", Documents: tc.documents})
+			if err != nil {
+				t.Fatalf("FormatChunksToBinary: %v", err)
+			}
+			dir := filepath.Dir(path)
+			wantFiles := []string{filepath.Join(dir, "data.bin"), filepath.Join(dir, "data.idx"), filepath.Join(dir, "data.meta.json")}
+			if !reflect.DeepEqual(files, wantFiles) {
+				t.Fatalf("files = %v, want %v", files, wantFiles)
+			}
+
+			size := 2
+			if tc.wide {
+				size = 4
+			}
+			var want []int
+			wantIdx := megatronIndex{version: 1, dtype: tc.dtype, docIndices: []int64{0, 2, 3, 5}}
+			for i, text := range tc.texts {
+				ids, err := tok.Encode(text)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tc.eos[i] {
+					ids = append(ids, eos)
+				}
+				wantIdx.lengths = append(wantIdx.lengths, int32(len(ids)))
+				wantIdx.pointers = append(wantIdx.pointers, int64(len(want)*size))
+				want = append(want, ids...)
+			}
+			if idx := readIndex(t, files[1]); !reflect.DeepEqual(idx, wantIdx) {
+				t.Errorf("index = %+v, want %+v", idx, wantIdx)
+			}
+			if got := readTokens(t, files[0], size); !reflect.DeepEqual(got, want) {
+				t.Errorf("tokens = %v, want %v", got, want)
+			}
+
+			m := readBinaryMeta(t, files[2])
+			wantMeta := BinaryMeta{Tokenizer: "test", VocabSize: tok.VocabSize(), DType: "uint16", EOS: utils.EndOfTextToken, EOSID: eos, Mode: "chunk",
+				BinaryFiles: BinaryFiles{Bin: files[0], Index: files[1], Sequences: 5, Documents: 3, Tokens: int64(len(want))}}
+			if tc.wide {
+				wantMeta.DType = "uint32"
+			}
+			if tc.documents {
+				wantMeta.Mode = "document"
+			}
+			if m != wantMeta {
+				t.Errorf("meta = %+v, want %+v", m, wantMeta)
+			}
+		})
+	}
+}
+
+func TestFormatPackedToBinary(t *testing.T) {
+	chunks := []processor.Chunk{
+		{Text: "aaaaa", Source: "A"},
+		{Text: "bb", Source: "B"},
+		{Text: "c", Source: "C"},
+		{Text: "ddd", Source: "D"},
+		{Text: "eeeeeeeeeeeeeeeeeeee", Source: "E"},
+	}
+	for _, tc := range []struct {
+		length     int
+		boundaries bool
+	}{
+		{8, true}, {8, false}, {16, true}, {64, true},
+	} {
+		tok := binaryTokenizer(false)
+		path := filepath.Join(t.TempDir(), "data.bin")
+		pack := processor.PackOptions{Length: tc.length, Boundaries: tc.boundaries}
+		files, stats, err := FormatPackedToBinary(chunks, path, BinaryOptions{Tokenizer: tok}, pack)
+		if err != nil {
+			t.Fatalf("length %d: FormatPackedToBinary: %v", tc.length, err)
+		}
+		pack.Tokenizer = tok
+		seqs, wantStats, err := processor.PackChunks(chunks, pack)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats != wantStats {
+			t.Errorf("length %d: stats = %+v, want %+v", tc.length, stats, wantStats)
+		}
+
+		// Every packed sequence is one sequence and one document.
+		wantIdx := megatronIndex{version: 1, dtype: 8, docIndices: []int64{0}}
+		var wantTokens, wantSegments []int
+		for i, seq := range seqs {
+			wantIdx.lengths = append(wantIdx.lengths, int32(tc.length))
+			wantIdx.pointers = append(wantIdx.pointers, int64(i*tc.length*2))
+			wantIdx.docIndices = append(wantIdx.docIndices, int64(i+1))
+			wantTokens = append(wantTokens, seq.Tokens...)
+			wantSegments = append(wantSegments, seq.Segments...)
+		}
+		if idx := readIndex(t, files[1]); !reflect.DeepEqual(idx, wantIdx) {
+			t.Errorf("length %d: index = %+v, want %+v", tc.length, idx, wantIdx)
+		}
+		if got := readTokens(t, files[0], 2); !reflect.DeepEqual(got, wantTokens) {
+			t.Errorf("length %d: tokens = %v, want %v", tc.length, got, wantTokens)
+		}
+
+		m := readBinaryMeta(t, files[len(files)-1])
+		if tc.boundaries {
+			if len(files) != 4 || files[2] != filepath.Join(filepath.Dir(path), "data.seg") || m.Segments != files[2] {
+				t.Fatalf("length %d: files = %v, segments %q; want a .seg file", tc.length, files, m.Segments)
+			}
+			if got := readTokens(t, files[2], 2); !reflect.DeepEqual(got, wantSegments) {
+				t.Errorf("length %d: segment IDs = %v, want %v", tc.length, got, wantSegments)
+			}
+		} else if len(files) != 3 || m.Segments != "" {
+			t.Errorf("length %d: files = %v, segments %q; want no .seg file", tc.length, files, m.Segments)
+		}
+		if m.Mode != "packed" || m.SequenceLength != tc.length || m.Sequences != len(seqs) || m.Documents != len(seqs) ||
+			m.Tokens != int64(len(seqs)*tc.length) || m.Padding != stats.Padding {
+			t.Errorf("length %d: meta = %+v for %d sequences", tc.length, m, len(seqs))
+		}
+	}
+}
@@ -0,0 +1,179 @@
+// formatter/csv.go
+package formatter
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/anurag-bit/goetl/pkg/processor"
+)
+
+// DefaultCSVColumns are the columns written when none are configured.
+const DefaultCSVColumns = "id,source,page,text,tokens,hash"
+
+// csvColumns renders the value of every column FormatChunksToCSV can write
+// for the chunk with the given 1-based row number.
+var csvColumns = map[string]func(index int, c processor.Chunk) string{
+	"id": func(index int, c processor.Chunk) string {
+		if c.Index > 0 {
+			return strconv.Itoa(c.Index)
+		}
+		return strconv.Itoa(index)
+	},
+	"source": func(index int, c processor.Chunk) string { return c.Source },
+	"page": func(index int, c processor.Chunk) string {
+		if c.Page == 0 {
+			return ""
+		}
+		return strconv.Itoa(c.Page)
+	},
+	"section":  func(index int, c processor.Chunk) string { return c.Section },
+	"language": func(index int, c processor.Chunk) string { return c.Language },
+	"text":     func(index int, c processor.Chunk) string { return c.Text },
+	"tokens":   func(index int, c processor.Chunk) string { return strconv.Itoa(c.Tokens) },
+	"hash": func(index int, c processor.Chunk) string {
+		sum := sha256.Sum256([]byte(c.Text))
+		return hex.EncodeToString(sum[:])
+	},
+}
+
+// CSVOptions configures FormatChunksToCSV.
+type CSVOptions struct {
+	// Columns are written in order; see ParseCSVColumns.
+	Columns []string
+	// Delimiter separates fields; it defaults to a comma.
+	Delimiter rune
+	// BOM starts the file with a UTF-8 byte order mark, so that Excel
+	// detects the encoding.
+	BOM bool
+}
+
+// ParseCSVColumns validates a comma-separated list of columns: id (the
+// chunk's 1-based Index, or else its row number), source, page, section, language, text, tokens and hash (the
+// SHA-256 of the text). An empty list means DefaultCSVColumns.
+func ParseCSVColumns(list string) ([]string, error) {
+	if strings.TrimSpace(list) == "" {
+		list = DefaultCSVColumns
+	}
+	var columns []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if csvColumns[name] == nil {
+			return nil, fmt.Errorf("unknown csv column %q (want id, source, page, section, language, text, tokens or hash)", name)
+		}
+		columns = append(columns, name)
+	}
+	return columns, nil
+}
+
+// ParseDelimiter validates a field delimiter given as a single character,
+// `\t` or "tab"; empty means a comma.
+func ParseDelimiter(s string) (rune, error) {
+	switch s {
+	case "":
+		return ',', nil
+	case `\t`, "tab":
+		return '\t', nil
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	if size != len(s) || r == utf8.RuneError || r == '"' || r == '\r' || r == '\n' {
+		return 0, fmt.Errorf("invalid csv delimiter %q", s)
+	}
+	return r, nil
+}
+
+// CSVWriter streams chunks as RFC 4180 CSV with a header row: fields
+// holding the delimiter, quotes or line breaks are quoted and quotes are
+// doubled, so chunk text survives intact.
+type CSVWriter struct {
+	w       *csv.Writer
+	columns []string
+	record  []string
+	index   int
+}
+
+// NewCSVWriter writes the byte order mark, if requested, and the header
+// row to w.
+func NewCSVWriter(w io.Writer, opts CSVOptions) (*CSVWriter, error) {
+	if len(opts.Columns) == 0 {
+		opts.Columns, _ = ParseCSVColumns(DefaultCSVColumns)
+	}
+	for _, name := range opts.Columns {
+		if csvColumns[name] == nil {
+			return nil, fmt.Errorf("unknown csv column %q", name)
+		}
+	}
+	if opts.BOM {
+		if _, err := io.WriteString(w, "\ufeff"); err != nil {
+			return nil, fmt.Errorf("failed to write to CSV file: %v", err)
+		}
+	}
+	cw := &CSVWriter{w: csv.NewWriter(w), columns: opts.Columns, record: make([]string, len(opts.Columns))}
+	if opts.Delimiter != 0 {
+		cw.w.Comma = opts.Delimiter
+	}
+	if err := cw.w.Write(opts.Columns); err != nil {
+		return nil, fmt.Errorf("failed to write to CSV file: %v", err)
+	}
+	return cw, nil
+}
+
+// Write adds one chunk as a row. Rows are flushed as they are written.
+func (cw *CSVWriter) Write(c processor.Chunk) error {
+	cw.index++
+	for j, name := range cw.columns {
+		cw.record[j] = csvColumns[name](cw.index, c)
+	}
+	if err := cw.w.Write(cw.record); err != nil {
+		return fmt.Errorf("failed to write to CSV file: %v", err)
+	}
+	cw.w.Flush()
+	if err := cw.w.Error(); err != nil {
+		return fmt.Errorf("failed to write to CSV file: %v", err)
+	}
+	return nil
+}
+
+// Close flushes the writer. It does not close the underlying writer.
+func (cw *CSVWriter) Close() error {
+	cw.w.Flush()
+	if err := cw.w.Error(); err != nil {
+		return fmt.Errorf("failed to write to CSV file: %v", err)
+	}
+	return nil
+}
+
+// FormatChunksToCSV writes chunks to a CSV file with a CSVWriter.
+func FormatChunksToCSV(chunks []processor.Chunk, outputPath string, opts CSVOptions) error {
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create CSV file: %v", err)
+	}
+	defer file.Close()
+
+	buf := bufio.NewWriter(file)
+	w, err := NewCSVWriter(buf, opts)
+	if err != nil {
+		return err
+	}
+	for _, c := range chunks {
+		if err := w.Write(c); err != nil {
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("failed to write to CSV file: %v", err)
+	}
+	return file.Close()
+}
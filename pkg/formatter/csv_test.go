@@ -0,0 +1,180 @@
+package formatter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/anurag-bit/goetl/pkg/processor"
+)
+
+func TestCSVIDsAcrossShards(t *testing.T) {
+	chunks := parquetTestChunks(7)
+	for i := range chunks {
+		chunks[i].Index = i + 1
+	}
+	path := filepath.Join(t.TempDir(), "data.csv")
+	files := writeSharded(t, path, "csv", ShardOptions{Records: 3}, chunks)
+	var ids []string
+	for _, f := range files[:len(files)-1] {
+		data, err := os.Open(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, err := csv.NewReader(data).ReadAll()
+		data.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", f, err)
+		}
+		for _, r := range records[1:] {
+			ids = append(ids, r[0])
+		}
+	}
+	if len(ids) != len(chunks) {
+		t.Fatalf("%d rows, want %d", len(ids), len(chunks))
+	}
+	for i, id := range ids {
+		if id != strconv.Itoa(i+1) {
+			t.Errorf("row %d has id %s, want %d", i, id, i+1)
+		}
+	}
+}
+
+func TestCSVIDWithoutIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv")
+	chunks := []processor.Chunk{{Text: "a"}, {Text: "b, \"quoted\"\nline"}}
+	if err := FormatChunksToCSV(chunks, path, CSVOptions{Columns: []string{"id", "text"}}); err != nil {
+		t.Fatalf("FormatChunksToCSV: %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"id", "text"}, {"1", "a"}, {"2", chunks[1].Text}}
+	if len(records) != len(want) {
+		t.Fatalf("records = %q, want %q", records, want)
+	}
+	for i := range want {
+		if records[i][0] != want[i][0] || records[i][1] != want[i][1] {
+			t.Errorf("record %d = %q, want %q", i, records[i], want[i])
+		}
+	}
+}
+
+func TestCSVQuoting(t *testing.T) {
+	texts := []string{"a,b", "a;b", "a\tb", `say "hi"`, "x\r\ny", "x\ny", " lead", ""}
+	var chunks []processor.Chunk
+	for _, text := range texts {
+		chunks = append(chunks, processor.Chunk{Text: text})
+	}
+	for _, tc := range []struct {
+		name      string
+		delimiter rune
+		want      string
+	}{
+		{"comma", 0, "id,text\n1,\"a,b\"\n2,a;b\n3,a\tb\n4,\"say \"\"hi\"\"\"\n5,\"x\r\ny\"\n6,\"x\ny\"\n7,\" lead\"\n8,\n"},
+		{"semicolon", ';', "id;text\n1;a,b\n2;\"a;b\"\n3;a\tb\n4;\"say \"\"hi\"\"\"\n5;\"x\r\ny\"\n6;\"x\ny\"\n7;\" lead\"\n8;\n"},
+		{"tab", '\t', "id\ttext\n1\ta,b\n2\ta;b\n3\t\"a\tb\"\n4\t\"say \"\"hi\"\"\"\n5\t\"x\r\ny\"\n6\t\"x\ny\"\n7\t\" lead\"\n8\t\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewCSVWriter(&buf, CSVOptions{Columns: []string{"id", "text"}, Delimiter: tc.delimiter})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range chunks {
+				if err := w.Write(c); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tc.want {
+				t.Errorf("output\n%q\nwant\n%q", buf.String(), tc.want)
+			}
+
+			// A reader with the same delimiter gets every text back; Go's
+			// reader turns the quoted CRLF into LF.
+			r := csv.NewReader(&buf)
+			if tc.delimiter != 0 {
+				r.Comma = tc.delimiter
+			}
+			records, err := r.ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, text := range texts {
+				if want := strings.ReplaceAll(text, "\r\n", "\n"); records[i+1][1] != want {
+					t.Errorf("row %d text = %q, want %q", i+1, records[i+1][1], want)
+				}
+			}
+		})
+	}
+}
+
+func TestCSVBOM(t *testing.T) {
+	for _, bom := range []bool{false, true} {
+		path := filepath.Join(t.TempDir(), "data.csv")
+		if err := FormatChunksToCSV([]processor.Chunk{{Text: "\ufeffstarts with a BOM"}}, path, CSVOptions{Columns: []string{"text"}, BOM: bom}); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "text\n\ufeffstarts with a BOM\n"
+		if bom {
+			want = "\ufeff" + want
+		}
+		if string(data) != want {
+			t.Errorf("BOM %v: file %q, want %q", bom, data, want)
+		}
+	}
+}
+
+func TestParseDelimiter(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want rune
+	}{
+		{"", ','}, {",", ','}, {";", ';'}, {"|", '|'}, {`\t`, '\t'}, {"tab", '\t'}, {"\t", '\t'}, {"§", '§'},
+		{`"`, 0}, {"\n", 0}, {"\r", 0}, {";;", 0}, {"ab", 0}, {"TAB", 0}, {"\xff", 0},
+	} {
+		got, err := ParseDelimiter(tc.in)
+		if got != tc.want || (err == nil) != (tc.want != 0) {
+			t.Errorf("ParseDelimiter(%q) = %q, %v; want %q", tc.in, got, err, tc.want)
+		}
+	}
+}
+
+func TestParseCSVColumns(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want []string
+	}{
+		{"", []string{"id", "source", "page", "text", "tokens", "hash"}},
+		{" ID , Text ", []string{"id", "text"}},
+		{"section,language,hash", []string{"section", "language", "hash"}},
+		{"id,body", nil},
+		{"id,,text", nil},
+	} {
+		got, err := ParseCSVColumns(tc.in)
+		if !reflect.DeepEqual(got, tc.want) || (err == nil) != (tc.want != nil) {
+			t.Errorf("ParseCSVColumns(%q) = %q, %v", tc.in, got, err)
+		}
+	}
+	if _, err := NewCSVWriter(&bytes.Buffer{}, CSVOptions{Columns: []string{"id", "body"}}); err == nil {
+		t.Error("NewCSVWriter accepted an unknown column")
+	}
+}
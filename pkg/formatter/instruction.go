@@ -0,0 +1,133 @@
+// formatter/instruction.go
+package formatter
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/anurag-bit/goetl/pkg/processor"
+)
+
+// DefaultInstruction is the instruction template used when none is given.
+const DefaultInstruction = "Please summarize the following text chunk #{{.Index}}."
+
+// InstructionData is what instruction templates can reference, e.g.
+// "Summarize section {{.Section}} of {{.File}}:".
+type InstructionData struct {
+	// Index is the 1-based number of the chunk in the run (see
+	// processor.Chunk.Index), or else in the output file.
+	Index int
+	Text  string
+	// Source is the input path and File its base name.
+	Source   string
+	File     string
+	Page     int
+	Section  string
+	Language string
+	Caption  string
+	Symbol   string
+	Tokens   int
+	Table    bool
+}
+
+// Instructions renders the instruction of every sample from one of
+// several templates, picked at random with a fixed seed so that runs are
+// reproducible.
+type Instructions struct {
+	templates []*template.Template
+	rng       *rand.Rand
+}
+
+// templateSeparator is the line between templates in a template file.
+const templateSeparator = "---"
+
+// NewInstructions parses templates, which use text/template syntax. For
+// compatibility a template without actions has every %d replaced by the
+// chunk index. Templates are checked against an empty chunk, so a
+// reference to an unknown field fails here rather than mid-run.
+func NewInstructions(templates []string, seed int64) (*Instructions, error) {
+	if len(templates) == 0 {
+		return nil, fmt.Errorf("no instruction templates")
+	}
+	in := &Instructions{rng: rand.New(rand.NewSource(seed))}
+	for i, text := range templates {
+		if !strings.Contains(text, "{{") {
+			text = strings.ReplaceAll(text, "%d", "{{.Index}}")
+			text = strings.ReplaceAll(text, "%%", "%")
+		}
+		tmpl, err := template.New(fmt.Sprintf("instruction %d", i+1)).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse instruction template: %v", err)
+		}
+		if err := tmpl.Execute(&bytes.Buffer{}, InstructionData{}); err != nil {
+			return nil, fmt.Errorf("failed to render instruction template: %v", err)
+		}
+		in.templates = append(in.templates, tmpl)
+	}
+	return in, nil
+}
+
+// LoadInstructions reads the templates of a file, separated by lines
+// holding only "---". Leading and trailing blank lines of each template
+// are dropped.
+func LoadInstructions(path string, seed int64) (*Instructions, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read instruction templates: %v", err)
+	}
+	var templates, lines []string
+	flush := func() {
+		if text := strings.Trim(strings.Join(lines, "\n"), "\n"); strings.TrimSpace(text) != "" {
+			templates = append(templates, text)
+		}
+		lines = nil
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		if strings.TrimSpace(line) == templateSeparator {
+			flush()
+			continue
+		}
+		lines = append(lines, line)
+	}
+	flush()
+	return NewInstructions(templates, seed)
+}
+
+// Render returns the instruction for the chunk with the given 1-based
+// index, unless the chunk has its own Index.
+func (in *Instructions) Render(index int, chunk processor.Chunk) (string, error) {
+	if chunk.Index > 0 {
+		index = chunk.Index
+	}
+	tmpl := in.templates[0]
+	if len(in.templates) > 1 {
+		tmpl = in.templates[in.rng.Intn(len(in.templates))]
+	}
+	file := ""
+	if chunk.Source != "" {
+		file = filepath.Base(chunk.Source)
+	}
+	var b strings.Builder
+	err := tmpl.Execute(&b, InstructionData{
+		Index:    index,
+		Text:     chunk.Text,
+		Source:   chunk.Source,
+		File:     file,
+		Page:     chunk.Page,
+		Section:  chunk.Section,
+		Language: chunk.Language,
+		Caption:  chunk.Caption,
+		Symbol:   chunk.Symbol,
+		Tokens:   chunk.Tokens,
+		Table:    chunk.Table,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render instruction template: %v", err)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,32 @@
+package formatter
+
+import (
+	"testing"
+
+	"github.com/anurag-bit/goetl/pkg/processor"
+)
+
+func TestRenderIndex(t *testing.T) {
+	for _, tc := range []struct {
+		template string
+		index    int
+		chunk    processor.Chunk
+		want     string
+	}{
+		{"chunk {{.Index}} of {{.File}}", 3, processor.Chunk{Source: "docs/a.md"}, "chunk 3 of a.md"},
+		{"chunk {{.Index}} of {{.File}}", 1, processor.Chunk{Source: "docs/b.md", Index: 42}, "chunk 42 of b.md"},
+		{"chunk #%d, 100%%", 1, processor.Chunk{Index: 42}, "chunk #42, 100%"},
+	} {
+		in, err := NewInstructions([]string{tc.template}, 0)
+		if err != nil {
+			t.Fatalf("NewInstructions(%q): %v", tc.template, err)
+		}
+		got, err := in.Render(tc.index, tc.chunk)
+		if err != nil {
+			t.Fatalf("Render: %v", err)
+		}
+		if got != tc.want {
+			t.Errorf("Render(%d, %+v) = %q, want %q", tc.index, tc.chunk, got, tc.want)
+		}
+	}
+}
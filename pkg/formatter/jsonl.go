@@ -3,61 +3,228 @@
 package formatter
 
 import (
+	"bufio"
 	"encoding/json"
-	"fmt"
+	"io"
 	"os"
+
+	"github.com/anurag-bit/goetl/pkg/processor"
 )
 
 type InstructionSample struct {
 	Instruction string `json:"instruction"`
 	Input       string `json:"input"`
 	Output      string `json:"output"`
+	// History holds the earlier turns of a multi-turn sample, alternating
+	// between user and assistant.
+	History []processor.Turn `json:"history,omitempty"`
+	Meta    *ChunkMeta       `json:"meta,omitempty"`
+}
+
+// ChunkMeta carries the provenance of the chunk behind a sample.
+type ChunkMeta struct {
+	Source             string  `json:"source,omitempty"`
+	Tokens             int     `json:"tokens,omitempty"`
+	Language           string  `json:"language,omitempty"`
+	LanguageConfidence float64 `json:"language_confidence,omitempty"`
+	Table              bool    `json:"table,omitempty"`
+	Caption            string  `json:"caption,omitempty"`
+	Symbol             string  `json:"symbol,omitempty"`
+	Page               int     `json:"page,omitempty"`
+	Section            string  `json:"section,omitempty"`
 }
 
 // FormatToJSONL writes chunked data to a JSONL file with instruction structure
 // FormatToJSONL formats a slice of text chunks into a JSONL (JSON Lines) file.
 // Each chunk is wrapped in an InstructionSample structure that includes:
-// - An instruction string formatted with the chunk's index
+// - An instruction rendered from the template with the chunk's index and text
 // - An empty input field
 // - The chunk content as output
 //
 // Parameters:
 //   - chunks: A slice of strings to be formatted as individual JSONL entries
 //   - outputPath: Path where the JSONL file will be written
-//   - instructionTemplate: A format string where %d will be replaced with the chunk index (1-based)
+//   - instructionTemplate: A text/template (see InstructionData), or a format string where %d is replaced with the chunk index (1-based)
 //
 // Returns:
-//   - error: If file creation, JSON marshaling, or file writing operations fail
+//   - error: If the template is invalid, or file creation, JSON marshaling, or file writing operations fail
 //
 // The function writes each JSON object on a separate line in the output file.
 func FormatToJSONL(chunks []string, outputPath string, instructionTemplate string) error {
+	texts := make([]processor.Chunk, len(chunks))
+	for i, chunk := range chunks {
+		texts[i] = processor.Chunk{Text: chunk}
+	}
+	instructions, err := NewInstructions([]string{instructionTemplate}, 0)
+	if err != nil {
+		return err
+	}
+	return writeSamples(texts, outputPath, instructions, SampleFormat{Schema: SchemaAlpaca}, false)
+}
+
+// FormatChunksToJSONL works like FormatToJSONL but also writes each chunk's
+// source, token count, detected language, table caption, code symbol, page
+// and section into the sample's meta field.
+func FormatChunksToJSONL(chunks []processor.Chunk, outputPath string, instructionTemplate string) error {
+	instructions, err := NewInstructions([]string{instructionTemplate}, 0)
+	if err != nil {
+		return err
+	}
+	return FormatChunksToSchema(chunks, outputPath, instructions, SampleFormat{Schema: SchemaAlpaca})
+}
+
+// FormatChunksToSchema works like FormatChunksToJSONL but renders the
+// instructions from instructions and writes the samples in the layout and
+// with the field names of format.
+func FormatChunksToSchema(chunks []processor.Chunk, outputPath string, instructions *Instructions, format SampleFormat) error {
+	return writeSamples(chunks, outputPath, instructions, format, true)
+}
+
+// writeSamples writes one sample per chunk, with the chunk's provenance
+// in meta if withMeta is set.
+func writeSamples(chunks []processor.Chunk, outputPath string, instructions *Instructions, format SampleFormat, withMeta bool) error {
 	file, err := os.Create(outputPath)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	for i, chunk := range chunks {
-		sample := InstructionSample{
-			Instruction: fmt.Sprintf(instructionTemplate, i+1),
-			Input:       "",
-			Output:      chunk,
-		}
-
-		jsonLine, err := json.Marshal(sample)
-		if err != nil {
+	buf := bufio.NewWriter(file)
+	w, err := NewSampleWriter(buf, instructions, format, withMeta)
+	if err != nil {
+		return err
+	}
+	for _, chunk := range chunks {
+		if err := w.Write(chunk); err != nil {
 			return err
 		}
+	}
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// SampleWriter streams chunks as JSONL samples, one line per sample.
+type SampleWriter struct {
+	w            io.Writer
+	instructions *Instructions
+	format       SampleFormat
+	withMeta     bool
+	index        int
+}
 
-		_, err = file.Write(jsonLine)
+// NewSampleWriter returns a SampleWriter that renders instructions and
+// writes samples in the layout of format to w, with each chunk's
+// provenance in meta if withMeta is set.
+func NewSampleWriter(w io.Writer, instructions *Instructions, format SampleFormat, withMeta bool) (*SampleWriter, error) {
+	if err := format.Validate(); err != nil {
+		return nil, err
+	}
+	return &SampleWriter{w: w, instructions: instructions, format: format, withMeta: withMeta}, nil
+}
+
+// Write adds the samples of one chunk: the chunk itself, or its generated
+// samples if it has any.
+func (sw *SampleWriter) Write(chunk processor.Chunk) error {
+	sw.index++
+	instruction, err := sw.instructions.Render(sw.index, chunk)
+	if err != nil {
+		return err
+	}
+	var meta *ChunkMeta
+	if sw.withMeta {
+		meta = &ChunkMeta{
+			Source:             chunk.Source,
+			Tokens:             chunk.Tokens,
+			Language:           chunk.Language,
+			LanguageConfidence: chunk.LanguageConfidence,
+			Table:              chunk.Table,
+			Caption:            chunk.Caption,
+			Symbol:             chunk.Symbol,
+			Page:               chunk.Page,
+			Section:            chunk.Section,
+		}
+	}
+	samples := []InstructionSample{{
+		Instruction: instruction,
+		Input:       "",
+		Output:      chunk.Text,
+		Meta:        meta,
+	}}
+	if len(chunk.Generated) > 0 {
+		samples = samples[:0]
+		for _, g := range chunk.Generated {
+			samples = append(samples, generatedSample(g, instruction, meta))
+		}
+	}
+	for _, sample := range samples {
+		line, err := sw.format.Marshal(sample)
 		if err != nil {
 			return err
 		}
-		_, err = file.Write([]byte("\n"))
-		if err != nil {
+		if _, err := sw.w.Write(append(line, '\n')); err != nil {
 			return err
 		}
 	}
+	return nil
+}
 
+// Close does nothing; samples are written as they come. It does not close
+// the underlying writer.
+func (sw *SampleWriter) Close() error {
+	return nil
+}
+
+// generatedSample turns a generated sample into an instruction sample whose
+// last exchange is the instruction and output. An empty first question is
+// filled with instruction.
+func generatedSample(g processor.GeneratedSample, instruction string, meta *ChunkMeta) InstructionSample {
+	turns := append([]processor.Turn(nil), g.Turns...)
+	if len(turns) > 0 && turns[0].Content == "" {
+		turns[0].Content = instruction
+	}
+	sample := InstructionSample{Input: g.Context, Meta: meta}
+	if n := len(turns); n >= 2 {
+		sample.Instruction, sample.Output = turns[n-2].Content, turns[n-1].Content
+		sample.History = turns[:n-2]
+	}
+	return sample
+}
+
+// PackedSample is one line of packed JSONL output.
+type PackedSample struct {
+	InputIDs   []int    `json:"input_ids"`
+	SegmentIDs []int    `json:"segment_ids,omitempty"`
+	Sources    []string `json:"sources,omitempty"`
+}
+
+// FormatPackedToJSONL writes one packed sequence per line, with its
+// segment IDs if they were recorded and the documents it contains.
+func FormatPackedToJSONL(seqs []processor.PackedSequence, outputPath string) error {
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	if err := WritePackedJSONL(w, seqs); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// WritePackedJSONL writes the lines of FormatPackedToJSONL to w.
+func WritePackedJSONL(w io.Writer, seqs []processor.PackedSequence) error {
+	enc := json.NewEncoder(w)
+	for _, seq := range seqs {
+		if err := enc.Encode(PackedSample{InputIDs: seq.Tokens, SegmentIDs: seq.Segments, Sources: seq.Sources}); err != nil {
+			return err
+		}
+	}
 	return nil
 }
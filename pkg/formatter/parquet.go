@@ -0,0 +1,426 @@
+// formatter/parquet.go
+package formatter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/anurag-bit/goetl/pkg/processor"
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+)
+
+// ParquetCodec is the compression of Parquet pages.
+type ParquetCodec string
+
+const (
+	ParquetSnappy ParquetCodec = "snappy"
+	ParquetZstd   ParquetCodec = "zstd"
+	ParquetNone   ParquetCodec = "none"
+)
+
+// ParseParquetCodec validates a -parquetcodec flag or API value; empty means snappy.
+func ParseParquetCodec(s string) (ParquetCodec, error) {
+	switch c := ParquetCodec(strings.ToLower(strings.TrimSpace(s))); c {
+	case "":
+		return ParquetSnappy, nil
+	case ParquetSnappy, ParquetZstd, ParquetNone:
+		return c, nil
+	default:
+		return "", fmt.Errorf("unknown parquet codec %q (want snappy, zstd or none)", s)
+	}
+}
+
+// ParquetOptions configures FormatChunksToParquet.
+type ParquetOptions struct {
+	Codec ParquetCodec
+	// RowGroupSize is the number of rows per row group, which bounds the
+	// memory used while writing; it defaults to DefaultRowGroupSize.
+	RowGroupSize int
+	// PageSize is the uncompressed size at which a column's data page is
+	// closed; it defaults to 1 MiB.
+	PageSize int
+}
+
+// DefaultRowGroupSize is the default number of rows per Parquet row group.
+const DefaultRowGroupSize = 65536
+
+// Parquet physical types, repetitions, encodings and codecs (parquet.thrift).
+const (
+	parquetBoolean   = 0
+	parquetInt32     = 1
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetRequired = 0
+	parquetOptional = 1
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecUncompressed = 0
+	codecSnappy       = 1
+	codecZstd         = 6
+
+	convertedUTF8 = 0
+	pageData      = 0
+)
+
+// parquetMagic starts and ends every Parquet file.
+const parquetMagic = "PAR1"
+
+// parquetColumn buffers the values of one column of the current row group.
+type parquetColumn struct {
+	name     string
+	typ      int32
+	optional bool
+	utf8     bool
+
+	// The open page: PLAIN values, definition levels and value count.
+	values bytes.Buffer
+	bits   []bool
+	defs   []bool
+	count  int
+
+	// The closed pages of the row group.
+	pages        bytes.Buffer
+	chunkValues  int64
+	uncompressed int64
+}
+
+// chunkColumns is the schema of FormatChunksToParquet. Metadata that does
+// not apply to a chunk is null.
+func chunkColumns() []*parquetColumn {
+	return []*parquetColumn{
+		{name: "text", typ: parquetByteArray, utf8: true},
+		{name: "source", typ: parquetByteArray, utf8: true},
+		{name: "tokens", typ: parquetInt32},
+		{name: "language", typ: parquetByteArray, utf8: true, optional: true},
+		{name: "language_confidence", typ: parquetDouble},
+		{name: "table", typ: parquetBoolean},
+		{name: "caption", typ: parquetByteArray, utf8: true, optional: true},
+		{name: "symbol", typ: parquetByteArray, utf8: true, optional: true},
+		{name: "page", typ: parquetInt32, optional: true},
+		{name: "section", typ: parquetByteArray, utf8: true, optional: true},
+	}
+}
+
+// append adds a value, or a null if the column is optional and null is set.
+func (c *parquetColumn) append(v interface{}, null bool) {
+	c.count++
+	if c.optional {
+		c.defs = append(c.defs, !null)
+		if null {
+			return
+		}
+	}
+	var b [8]byte
+	switch x := v.(type) {
+	case string:
+		binary.LittleEndian.PutUint32(b[:4], uint32(len(x)))
+		c.values.Write(b[:4])
+		c.values.WriteString(x)
+	case int32:
+		binary.LittleEndian.PutUint32(b[:4], uint32(x))
+		c.values.Write(b[:4])
+	case float64:
+		binary.LittleEndian.PutUint64(b[:], math.Float64bits(x))
+		c.values.Write(b[:])
+	case bool:
+		c.bits = append(c.bits, x)
+	}
+}
+
+// size estimates the uncompressed size of the open page.
+func (c *parquetColumn) size() int {
+	return c.values.Len() + len(c.bits)/8 + len(c.defs)/8
+}
+
+// ParquetWriter streams chunks into a Parquet file, one row group of
+// RowGroupSize rows at a time.
+type ParquetWriter struct {
+	w       *bufio.Writer
+	offset  int64
+	opts    ParquetOptions
+	codec   int32
+	zstd    *zstd.Encoder
+	columns []*parquetColumn
+	rows    int
+	total   int64
+	groups  *thriftWriter
+	nGroups int
+}
+
+// NewParquetWriter starts a Parquet file of chunks on w.
+func NewParquetWriter(w io.Writer, opts ParquetOptions) (*ParquetWriter, error) {
+	if opts.RowGroupSize <= 0 {
+		opts.RowGroupSize = DefaultRowGroupSize
+	}
+	if opts.PageSize <= 0 {
+		opts.PageSize = 1 << 20
+	}
+	pw := &ParquetWriter{w: bufio.NewWriter(w), opts: opts, columns: chunkColumns(), groups: newThriftWriter()}
+	switch opts.Codec {
+	case ParquetSnappy, "":
+		pw.codec = codecSnappy
+	case ParquetZstd:
+		enc, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, err
+		}
+		pw.codec, pw.zstd = codecZstd, enc
+	case ParquetNone:
+		pw.codec = codecUncompressed
+	default:
+		return nil, fmt.Errorf("unknown parquet codec %q", opts.Codec)
+	}
+	if err := pw.write([]byte(parquetMagic)); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+func (pw *ParquetWriter) write(b []byte) error {
+	n, err := pw.w.Write(b)
+	pw.offset += int64(n)
+	return err
+}
+
+// Write adds one chunk as a row.
+func (pw *ParquetWriter) Write(c processor.Chunk) error {
+	values := []struct {
+		v    interface{}
+		null bool
+	}{
+		{c.Text, false},
+		{c.Source, false},
+		{int32(c.Tokens), false},
+		{c.Language, c.Language == ""},
+		{c.LanguageConfidence, false},
+		{c.Table, false},
+		{c.Caption, c.Caption == ""},
+		{c.Symbol, c.Symbol == ""},
+		{int32(c.Page), c.Page == 0},
+		{c.Section, c.Section == ""},
+	}
+	for i, col := range pw.columns {
+		col.append(values[i].v, values[i].null)
+		if col.size() >= pw.opts.PageSize {
+			pw.closePage(col)
+		}
+	}
+	pw.rows++
+	if pw.rows >= pw.opts.RowGroupSize {
+		return pw.flushRowGroup()
+	}
+	return nil
+}
+
+// Size returns the bytes written so far plus those of the pages held for
+// the current row group, before compression of the open pages.
+func (pw *ParquetWriter) Size() int64 {
+	n := pw.offset
+	for _, col := range pw.columns {
+		n += int64(col.pages.Len() + col.size())
+	}
+	return n
+}
+
+// closePage compresses the open page of col and appends it to the row group.
+func (pw *ParquetWriter) closePage(col *parquetColumn) {
+	if col.count == 0 {
+		return
+	}
+	var body bytes.Buffer
+	if col.optional {
+		levels := rleBooleans(col.defs)
+		var n [4]byte
+		binary.LittleEndian.PutUint32(n[:], uint32(len(levels)))
+		body.Write(n[:])
+		body.Write(levels)
+	}
+	if col.typ == parquetBoolean {
+		packed := make([]byte, (len(col.bits)+7)/8)
+		for i, b := range col.bits {
+			if b {
+				packed[i/8] |= 1 << (i % 8)
+			}
+		}
+		body.Write(packed)
+	} else {
+		body.Write(col.values.Bytes())
+	}
+
+	data := body.Bytes()
+	switch pw.codec {
+	case codecSnappy:
+		data = s2.EncodeSnappy(nil, data)
+	case codecZstd:
+		data = pw.zstd.EncodeAll(data, nil)
+	}
+	h := newThriftWriter()
+	h.i32(1, pageData)
+	h.i32(2, int32(body.Len()))
+	h.i32(3, int32(len(data)))
+	h.begin(5)
+	h.i32(1, int32(col.count))
+	h.i32(2, encodingPlain)
+	h.i32(3, encodingRLE)
+	h.i32(4, encodingRLE)
+	h.end()
+	h.end()
+
+	col.pages.Write(h.Bytes())
+	col.pages.Write(data)
+	col.uncompressed += int64(len(h.Bytes()) + body.Len())
+	col.chunkValues += int64(col.count)
+	col.values.Reset()
+	col.bits, col.defs, col.count = col.bits[:0], col.defs[:0], 0
+}
+
+// flushRowGroup writes the buffered rows as a row group and records its
+// metadata for the footer.
+func (pw *ParquetWriter) flushRowGroup() error {
+	if pw.rows == 0 {
+		return nil
+	}
+	g := pw.groups
+	g.begin(0)
+	g.list(1, thriftStruct, len(pw.columns))
+	var total int64
+	for _, col := range pw.columns {
+		pw.closePage(col)
+		start := pw.offset
+		if err := pw.write(col.pages.Bytes()); err != nil {
+			return err
+		}
+		total += col.uncompressed
+
+		g.begin(0)
+		g.i64(2, start)
+		g.begin(3)
+		g.i32(1, col.typ)
+		g.list(2, thriftI32, 2)
+		g.listI32(encodingPlain)
+		g.listI32(encodingRLE)
+		g.list(3, thriftBinary, 1)
+		g.listBinary(col.name)
+		g.i32(4, pw.codec)
+		g.i64(5, col.chunkValues)
+		g.i64(6, col.uncompressed)
+		g.i64(7, int64(col.pages.Len()))
+		g.i64(9, start)
+		g.end()
+		g.end()
+
+		col.pages.Reset()
+		col.chunkValues, col.uncompressed = 0, 0
+	}
+	g.i64(2, total)
+	g.i64(3, int64(pw.rows))
+	g.end()
+
+	pw.nGroups++
+	pw.total += int64(pw.rows)
+	pw.rows = 0
+	return nil
+}
+
+// Close writes the last row group and the footer. It does not close the
+// underlying writer.
+func (pw *ParquetWriter) Close() error {
+	if err := pw.flushRowGroup(); err != nil {
+		return err
+	}
+	if pw.zstd != nil {
+		pw.zstd.Close()
+	}
+	m := newThriftWriter()
+	m.i32(1, 1)
+	m.list(2, thriftStruct, len(pw.columns)+1)
+	m.begin(0)
+	m.binary(4, "schema")
+	m.i32(5, int32(len(pw.columns)))
+	m.end()
+	for _, col := range pw.columns {
+		m.begin(0)
+		m.i32(1, col.typ)
+		repetition := int32(parquetRequired)
+		if col.optional {
+			repetition = parquetOptional
+		}
+		m.i32(3, repetition)
+		m.binary(4, col.name)
+		if col.utf8 {
+			m.i32(6, convertedUTF8)
+		}
+		m.end()
+	}
+	m.i64(3, pw.total)
+	m.list(4, thriftStruct, pw.nGroups)
+	m.buf.Write(pw.groups.Bytes())
+	m.binary(6, "goetl")
+	m.buf.WriteByte(0)
+
+	footer := m.Bytes()
+	var n [4]byte
+	binary.LittleEndian.PutUint32(n[:], uint32(len(footer)))
+	for _, b := range [][]byte{footer, n[:], []byte(parquetMagic)} {
+		if err := pw.write(b); err != nil {
+			return err
+		}
+	}
+	return pw.w.Flush()
+}
+
+// rleBooleans encodes definition levels of bit width 1 in the RLE/bit-packed
+// hybrid encoding, as one RLE run per run of equal levels.
+func rleBooleans(levels []bool) []byte {
+	var out []byte
+	var b [binary.MaxVarintLen64]byte
+	for i := 0; i < len(levels); {
+		j := i
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		out = append(out, b[:binary.PutUvarint(b[:], uint64(j-i)<<1)]...)
+		if levels[i] {
+			out = append(out, 1)
+		} else {
+			out = append(out, 0)
+		}
+		i = j
+	}
+	return out
+}
+
+// FormatChunksToParquet writes chunks and their metadata to a Parquet file
+// with the columns text, source, tokens, language, language_confidence,
+// table, caption, symbol, page and section.
+func FormatChunksToParquet(chunks []processor.Chunk, outputPath string, opts ParquetOptions) error {
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	pw, err := NewParquetWriter(file, opts)
+	if err != nil {
+		return err
+	}
+	for _, c := range chunks {
+		if err := pw.Write(c); err != nil {
+			return fmt.Errorf("failed to write parquet: %v", err)
+		}
+	}
+	if err := pw.Close(); err != nil {
+		return fmt.Errorf("failed to write parquet: %v", err)
+	}
+	return file.Close()
+}
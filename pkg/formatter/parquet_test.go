@@ -0,0 +1,371 @@
+package formatter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/anurag-bit/goetl/pkg/processor"
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+)
+
+// The reader below is written from the Parquet and Thrift compact protocol
+// specifications and shares no code with ParquetWriter, so that the writer
+// is checked against the format rather than against itself.
+
+// compactReader decodes Thrift compact protocol values into generic Go
+// values: structs become map[int16]interface{}, lists []interface{}.
+type compactReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *compactReader) next() byte {
+	c := r.b[r.pos]
+	r.pos++
+	return c
+}
+
+func (r *compactReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b[r.pos:])
+	if n <= 0 {
+		panic(fmt.Sprintf("bad varint at %d", r.pos))
+	}
+	r.pos += n
+	return v
+}
+
+func (r *compactReader) zigzag() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *compactReader) value(typ byte) interface{} {
+	switch typ {
+	case 1:
+		return true
+	case 2:
+		return false
+	case 3:
+		return int64(int8(r.next()))
+	case 4, 5, 6:
+		return r.zigzag()
+	case 7:
+		v := math.Float64frombits(binary.LittleEndian.Uint64(r.b[r.pos:]))
+		r.pos += 8
+		return v
+	case 8:
+		n := int(r.uvarint())
+		v := r.b[r.pos : r.pos+n]
+		r.pos += n
+		return v
+	case 9, 10:
+		h := r.next()
+		size, elem := int(h>>4), h&0x0f
+		if size == 15 {
+			size = int(r.uvarint())
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			if elem == 1 || elem == 2 {
+				list[i] = r.next() == 1
+			} else {
+				list[i] = r.value(elem)
+			}
+		}
+		return list
+	case 12:
+		return r.structure()
+	}
+	panic(fmt.Sprintf("unsupported thrift type %d at %d", typ, r.pos))
+}
+
+func (r *compactReader) structure() map[int16]interface{} {
+	fields := make(map[int16]interface{})
+	var last int16
+	for {
+		h := r.next()
+		if h == 0 {
+			return fields
+		}
+		id := last + int16(h>>4)
+		if h>>4 == 0 {
+			id = int16(r.zigzag())
+		}
+		fields[id] = r.value(h & 0x0f)
+		last = id
+	}
+}
+
+func field(s interface{}, ids ...int16) interface{} {
+	for _, id := range ids {
+		s = s.(map[int16]interface{})[id]
+	}
+	return s
+}
+
+func fieldInt(s interface{}, ids ...int16) int {
+	return int(field(s, ids...).(int64))
+}
+
+// decodeLevels decodes n bit-width-1 levels in the RLE/bit-packed hybrid encoding.
+func decodeLevels(b []byte, n int) []bool {
+	r := &compactReader{b: b}
+	var levels []bool
+	for len(levels) < n {
+		h := r.uvarint()
+		if h&1 == 0 {
+			v := r.next() == 1
+			for i := uint64(0); i < h>>1; i++ {
+				levels = append(levels, v)
+			}
+			continue
+		}
+		for i := uint64(0); i < h>>1; i++ {
+			c := r.next()
+			for bit := 0; bit < 8; bit++ {
+				levels = append(levels, c&(1<<bit) != 0)
+			}
+		}
+	}
+	return levels[:n]
+}
+
+// parquetFile is what readParquet found in a file.
+type parquetFile struct {
+	columns   []string
+	optional  []bool
+	values    [][]interface{} // per column, nil for null
+	rowGroups int
+	pages     int
+}
+
+func readParquet(t *testing.T, path string) parquetFile {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data[:4]) != "PAR1" || string(data[len(data)-4:]) != "PAR1" {
+		t.Fatalf("missing PAR1 magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	meta := (&compactReader{b: data[len(data)-8-footerLen : len(data)-8]}).structure()
+
+	var f parquetFile
+	schema := field(meta, 2).([]interface{})
+	if n := fieldInt(schema[0], 5); n != len(schema)-1 {
+		t.Fatalf("schema root has %d children, want %d", n, len(schema)-1)
+	}
+	types := make([]int, len(schema)-1)
+	for i, el := range schema[1:] {
+		f.columns = append(f.columns, string(field(el, 4).([]byte)))
+		f.optional = append(f.optional, fieldInt(el, 3) == 1)
+		types[i] = fieldInt(el, 1)
+	}
+	f.values = make([][]interface{}, len(f.columns))
+
+	dec, _ := zstd.NewReader(nil)
+	defer dec.Close()
+	rows := 0
+	for _, rg := range field(meta, 4).([]interface{}) {
+		f.rowGroups++
+		groupRows := fieldInt(rg, 3)
+		rows += groupRows
+		for i, cc := range field(rg, 1).([]interface{}) {
+			md := field(cc, 3)
+			if fieldInt(md, 1) != types[i] {
+				t.Fatalf("column %s has type %d in its chunk, %d in the schema", f.columns[i], fieldInt(md, 1), types[i])
+			}
+			if got := string(field(md, 3).([]interface{})[0].([]byte)); got != f.columns[i] {
+				t.Fatalf("column chunk %d is %q, want %q", i, got, f.columns[i])
+			}
+			if fieldInt(md, 5) != groupRows {
+				t.Fatalf("column %s has %d values in a row group of %d rows", f.columns[i], fieldInt(md, 5), groupRows)
+			}
+			codec := fieldInt(md, 4)
+			r := &compactReader{b: data, pos: fieldInt(md, 9)}
+			end := r.pos + fieldInt(md, 7)
+			values := 0
+			for r.pos < end {
+				header := r.structure()
+				size := fieldInt(header, 3)
+				page := data[r.pos : r.pos+size]
+				r.pos += size
+				f.pages++
+				switch codec {
+				case 0:
+				case 1:
+					if page, err = s2.Decode(nil, page); err != nil {
+						t.Fatalf("snappy: %v", err)
+					}
+				case 6:
+					if page, err = dec.DecodeAll(page, nil); err != nil {
+						t.Fatalf("zstd: %v", err)
+					}
+				default:
+					t.Fatalf("unexpected codec %d", codec)
+				}
+				if len(page) != fieldInt(header, 2) {
+					t.Fatalf("page is %d bytes uncompressed, header says %d", len(page), fieldInt(header, 2))
+				}
+				n := fieldInt(header, 5, 1)
+				values += n
+				defined := make([]bool, n)
+				for j := range defined {
+					defined[j] = true
+				}
+				if f.optional[i] {
+					l := int(binary.LittleEndian.Uint32(page))
+					defined = decodeLevels(page[4:4+l], n)
+					page = page[4+l:]
+				}
+				f.values[i] = append(f.values[i], plainValues(t, types[i], page, defined)...)
+			}
+			if r.pos != end || values != groupRows {
+				t.Fatalf("column %s: pages end at %d (want %d) with %d values (want %d)", f.columns[i], r.pos, end, values, groupRows)
+			}
+		}
+	}
+	if n := fieldInt(meta, 3); n != rows {
+		t.Fatalf("file has %d rows, row groups %d", n, rows)
+	}
+	return f
+}
+
+// plainValues decodes PLAIN values, with nil where a value is not defined.
+func plainValues(t *testing.T, typ int, b []byte, defined []bool) []interface{} {
+	t.Helper()
+	out := make([]interface{}, len(defined))
+	pos, bit := 0, 0
+	for i, ok := range defined {
+		if !ok {
+			continue
+		}
+		switch typ {
+		case 0: // BOOLEAN, bit-packed from the least significant bit
+			out[i] = b[bit/8]&(1<<(bit%8)) != 0
+			bit++
+		case 1: // INT32
+			out[i] = int32(binary.LittleEndian.Uint32(b[pos:]))
+			pos += 4
+		case 5: // DOUBLE
+			out[i] = math.Float64frombits(binary.LittleEndian.Uint64(b[pos:]))
+			pos += 8
+		case 6: // BYTE_ARRAY
+			n := int(binary.LittleEndian.Uint32(b[pos:]))
+			out[i] = string(b[pos+4 : pos+4+n])
+			pos += 4 + n
+		default:
+			t.Fatalf("unexpected physical type %d", typ)
+		}
+	}
+	if used := pos + (bit+7)/8; used != len(b) {
+		t.Fatalf("page holds %d bytes of values, decoded %d", len(b), used)
+	}
+	return out
+}
+
+func parquetTestChunks(n int) []processor.Chunk {
+	chunks := make([]processor.Chunk, n)
+	langs := []string{"", "en", "de"}
+	for i := range chunks {
+		c := processor.Chunk{
+			Text:               fmt.Sprintf("chunk %d: %s", i, strings.Repeat("lorem ipsum ", i%5+1)),
+			Tokens:             i * 3,
+			Source:             fmt.Sprintf("docs/doc%d.md", i/4),
+			Language:           langs[i%3],
+			LanguageConfidence: float64(i) / 10,
+			Table:              i%4 == 1,
+			Page:               i % 7,
+		}
+		if c.Table {
+			c.Caption = fmt.Sprintf("Table %d", i)
+		}
+		// Later row groups have no symbol at all.
+		if i%5 == 0 && i < 10 {
+			c.Symbol = fmt.Sprintf("Func%d", i)
+		}
+		if i%2 == 0 {
+			c.Section = "Section ü"
+		}
+		chunks[i] = c
+	}
+	return chunks
+}
+
+// parquetRow is the row FormatChunksToParquet should write for c.
+func parquetRow(c processor.Chunk) []interface{} {
+	optional := func(s string) interface{} {
+		if s == "" {
+			return nil
+		}
+		return s
+	}
+	var page interface{}
+	if c.Page != 0 {
+		page = int32(c.Page)
+	}
+	return []interface{}{c.Text, c.Source, int32(c.Tokens), optional(c.Language), c.LanguageConfidence,
+		c.Table, optional(c.Caption), optional(c.Symbol), page, optional(c.Section)}
+}
+
+func TestParquetRoundTrip(t *testing.T) {
+	chunks := parquetTestChunks(25)
+	wantColumns := []string{"text", "source", "tokens", "language", "language_confidence", "table", "caption", "symbol", "page", "section"}
+	for _, codec := range []ParquetCodec{ParquetSnappy, ParquetZstd, ParquetNone} {
+		for _, tc := range []struct {
+			name            string
+			rowGroup, page  int
+			groups, minPage int
+		}{
+			{"one group", 0, 0, 1, 10},
+			{"row groups", 4, 0, 7, 70},
+			{"page splits", 10, 64, 3, 40},
+		} {
+			t.Run(fmt.Sprintf("%s/%s", codec, tc.name), func(t *testing.T) {
+				path := filepath.Join(t.TempDir(), "data.parquet")
+				opts := ParquetOptions{Codec: codec, RowGroupSize: tc.rowGroup, PageSize: tc.page}
+				if err := FormatChunksToParquet(chunks, path, opts); err != nil {
+					t.Fatalf("FormatChunksToParquet: %v", err)
+				}
+				f := readParquet(t, path)
+				if !reflect.DeepEqual(f.columns, wantColumns) {
+					t.Fatalf("columns = %v, want %v", f.columns, wantColumns)
+				}
+				if f.rowGroups != tc.groups || f.pages < tc.minPage {
+					t.Errorf("%d row groups and %d pages, want %d and at least %d", f.rowGroups, f.pages, tc.groups, tc.minPage)
+				}
+				for i, c := range chunks {
+					want := parquetRow(c)
+					for j, col := range f.columns {
+						if got := f.values[j][i]; !reflect.DeepEqual(got, want[j]) {
+							t.Errorf("row %d %s = %#v, want %#v", i, col, got, want[j])
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestParquetEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty.parquet")
+	if err := FormatChunksToParquet(nil, path, ParquetOptions{}); err != nil {
+		t.Fatalf("FormatChunksToParquet: %v", err)
+	}
+	if f := readParquet(t, path); f.rowGroups != 0 || len(f.columns) != 10 {
+		t.Errorf("empty file has %d row groups and %d columns", f.rowGroups, len(f.columns))
+	}
+	data, _ := os.ReadFile(path)
+	if !bytes.HasPrefix(data, []byte("PAR1")) {
+		t.Error("missing magic")
+	}
+}
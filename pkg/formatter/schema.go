@@ -0,0 +1,246 @@
+// formatter/schema.go
+package formatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/anurag-bit/goetl/pkg/processor"
+)
+
+// Schema selects the JSON layout of instruction-tuning samples.
+type Schema string
+
+const (
+	// SchemaAlpaca writes {"instruction", "input", "output"}.
+	SchemaAlpaca Schema = "alpaca"
+	// SchemaShareGPT writes {"conversations": [{"from": "human", "value"}, {"from": "gpt", "value"}]}.
+	SchemaShareGPT Schema = "sharegpt"
+	// SchemaChat writes OpenAI chat {"messages": [{"role": "user", "content"}, {"role": "assistant", "content"}]}.
+	SchemaChat Schema = "chat"
+	// SchemaText writes {"text"} with the chunk only, for pretraining.
+	SchemaText Schema = "text"
+	// SchemaCompletion writes {"prompt", "completion"}.
+	SchemaCompletion Schema = "completion"
+)
+
+// schemaFields lists the names each schema writes, in output order. Keys
+// and role values alike can be renamed through SampleFormat.Fields.
+var schemaFields = map[Schema][]string{
+	SchemaAlpaca:     {"instruction", "input", "output", "history", "meta"},
+	SchemaShareGPT:   {"conversations", "from", "value", "human", "gpt", "meta"},
+	SchemaChat:       {"messages", "role", "content", "user", "assistant", "meta"},
+	SchemaText:       {"text", "meta"},
+	SchemaCompletion: {"prompt", "completion", "meta"},
+}
+
+// schemaGroups splits the names of schemaFields into the sets whose output
+// names must stay distinct: the keys of one JSON object, and the role values
+// of a conversation.
+var schemaGroups = map[Schema][][]string{
+	SchemaAlpaca:     {{"instruction", "input", "output", "history", "meta"}},
+	SchemaShareGPT:   {{"conversations", "meta"}, {"from", "value"}, {"human", "gpt"}},
+	SchemaChat:       {{"messages", "meta"}, {"role", "content"}, {"user", "assistant"}},
+	SchemaText:       {{"text", "meta"}},
+	SchemaCompletion: {{"prompt", "completion", "meta"}},
+}
+
+// ParseSchema validates a -schema flag or API value; empty means alpaca.
+func ParseSchema(name string) (Schema, error) {
+	s := Schema(strings.ToLower(strings.TrimSpace(name)))
+	if s == "" {
+		return SchemaAlpaca, nil
+	}
+	if _, ok := schemaFields[s]; !ok {
+		return "", fmt.Errorf("unknown schema %q (want alpaca, sharegpt, chat, text or completion)", name)
+	}
+	return s, nil
+}
+
+// ParseFieldNames parses a list of renames such as
+// "instruction=prompt,output=response" into a map for SampleFormat.Fields.
+func ParseFieldNames(spec string) (map[string]string, error) {
+	fields := make(map[string]string)
+	for _, part := range strings.Split(spec, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid field rename %q (want name=newname)", part)
+		}
+		fields[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return fields, nil
+}
+
+// SampleFormat turns instruction samples into JSON lines of a schema.
+type SampleFormat struct {
+	Schema Schema
+	// Fields renames the keys and role values of the schema; renaming a
+	// key to "" leaves it out (e.g. "meta" or "input").
+	Fields map[string]string
+}
+
+// Validate checks that the schema is known, that Fields only renames names
+// the schema writes and that no two keys of an object, or two roles, end up
+// with the same name.
+func (f SampleFormat) Validate() error {
+	names, ok := schemaFields[f.schema()]
+	if !ok {
+		return fmt.Errorf("unknown schema %q", f.Schema)
+	}
+	for name := range f.Fields {
+		found := false
+		for _, n := range names {
+			found = found || n == name
+		}
+		if !found {
+			return fmt.Errorf("schema %s has no field %q (fields: %s)", f.schema(), name, strings.Join(names, ", "))
+		}
+	}
+	for _, group := range schemaGroups[f.schema()] {
+		seen := make(map[string]string)
+		for _, field := range group {
+			name := f.name(field)
+			if other, ok := seen[name]; ok && name != "" {
+				return fmt.Errorf("schema %s fields %q and %q are both named %q", f.schema(), other, field, name)
+			}
+			seen[name] = field
+		}
+	}
+	return nil
+}
+
+func (f SampleFormat) schema() Schema {
+	if f.Schema == "" {
+		return SchemaAlpaca
+	}
+	return f.Schema
+}
+
+// name returns the output name of a schema field.
+func (f SampleFormat) name(field string) string {
+	if n, ok := f.Fields[field]; ok {
+		return n
+	}
+	return field
+}
+
+// Marshal encodes a sample as one JSON object in the field order of the
+// schema. The chunk or the generated answer is the sample's output; the
+// instruction and a non-empty input form the prompt. Earlier exchanges of
+// a multi-turn sample become Alpaca's "history" of [question, answer]
+// pairs, precede the prompt in conversations, and are joined with blank
+// lines into the prompt of completion and the text of text samples.
+func (f SampleFormat) Marshal(s InstructionSample) ([]byte, error) {
+	var turns []processor.Turn
+	turns = append(turns, s.History...)
+	turns = append(turns, processor.Turn{Role: "user", Content: s.Instruction}, processor.Turn{Role: "assistant", Content: s.Output})
+	if s.Input != "" {
+		turns[len(s.History)].Content += "\n\n" + s.Input
+	}
+	var obj orderedObject
+	switch f.schema() {
+	case SchemaAlpaca:
+		obj.add(f.name("instruction"), s.Instruction)
+		obj.add(f.name("input"), s.Input)
+		obj.add(f.name("output"), s.Output)
+		if len(s.History) > 0 {
+			var history [][2]string
+			for i := 0; i+1 < len(s.History); i += 2 {
+				history = append(history, [2]string{s.History[i].Content, s.History[i+1].Content})
+			}
+			obj.add(f.name("history"), history)
+		}
+	case SchemaShareGPT:
+		obj.add(f.name("conversations"), f.turns(turns, "from", "value", "human", "gpt"))
+	case SchemaChat:
+		obj.add(f.name("messages"), f.turns(turns, "role", "content", "user", "assistant"))
+	case SchemaText:
+		if len(s.History) > 0 {
+			obj.add(f.name("text"), joinTurns(turns))
+		} else {
+			obj.add(f.name("text"), s.Output)
+		}
+	case SchemaCompletion:
+		obj.add(f.name("prompt"), joinTurns(turns[:len(turns)-1]))
+		obj.add(f.name("completion"), s.Output)
+	default:
+		return nil, fmt.Errorf("unknown schema %q", f.Schema)
+	}
+	if s.Meta != nil {
+		obj.add(f.name("meta"), s.Meta)
+	}
+	return json.Marshal(obj)
+}
+
+// turns builds the messages of a conversation, naming the roles user and
+// assistant as the schema does.
+func (f SampleFormat) turns(turns []processor.Turn, roleKey, textKey, user, assistant string) []orderedObject {
+	out := make([]orderedObject, len(turns))
+	for i, t := range turns {
+		role := f.name(user)
+		if t.Role == "assistant" {
+			role = f.name(assistant)
+		}
+		out[i] = f.turn(roleKey, textKey, role, t.Content)
+	}
+	return out
+}
+
+// joinTurns joins the contents of turns with blank lines.
+func joinTurns(turns []processor.Turn) string {
+	parts := make([]string, len(turns))
+	for i, t := range turns {
+		parts[i] = t.Content
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// turn builds one message of a conversation.
+func (f SampleFormat) turn(roleKey, textKey, role, text string) orderedObject {
+	var t orderedObject
+	t.add(f.name(roleKey), role)
+	t.add(f.name(textKey), text)
+	return t
+}
+
+// orderedObject is a JSON object that keeps its keys in insertion order.
+type orderedObject struct {
+	keys   []string
+	values []interface{}
+}
+
+// add appends a key; empty keys are left out.
+func (o *orderedObject) add(key string, value interface{}) {
+	if key != "" {
+		o.keys = append(o.keys, key)
+		o.values = append(o.values, value)
+	}
+}
+
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(o.values[i])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
@@ -0,0 +1,114 @@
+package formatter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/anurag-bit/goetl/pkg/processor"
+)
+
+func TestSampleFormatMarshal(t *testing.T) {
+	single := InstructionSample{Instruction: "Summarize.", Output: "Text.", Meta: &ChunkMeta{Source: "a.md", Tokens: 2}}
+	multi := InstructionSample{
+		Instruction: "Q2",
+		Input:       "context",
+		Output:      "A2",
+		History:     []processor.Turn{{Role: "user", Content: "Q1"}, {Role: "assistant", Content: "A1"}},
+	}
+	const meta = `"meta":{"source":"a.md","tokens":2}`
+	for _, tc := range []struct {
+		name   string
+		format SampleFormat
+		sample InstructionSample
+		want   string
+	}{
+		{"alpaca", SampleFormat{Schema: SchemaAlpaca}, single,
+			`{"instruction":"Summarize.","input":"","output":"Text.",` + meta + `}`},
+		{"default schema", SampleFormat{}, single,
+			`{"instruction":"Summarize.","input":"","output":"Text.",` + meta + `}`},
+		{"sharegpt", SampleFormat{Schema: SchemaShareGPT}, single,
+			`{"conversations":[{"from":"human","value":"Summarize."},{"from":"gpt","value":"Text."}],` + meta + `}`},
+		{"chat", SampleFormat{Schema: SchemaChat}, single,
+			`{"messages":[{"role":"user","content":"Summarize."},{"role":"assistant","content":"Text."}],` + meta + `}`},
+		{"text", SampleFormat{Schema: SchemaText}, single,
+			`{"text":"Text.",` + meta + `}`},
+		{"completion", SampleFormat{Schema: SchemaCompletion}, single,
+			`{"prompt":"Summarize.","completion":"Text.",` + meta + `}`},
+
+		{"alpaca renamed", SampleFormat{Schema: SchemaAlpaca, Fields: map[string]string{"output": "response", "input": "", "meta": "source"}}, single,
+			`{"instruction":"Summarize.","response":"Text.","source":{"source":"a.md","tokens":2}}`},
+		{"alpaca swapped", SampleFormat{Fields: map[string]string{"instruction": "output", "output": "instruction"}}, single,
+			`{"output":"Summarize.","input":"","instruction":"Text.",` + meta + `}`},
+		{"chat renamed", SampleFormat{Schema: SchemaChat, Fields: map[string]string{"messages": "dialog", "assistant": "model", "content": "text", "meta": ""}}, single,
+			`{"dialog":[{"role":"user","text":"Summarize."},{"role":"model","text":"Text."}]}`},
+
+		// The input belongs to the current question, not the first turn of the history.
+		{"alpaca history", SampleFormat{}, multi,
+			`{"instruction":"Q2","input":"context","output":"A2","history":[["Q1","A1"]]}`},
+		{"sharegpt history", SampleFormat{Schema: SchemaShareGPT}, multi,
+			`{"conversations":[{"from":"human","value":"Q1"},{"from":"gpt","value":"A1"},{"from":"human","value":"Q2\n\ncontext"},{"from":"gpt","value":"A2"}]}`},
+		{"chat history", SampleFormat{Schema: SchemaChat}, multi,
+			`{"messages":[{"role":"user","content":"Q1"},{"role":"assistant","content":"A1"},{"role":"user","content":"Q2\n\ncontext"},{"role":"assistant","content":"A2"}]}`},
+		{"text history", SampleFormat{Schema: SchemaText}, multi,
+			`{"text":"Q1\n\nA1\n\nQ2\n\ncontext\n\nA2"}`},
+		{"completion history", SampleFormat{Schema: SchemaCompletion}, multi,
+			`{"prompt":"Q1\n\nA1\n\nQ2\n\ncontext","completion":"A2"}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.format.Validate(); err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+			got, err := tc.format.Marshal(tc.sample)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("Marshal =\n%s\nwant\n%s", got, tc.want)
+			}
+		})
+	}
+	if _, err := (SampleFormat{Schema: "xml"}).Marshal(single); err == nil {
+		t.Error("Marshal accepted an unknown schema")
+	}
+}
+
+func TestSampleFormatValidate(t *testing.T) {
+	for _, tc := range []struct {
+		format SampleFormat
+		err    string // empty if valid
+	}{
+		{SampleFormat{Schema: SchemaChat, Fields: map[string]string{"messages": "role"}}, ""},
+		{SampleFormat{Fields: map[string]string{"input": "", "meta": ""}}, ""},
+		{SampleFormat{Schema: "xml"}, "unknown schema"},
+		{SampleFormat{Fields: map[string]string{"messages": "chat"}}, `no field "messages"`},
+		{SampleFormat{Fields: map[string]string{"input": "output"}}, `named "output"`},
+		{SampleFormat{Fields: map[string]string{"instruction": "prompt", "input": "prompt"}}, `named "prompt"`},
+		{SampleFormat{Schema: SchemaText, Fields: map[string]string{"meta": "text"}}, `named "text"`},
+		{SampleFormat{Schema: SchemaChat, Fields: map[string]string{"role": "content"}}, `named "content"`},
+		{SampleFormat{Schema: SchemaChat, Fields: map[string]string{"user": "assistant"}}, `named "assistant"`},
+		{SampleFormat{Schema: SchemaShareGPT, Fields: map[string]string{"human": "x", "gpt": "x"}}, `named "x"`},
+	} {
+		err := tc.format.Validate()
+		if tc.err == "" && err != nil || tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("Validate(%s %v) = %v, want %q", tc.format.Schema, tc.format.Fields, err, tc.err)
+		}
+	}
+	if _, err := NewSampleWriter(&strings.Builder{}, nil, SampleFormat{Fields: map[string]string{"input": "output"}}, false); err == nil {
+		t.Error("NewSampleWriter accepted duplicate keys")
+	}
+}
+
+func TestParseFieldNames(t *testing.T) {
+	fields, err := ParseFieldNames(" output = response ,meta=,, instruction=prompt")
+	if err != nil || len(fields) != 3 || fields["output"] != "response" || fields["meta"] != "" || fields["instruction"] != "prompt" {
+		t.Errorf("ParseFieldNames = %v, %v", fields, err)
+	}
+	if _, err := ParseFieldNames("output"); err == nil {
+		t.Error("ParseFieldNames accepted a rename without =")
+	}
+	for in, want := range map[string]Schema{"": SchemaAlpaca, " ShareGPT ": SchemaShareGPT, "chat": SchemaChat, "xml": ""} {
+		if got, err := ParseSchema(in); got != want || (err == nil) != (want != "") {
+			t.Errorf("ParseSchema(%q) = %q, %v", in, got, err)
+		}
+	}
+}
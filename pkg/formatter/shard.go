@@ -0,0 +1,379 @@
+// formatter/shard.go
+package formatter
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/anurag-bit/goetl/pkg/processor"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is the stream compression of output files.
+type Compression string
+
+const (
+	CompressNone Compression = "none"
+	CompressGzip Compression = "gzip"
+	CompressZstd Compression = "zstd"
+)
+
+// ParseCompression validates a -compress flag or API value; empty means none.
+func ParseCompression(s string) (Compression, error) {
+	switch c := Compression(strings.ToLower(strings.TrimSpace(s))); c {
+	case "":
+		return CompressNone, nil
+	case CompressNone, CompressGzip, CompressZstd:
+		return c, nil
+	case "gz":
+		return CompressGzip, nil
+	case "zst":
+		return CompressZstd, nil
+	default:
+		return "", fmt.Errorf("unknown compression %q (want none, gzip or zstd)", s)
+	}
+}
+
+// Ext returns the file extension compressed files get.
+func (c Compression) Ext() string {
+	switch c {
+	case CompressGzip:
+		return ".gz"
+	case CompressZstd:
+		return ".zst"
+	}
+	return ""
+}
+
+// ShardOptions configures how output is split into shards. A shard is
+// closed once it holds Records chunks or Bytes of output, whichever comes
+// first; zero disables a limit.
+type ShardOptions struct {
+	Records     int
+	Bytes       int64
+	Compression Compression
+}
+
+// Sharded reports whether any shard limit is set.
+func (o ShardOptions) Sharded() bool {
+	return o.Records > 0 || o.Bytes > 0
+}
+
+// ParseByteSize parses a size such as "512MB", "2GiB", "64k" or "1000000".
+// Units are binary: 1 KB is 1024 bytes. Empty means 0.
+func ParseByteSize(s string) (int64, error) {
+	t := strings.ToUpper(strings.TrimSpace(s))
+	if t == "" {
+		return 0, nil
+	}
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"T", 1 << 40}} {
+		for _, suffix := range []string{unit.suffix + "IB", unit.suffix + "B", unit.suffix} {
+			if strings.HasSuffix(t, suffix) {
+				t, multiplier = strings.TrimSuffix(t, suffix), unit.size
+				break
+			}
+		}
+		if multiplier != 1 {
+			break
+		}
+	}
+	t = strings.TrimSpace(strings.TrimSuffix(t, "B"))
+	n, err := strconv.ParseFloat(t, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// ShardChunks divides chunks into consecutive shards of at most
+// opts.Records chunks, for formats that cannot be written one chunk at a
+// time. It returns at least one shard, which may be empty.
+func ShardChunks(chunks []processor.Chunk, opts ShardOptions) [][]processor.Chunk {
+	if opts.Records <= 0 {
+		return [][]processor.Chunk{chunks}
+	}
+	var shards [][]processor.Chunk
+	for len(chunks) > opts.Records {
+		shards = append(shards, chunks[:opts.Records])
+		chunks = chunks[opts.Records:]
+	}
+	return append(shards, chunks)
+}
+
+// ShardPath returns the path of shard i of n: data.jsonl becomes
+// data-00000-of-00042.jsonl.
+func ShardPath(outputPath string, i, n int) string {
+	ext := filepath.Ext(outputPath)
+	return fmt.Sprintf("%s-%05d-of-%05d%s", strings.TrimSuffix(outputPath, ext), i, n, ext)
+}
+
+// compressedFile compresses what is written to it into a file.
+type compressedFile struct {
+	file *os.File
+	buf  *bufio.Writer
+	zw   io.WriteCloser
+}
+
+// CreateCompressed creates path plus the extension of c and returns a
+// writer that compresses into it as it is written, along with the path.
+// Closing the writer flushes the compressor and closes the file.
+func CreateCompressed(path string, c Compression) (io.WriteCloser, string, error) {
+	path += c.Ext()
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, path, fmt.Errorf("failed to create output file: %v", err)
+	}
+	f := &compressedFile{file: file, buf: bufio.NewWriter(file)}
+	switch c {
+	case CompressNone, "":
+	case CompressGzip:
+		f.zw = gzip.NewWriter(f.buf)
+	case CompressZstd:
+		if f.zw, err = zstd.NewWriter(f.buf); err != nil {
+			file.Close()
+			return nil, path, fmt.Errorf("failed to compress output: %v", err)
+		}
+	default:
+		file.Close()
+		return nil, path, fmt.Errorf("unknown compression %q", c)
+	}
+	return f, path, nil
+}
+
+func (f *compressedFile) Write(p []byte) (int, error) {
+	if f.zw != nil {
+		return f.zw.Write(p)
+	}
+	return f.buf.Write(p)
+}
+
+func (f *compressedFile) Close() error {
+	var err error
+	if f.zw != nil {
+		err = f.zw.Close()
+	}
+	if ferr := f.buf.Flush(); err == nil {
+		err = ferr
+	}
+	if cerr := f.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// ChunkWriter writes chunks one at a time to a stream, as ParquetWriter,
+// SampleWriter and CSVWriter do. Close finishes the stream without closing
+// the underlying writer.
+type ChunkWriter interface {
+	Write(c processor.Chunk) error
+	Close() error
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ShardWriter streams chunks into a file, or into numbered shards listed
+// in a manifest, compressing them as they are written. The size of a
+// shard is what its ChunkWriter has written, before compression; a
+// ParquetWriter also counts the pages it holds for the current row group.
+type ShardWriter struct {
+	path   string
+	format string
+	opts   ShardOptions
+	open   func(w io.Writer) (ChunkWriter, error)
+
+	out     io.WriteCloser
+	counter *countingWriter
+	cw      ChunkWriter
+	files   []string
+	chunks  []int
+	err     error
+}
+
+// NewShardWriter returns a ShardWriter for outputPath that starts every
+// file with open.
+func NewShardWriter(outputPath, format string, opts ShardOptions, open func(w io.Writer) (ChunkWriter, error)) *ShardWriter {
+	return &ShardWriter{path: outputPath, format: strings.ToLower(format), opts: opts, open: open}
+}
+
+// size returns the bytes written to the current shard, or what the
+// ChunkWriter reports if it holds output back.
+func (s *ShardWriter) size() int64 {
+	if sized, ok := s.cw.(interface{ Size() int64 }); ok {
+		return sized.Size()
+	}
+	return s.counter.n
+}
+
+// openShard starts the next file. While writing, shards are named without
+// the shard count, which is only known at the end.
+func (s *ShardWriter) openShard() error {
+	path := s.path
+	if s.opts.Sharded() {
+		ext := filepath.Ext(s.path)
+		path = fmt.Sprintf("%s-%05d%s", strings.TrimSuffix(s.path, ext), len(s.files), ext)
+	}
+	out, path, err := CreateCompressed(path, s.opts.Compression)
+	if err != nil {
+		return err
+	}
+	s.files = append(s.files, path)
+	s.chunks = append(s.chunks, 0)
+	s.out, s.counter = out, &countingWriter{w: out}
+	if s.cw, err = s.open(s.counter); err != nil {
+		out.Close()
+		return err
+	}
+	return nil
+}
+
+// closeShard finishes the current file.
+func (s *ShardWriter) closeShard() error {
+	err := s.cw.Close()
+	if cerr := s.out.Close(); err == nil {
+		err = cerr
+	}
+	s.cw, s.out = nil, nil
+	return err
+}
+
+// Write adds a chunk, first moving on to a new shard if the current one is full.
+func (s *ShardWriter) Write(c processor.Chunk) error {
+	if s.err == nil {
+		s.err = s.write(c)
+	}
+	return s.err
+}
+
+func (s *ShardWriter) write(c processor.Chunk) error {
+	if s.cw != nil && s.opts.Sharded() {
+		n := s.chunks[len(s.chunks)-1]
+		if (s.opts.Records > 0 && n >= s.opts.Records) || (s.opts.Bytes > 0 && s.size() >= s.opts.Bytes) {
+			if err := s.closeShard(); err != nil {
+				return err
+			}
+		}
+	}
+	if s.cw == nil {
+		if err := s.openShard(); err != nil {
+			return err
+		}
+	}
+	s.chunks[len(s.chunks)-1]++
+	return s.cw.Write(c)
+}
+
+// Close finishes the last file, gives the shards their final names and
+// writes the manifest. It returns the paths written. After a failed Write
+// it only closes the current file and returns that error.
+func (s *ShardWriter) Close() ([]string, error) {
+	if s.err != nil {
+		if s.cw != nil {
+			s.closeShard()
+		}
+		return s.files, s.err
+	}
+	if s.cw == nil && len(s.files) == 0 {
+		if err := s.openShard(); err != nil {
+			return s.files, err
+		}
+	}
+	if s.cw != nil {
+		if err := s.closeShard(); err != nil {
+			return s.files, err
+		}
+	}
+	if !s.opts.Sharded() {
+		return s.files, nil
+	}
+	manifest := Manifest{Format: s.format, Compression: s.opts.Compression}
+	if manifest.Compression == "" {
+		manifest.Compression = CompressNone
+	}
+	for i, f := range s.files {
+		final := ShardPath(s.path, i, len(s.files)) + s.opts.Compression.Ext()
+		if err := os.Rename(f, final); err != nil {
+			return s.files, fmt.Errorf("failed to rename shard: %v", err)
+		}
+		s.files[i] = final
+		if err := manifest.AddShard([]string{final}, s.chunks[i]); err != nil {
+			return s.files, err
+		}
+	}
+	manifestPath := ManifestPath(s.path)
+	if err := WriteManifest(manifestPath, manifest); err != nil {
+		return s.files, err
+	}
+	return append(s.files, manifestPath), nil
+}
+
+// Manifest describes a sharded dataset; it is written next to the shards
+// as data.manifest.json.
+type Manifest struct {
+	Format      string          `json:"format"`
+	Compression Compression     `json:"compression"`
+	Chunks      int             `json:"chunks"`
+	Shards      []ManifestShard `json:"shards"`
+}
+
+// ManifestShard lists the files of one shard (several for bin output),
+// relative to the manifest, with the number of chunks and bytes on disk.
+type ManifestShard struct {
+	Files  []string `json:"files"`
+	Chunks int      `json:"chunks"`
+	Bytes  int64    `json:"bytes"`
+}
+
+// ManifestPath returns the manifest path of a sharded output:
+// data.jsonl becomes data.manifest.json.
+func ManifestPath(outputPath string) string {
+	return strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".manifest.json"
+}
+
+// AddShard records the files written for a shard of chunks.
+func (m *Manifest) AddShard(files []string, chunks int) error {
+	shard := ManifestShard{Chunks: chunks}
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return fmt.Errorf("failed to stat shard: %v", err)
+		}
+		shard.Files = append(shard.Files, filepath.Base(f))
+		shard.Bytes += info.Size()
+	}
+	m.Shards = append(m.Shards, shard)
+	m.Chunks += chunks
+	return nil
+}
+
+// WriteManifest writes m as indented JSON.
+func WriteManifest(path string, m Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %v", err)
+	}
+	return nil
+}
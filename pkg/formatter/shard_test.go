@@ -0,0 +1,254 @@
+package formatter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anurag-bit/goetl/pkg/processor"
+	"github.com/klauspost/compress/zstd"
+)
+
+// readCompressed returns the decompressed contents of path.
+func readCompressed(t *testing.T, path string, c Compression) []byte {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var r io.Reader = f
+	switch c {
+	case CompressGzip:
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("gzip: %v", err)
+		}
+		r = zr
+	case CompressZstd:
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			t.Fatalf("zstd: %v", err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+	return data
+}
+
+func readManifest(t *testing.T, path string) Manifest {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func writeSharded(t *testing.T, path, format string, opts ShardOptions, chunks []processor.Chunk) []string {
+	t.Helper()
+	w := NewShardWriter(path, format, opts, func(w io.Writer) (ChunkWriter, error) {
+		switch format {
+		case "jsonl":
+			instructions, err := NewInstructions([]string{DefaultInstruction}, 0)
+			if err != nil {
+				return nil, err
+			}
+			return NewSampleWriter(w, instructions, SampleFormat{Schema: SchemaAlpaca}, true)
+		case "csv":
+			return NewCSVWriter(w, CSVOptions{})
+		default:
+			return NewParquetWriter(w, ParquetOptions{})
+		}
+	})
+	for _, c := range chunks {
+		if err := w.Write(c); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	files, err := w.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return files
+}
+
+func TestShardWriterRecords(t *testing.T) {
+	for _, c := range []Compression{CompressNone, CompressGzip, CompressZstd} {
+		t.Run(string(c), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "data.jsonl")
+			files := writeSharded(t, path, "jsonl", ShardOptions{Records: 3, Compression: c}, parquetTestChunks(10))
+			if len(files) != 5 || files[4] != ManifestPath(path) {
+				t.Fatalf("files = %v, want 4 shards and the manifest", files)
+			}
+			m := readManifest(t, files[4])
+			if m.Format != "jsonl" || m.Compression != c || m.Chunks != 10 || len(m.Shards) != 4 {
+				t.Fatalf("manifest = %+v", m)
+			}
+			for i, want := range []int{3, 3, 3, 1} {
+				name := ShardPath(path, i, 4) + c.Ext()
+				if files[i] != name || m.Shards[i].Files[0] != filepath.Base(name) || m.Shards[i].Chunks != want {
+					t.Errorf("shard %d: file %s, manifest %+v; want %s with %d chunks", i, files[i], m.Shards[i], name, want)
+				}
+				info, err := os.Stat(name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if m.Shards[i].Bytes != info.Size() {
+					t.Errorf("shard %d: manifest has %d bytes, file %d", i, m.Shards[i].Bytes, info.Size())
+				}
+				if lines := bytes.Count(readCompressed(t, name, c), []byte("\n")); lines != want {
+					t.Errorf("shard %d holds %d lines, want %d", i, lines, want)
+				}
+			}
+		})
+	}
+}
+
+func TestShardWriterBytes(t *testing.T) {
+	const limit = 200
+	chunks := parquetTestChunks(20)
+	for _, c := range []Compression{CompressNone, CompressGzip, CompressZstd} {
+		t.Run(string(c), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "data.csv")
+			files := writeSharded(t, path, "csv", ShardOptions{Bytes: limit, Compression: c}, chunks)
+			shards := files[:len(files)-1]
+			if len(shards) < 3 {
+				t.Fatalf("%d shards, want several", len(shards))
+			}
+			var rows []string
+			for i, f := range shards {
+				data := readCompressed(t, f, c)
+				lines := strings.SplitAfter(strings.TrimSuffix(string(data), "\n"), "\n")
+				// A shard is closed by the first row that reaches the limit.
+				if i < len(shards)-1 && len(data) < limit {
+					t.Errorf("shard %d is %d bytes before compression, under the limit of %d", i, len(data), limit)
+				}
+				if last := len(lines[len(lines)-1]); len(data)-last-1 >= limit {
+					t.Errorf("shard %d was %d bytes before its last row", i, len(data)-last-1)
+				}
+				if lines[0] != "id,source,page,text,tokens,hash\n" {
+					t.Errorf("shard %d starts with %q, want the header", i, lines[0])
+				}
+				rows = append(rows, lines[1:]...)
+			}
+			if len(rows) != len(chunks) {
+				t.Errorf("shards hold %d rows, want %d", len(rows), len(chunks))
+			}
+		})
+	}
+}
+
+func TestShardWriterParquetBytes(t *testing.T) {
+	chunks := parquetTestChunks(30)
+	path := filepath.Join(t.TempDir(), "data.parquet")
+	files := writeSharded(t, path, "parquet", ShardOptions{Bytes: 600}, chunks)
+	m := readManifest(t, files[len(files)-1])
+	if len(m.Shards) < 2 || m.Chunks != len(chunks) {
+		t.Fatalf("manifest = %+v, want several shards of %d chunks", m, len(chunks))
+	}
+	rows := 0
+	for i, f := range files[:len(files)-1] {
+		p := readParquet(t, f)
+		if n := len(p.values[0]); n != m.Shards[i].Chunks {
+			t.Errorf("shard %d holds %d rows, manifest says %d", i, n, m.Shards[i].Chunks)
+		}
+		for j, text := range p.values[0] {
+			if text != chunks[rows+j].Text {
+				t.Errorf("shard %d row %d = %q, want %q", i, j, text, chunks[rows+j].Text)
+			}
+		}
+		rows += len(p.values[0])
+	}
+	if rows != len(chunks) {
+		t.Errorf("shards hold %d rows, want %d", rows, len(chunks))
+	}
+}
+
+func TestShardWriterCompressOnly(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.jsonl")
+	files := writeSharded(t, path, "jsonl", ShardOptions{Compression: CompressGzip}, parquetTestChunks(5))
+	if len(files) != 1 || files[0] != path+".gz" {
+		t.Fatalf("files = %v, want only %s.gz", files, path)
+	}
+	if lines := bytes.Count(readCompressed(t, files[0], CompressGzip), []byte("\n")); lines != 5 {
+		t.Errorf("file holds %d lines, want 5", lines)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d entries, want 1", len(entries))
+	}
+}
+
+func TestShardWriterEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv")
+	files := writeSharded(t, path, "csv", ShardOptions{Records: 10}, nil)
+	want := []string{ShardPath(path, 0, 1), ManifestPath(path)}
+	if fmt.Sprint(files) != fmt.Sprint(want) {
+		t.Fatalf("files = %v, want %v", files, want)
+	}
+	if data, _ := os.ReadFile(files[0]); string(data) != "id,source,page,text,tokens,hash\n" {
+		t.Errorf("empty shard = %q, want only the header", data)
+	}
+}
+
+// failingWriter fails on the chunk with index fail.
+type failingWriter struct {
+	fail, n int
+	closed  bool
+}
+
+func (w *failingWriter) Write(c processor.Chunk) error {
+	if w.n++; w.n == w.fail {
+		return fmt.Errorf("disk full")
+	}
+	return nil
+}
+
+func (w *failingWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestShardWriterWriteError(t *testing.T) {
+	var writers []*failingWriter
+	path := filepath.Join(t.TempDir(), "data.jsonl")
+	w := NewShardWriter(path, "jsonl", ShardOptions{Records: 2}, func(io.Writer) (ChunkWriter, error) {
+		fw := &failingWriter{fail: 2}
+		writers = append(writers, fw)
+		return fw, nil
+	})
+	var err error
+	for _, c := range parquetTestChunks(3) {
+		if err = w.Write(c); err != nil {
+			break
+		}
+	}
+	if err == nil || err.Error() != "disk full" {
+		t.Fatalf("Write error = %v, want disk full", err)
+	}
+	if _, err := w.Close(); err == nil || err.Error() != "disk full" {
+		t.Errorf("Close after a failed Write = %v, want disk full", err)
+	}
+	if len(writers) != 1 || !writers[0].closed {
+		t.Errorf("the failed shard was not closed")
+	}
+	if _, err := os.Stat(ManifestPath(path)); !os.IsNotExist(err) {
+		t.Errorf("manifest written after a failed Write: %v", err)
+	}
+}
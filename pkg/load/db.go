@@ -4,6 +4,7 @@ package load
 import (
 	"context"
 	"database/sql"
+	"encoding/csv"
 	"fmt"
 	"os"
 	
@@ -43,8 +44,9 @@ func LoadToPostgres(data []string, dbURL string) error {
 
 	return nil
 }
-// LoadToCSV loads data into a CSV file
-
+// LoadToCSV loads data into a CSV file with a single "text" column: a
+// header row naming it, then one row per chunk, quoted where a chunk holds
+// commas, quotes or line breaks (RFC 4180)
 func LoadToCSV(data []string, outputPath string) error {
 	file, err := os.Create(outputPath)
 	if err != nil {
@@ -52,12 +54,19 @@ func LoadToCSV(data []string, outputPath string) error {
 	}
 	defer file.Close()
 
+	w := csv.NewWriter(file)
+	if err := w.Write([]string{"text"}); err != nil {
+		return fmt.Errorf("failed to write to CSV file: %v", err)
+	}
 	for _, chunk := range data {
-		_, err := file.WriteString(chunk + "\n")
-		if err != nil {
+		if err := w.Write([]string{chunk}); err != nil {
 			return fmt.Errorf("failed to write to CSV file: %v", err)
 		}
 	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write to CSV file: %v", err)
+	}
 
 	return nil
 }
@@ -0,0 +1,40 @@
+package load
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadToCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv")
+	data := []string{"plain", "a, b", `say "hi"`, "two\nlines"}
+	if err := LoadToCSV(data, path); err != nil {
+		t.Fatalf("LoadToCSV: %v", err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "text\nplain\n\"a, b\"\n\"say \"\"hi\"\"\"\n\"two\nlines\"\n"; string(raw) != want {
+		t.Errorf("file %q, want %q", raw, want)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"text"}}
+	for _, chunk := range data {
+		want = append(want, []string{chunk})
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records = %q, want %q", records, want)
+	}
+}
@@ -1,6 +1,7 @@
 package load
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"go/ast"
@@ -10,6 +11,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/anurag-bit/goetl/pkg/processor"
 )
 
 // FileManager manages loading, saving, and analyzing files and directories.
@@ -19,6 +22,13 @@ type FileManager struct {
 	FileType     string
 	FileTypeName string
 	FileTypeID   string
+
+	// SecretScanner, when set, is run over every file in the codebase and
+	// SecretPolicy decides whether files with findings are kept, dropped
+	// from the graph or fail the analysis. Findings are collected in SecretReport.
+	SecretScanner *processor.SecretScanner
+	SecretPolicy  processor.SecretPolicy
+	SecretReport  *processor.SecretReport
 }
 
 // NewFileManager creates a new FileManager for a given file path.
@@ -113,7 +123,17 @@ func (fm *FileManager) buildGraphRecursive(path string, parent *SemanticNode) er
 			if err != nil {
 				return err
 			}
-		} else if strings.HasSuffix(entry.Name(), ".go") {
+			parent.Children = append(parent.Children, node)
+			continue
+		}
+		keep, err := fm.scanSecrets(fullPath)
+		if err != nil {
+			return err
+		}
+		if !keep {
+			continue
+		}
+		if strings.HasSuffix(entry.Name(), ".go") {
 			node.Type = "go-file"
 			structs, funcs, _ := parseGoFile(fullPath)
 			for _, s := range structs {
@@ -130,6 +150,23 @@ func (fm *FileManager) buildGraphRecursive(path string, parent *SemanticNode) er
 	return nil
 }
 
+// scanSecrets runs the secret scanner over a file and reports whether the
+// file should stay in the graph. Binary files are not scanned.
+func (fm *FileManager) scanSecrets(path string) (bool, error) {
+	if fm.SecretScanner == nil || fm.SecretPolicy == processor.SecretPolicyOff {
+		return true, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	if bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+		return true, nil
+	}
+	_, keep, err := fm.SecretScanner.Apply(fm.SecretPolicy, fm.SecretReport, path, string(data))
+	return keep, err
+}
+
 // parseGoFile parses a Go file and returns struct and function names.
 func parseGoFile(path string) (structs []string, funcs []string, err error) {
 	fset := token.NewFileSet()
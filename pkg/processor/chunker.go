@@ -1,11 +1,20 @@
 // processor/chunker.go
 package processor
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+)
 
 // ChunkText splits the text into fixed-size chunks with optional overlap.
 // Example: chunkSize = 500, overlap = 50
+// A non-positive chunkSize yields no chunks; overlap is clamped to
+// [0, chunkSize-1] so the loop always advances.
 func ChunkText(text string, chunkSize int, overlap int) []string {
+	if chunkSize <= 0 {
+		return nil
+	}
+	overlap = clampOverlap(overlap, chunkSize)
 	var chunks []string
 	runes := []rune(text)
 	length := len(runes)
@@ -26,8 +35,12 @@ func ChunkText(text string, chunkSize int, overlap int) []string {
 }
 
 // ChunkTextByTokens splits the text into chunks of tokens (words)
-// with optional overlap in word count.
+// with optional overlap in word count. Sizes are handled as in ChunkText.
 func ChunkTextByTokens(text string, tokenSize int, overlap int) []string {
+	if tokenSize <= 0 {
+		return nil
+	}
+	overlap = clampOverlap(overlap, tokenSize)
 	var chunks []string
 	words := strings.Fields(text)
 	length := len(words)
@@ -63,7 +76,14 @@ func ChunkTextByParagraph(text string) []string {
 	return paragraphs
 }
 
+// ChunkTextBySearchableTokens splits text into chunks of tokenSize words
+// joined by single spaces, overlapping by overlap words. Sizes are handled
+// as in ChunkText.
 func ChunkTextBySearchableTokens(text string, tokenSize int, overlap int) []string {
+	if tokenSize <= 0 {
+		return nil
+	}
+	overlap = clampOverlap(overlap, tokenSize)
 	var chunks []string
 	words := strings.Fields(text)
 	length := len(words)
@@ -82,3 +102,184 @@ func ChunkTextBySearchableTokens(text string, tokenSize int, overlap int) []stri
 	}
 	return chunks
 }
+
+// clampOverlap limits overlap to [0, size-1].
+func clampOverlap(overlap, size int) int {
+	return max(0, min(overlap, size-1))
+}
+
+// recursivePiece is a unit the recursive chunker packs into chunks: a whole
+// paragraph, a sentence or a run of words, with its size in tokens.
+type recursivePiece struct {
+	text      string
+	tokens    int
+	paragraph int
+}
+
+// ChunkTextRecursive splits text at the coarsest boundary that keeps pieces
+// within tokenSize words: paragraph breaks ("\n\n") first, then sentence
+// boundaries, then words. Consecutive pieces are packed into chunks of at
+// most tokenSize words. Each new chunk starts with the trailing sentences of
+// the previous one, as many as fit into overlap words, so overlaps never
+// cut a sentence in half.
+func ChunkTextRecursive(text string, tokenSize int, overlap int) []string {
+	return ChunkTextRecursiveCounted(text, tokenSize, overlap, WordCounter{})
+}
+
+// ChunkTextRecursiveCounted is ChunkTextRecursive with sizes measured in
+// the tokens of counter instead of words.
+func ChunkTextRecursiveCounted(text string, tokenSize int, overlap int, counter TokenCounter) []string {
+	if tokenSize <= 0 {
+		return nil
+	}
+	var pieces []recursivePiece
+	for i, para := range ChunkTextByParagraph(text) {
+		if n := counter.CountTokens(para); n <= tokenSize {
+			pieces = append(pieces, recursivePiece{text: para, tokens: n, paragraph: i})
+			continue
+		}
+		for _, sentence := range SplitSentences(para) {
+			if n := counter.CountTokens(sentence); n <= tokenSize {
+				pieces = append(pieces, recursivePiece{text: sentence, tokens: n, paragraph: i})
+				continue
+			}
+			for _, part := range ChunkTextByCountedTokens(sentence, tokenSize, 0, counter) {
+				pieces = append(pieces, recursivePiece{text: part, tokens: counter.CountTokens(part), paragraph: i})
+			}
+		}
+	}
+
+	var chunks []string
+	var current []recursivePiece
+	size := 0
+	for _, p := range pieces {
+		if size+p.tokens > tokenSize && len(current) > 0 {
+			chunks = append(chunks, joinPieces(current))
+			current = overlapPieces(current, overlap, tokenSize-p.tokens, counter)
+			size = 0
+			for _, c := range current {
+				size += c.tokens
+			}
+		}
+		current = append(current, p)
+		size += p.tokens
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, joinPieces(current))
+	}
+	return chunks
+}
+
+// overlapPieces returns the trailing sentences of a finished chunk that fit
+// into overlap words and into the room left for the next piece.
+func overlapPieces(prev []recursivePiece, overlap, room int, counter TokenCounter) []recursivePiece {
+	limit := min(overlap, room)
+	var sentences []recursivePiece
+	total := 0
+	for i := len(prev) - 1; i >= 0; i-- {
+		ss := SplitSentences(prev[i].text)
+		for j := len(ss) - 1; j >= 0; j-- {
+			n := counter.CountTokens(ss[j])
+			if total+n > limit {
+				return reversePieces(sentences)
+			}
+			sentences = append(sentences, recursivePiece{text: ss[j], tokens: n, paragraph: prev[i].paragraph})
+			total += n
+		}
+	}
+	return reversePieces(sentences)
+}
+
+func reversePieces(p []recursivePiece) []recursivePiece {
+	for i, j := 0, len(p)-1; i < j; i, j = i+1, j-1 {
+		p[i], p[j] = p[j], p[i]
+	}
+	return p
+}
+
+// joinPieces joins pieces with a space inside a paragraph and a blank line between paragraphs.
+func joinPieces(pieces []recursivePiece) string {
+	var b strings.Builder
+	for i, p := range pieces {
+		if i > 0 {
+			if p.paragraph != pieces[i-1].paragraph {
+				b.WriteString("\n\n")
+			} else {
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString(p.text)
+	}
+	return b.String()
+}
+
+// Chunker names accepted by ChunkOptions.
+const (
+	ChunkerFixed     = "fixed"
+	ChunkerToken     = "token"
+	ChunkerParagraph = "paragraph"
+	ChunkerRecursive = "recursive"
+	ChunkerSemantic  = "semantic"
+	// ChunkerCode splits Go sources at declarations and chunks other
+	// files like ChunkerRecursive.
+	ChunkerCode = "code"
+)
+
+// ParseChunker validates a chunker name from a flag or API request and
+// returns it in canonical form. An empty name selects ChunkerToken.
+func ParseChunker(name string) (string, error) {
+	switch c := strings.ToLower(strings.TrimSpace(name)); c {
+	case "":
+		return ChunkerToken, nil
+	case ChunkerFixed, ChunkerToken, ChunkerParagraph, ChunkerRecursive, ChunkerSemantic, ChunkerCode:
+		return c, nil
+	default:
+		return "", fmt.Errorf("unknown chunker %q (want fixed, token, paragraph, recursive, semantic or code)", name)
+	}
+}
+
+// KeepsParagraphs reports whether the named chunker needs paragraph breaks
+// preserved by cleaning.
+func KeepsParagraphs(chunker string) bool {
+	c := strings.ToLower(chunker)
+	return c == ChunkerParagraph || c == ChunkerRecursive || c == ChunkerCode
+}
+
+// Chunk is a piece of a document together with the metadata the pipeline
+// stages collect for it.
+type Chunk struct {
+	Text               string
+	Tokens             int
+	Source             string
+	Language           string
+	LanguageConfidence float64
+	// Table marks a chunk holding one whole serialized table; Caption is
+	// the table's caption, if it had one.
+	Table   bool
+	Caption string
+	// Symbol names the Go declaration a code chunk holds, e.g. "(*Tokenizer).ApplyBPE".
+	Symbol string
+	// Page is the 1-based page the chunk starts on, for paged formats such
+	// as PDF; Section is the title of the heading it falls under.
+	Page    int
+	Section string
+	// Generated holds the samples a Generator wrote for the chunk; they
+	// replace the default instruction sample in JSONL output.
+	Generated []GeneratedSample
+	// Index is the 1-based position of the chunk among all chunks of a
+	// run, so that ids stay unique across split and shard files; zero if
+	// it was not assigned.
+	Index int
+	// Document identifies the document the chunk was cut from by its
+	// content (see DocumentKey); SplitChunks falls back to Source if empty.
+	Document string
+}
+
+// ChunkTexts returns the text of every chunk, for loaders that store plain strings.
+func ChunkTexts(chunks []Chunk) []string {
+	texts := make([]string, len(chunks))
+	for i, c := range chunks {
+		texts[i] = c.Text
+	}
+	return texts
+}
@@ -0,0 +1,242 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// recursiveText returns paragraphs of sentences of two to six words. Every
+// word is distinct and sentences start with a capital letter.
+func recursiveText(paragraphs, sentences int) string {
+	var paras []string
+	word := 0
+	for p := 0; p < paragraphs; p++ {
+		var ss []string
+		for s := 0; s < sentences; s++ {
+			words := []string{fmt.Sprintf("W%d", word)}
+			word++
+			for len(words) < 2+(p+s)%5 {
+				words = append(words, fmt.Sprintf("w%d", word))
+				word++
+			}
+			ss = append(ss, strings.Join(words, " ")+".")
+		}
+		paras = append(paras, strings.Join(ss, " "))
+	}
+	return strings.Join(paras, "\n\n")
+}
+
+// paragraphOf maps every word of text to the index of its paragraph.
+func paragraphOf(text string) map[string]int {
+	paras := make(map[string]int)
+	for i, p := range strings.Split(text, "\n\n") {
+		for _, w := range strings.Fields(p) {
+			paras[w] = i
+		}
+	}
+	return paras
+}
+
+func TestChunkTextRecursive(t *testing.T) {
+	text := recursiveText(4, 5)
+	words := strings.Fields(text)
+	paras := paragraphOf(text)
+	for _, size := range []int{6, 9, 15, 40} {
+		for _, overlap := range []int{0, 3, 7} {
+			if overlap >= size {
+				continue
+			}
+			name := fmt.Sprintf("size %d overlap %d", size, overlap)
+			chunks := ChunkTextRecursiveCounted(text, size, overlap, WordCounter{})
+			var all []string
+			overlapped := false
+			for i, c := range chunks {
+				cw := strings.Fields(c)
+				if len(cw) > size {
+					t.Errorf("%s: chunk %q has %d words", name, c, len(cw))
+				}
+				// Paragraphs are separated by a blank line, sentences of one
+				// paragraph by a space.
+				for j, part := range strings.Split(c, "\n\n") {
+					first := strings.Fields(part)[0]
+					if strings.Contains(part, "\n") || strings.Contains(part, "  ") {
+						t.Errorf("%s: paragraph %q is not joined by single spaces", name, part)
+					}
+					for _, w := range strings.Fields(part) {
+						if paras[w] != paras[first] {
+							t.Errorf("%s: %s and %s share a paragraph in %q", name, first, w, c)
+						}
+					}
+					if j > 0 && paras[first] == paras[strings.Fields(strings.Split(c, "\n\n")[j-1])[0]] {
+						t.Errorf("%s: blank line inside paragraph %d in %q", name, paras[first], c)
+					}
+				}
+
+				// The overlap is the previous chunk's trailing whole sentences.
+				shared := 0
+				if i > 0 {
+					prev := strings.Fields(chunks[i-1])
+					for k := min(len(cw), len(prev)); k > 0 && shared == 0; k-- {
+						if reflect.DeepEqual(prev[len(prev)-k:], cw[:k]) {
+							shared = k
+						}
+					}
+					if shared > overlap {
+						t.Errorf("%s: chunk %d repeats %d words", name, i, shared)
+					}
+					if shared > 0 {
+						overlapped = true
+						if !strings.HasPrefix(cw[0], "W") || !strings.HasSuffix(cw[shared-1], ".") {
+							t.Errorf("%s: overlap %q is not whole sentences", name, cw[:shared])
+						}
+					}
+				}
+				all = append(all, cw[shared:]...)
+			}
+			if !reflect.DeepEqual(all, words) {
+				t.Errorf("%s: chunks without their overlaps do not add up to the text", name)
+			}
+			if overlap >= 6 && size >= 15 && !overlapped {
+				t.Errorf("%s: no chunk overlaps", name)
+			}
+		}
+	}
+}
+
+func TestChunkTextRecursiveLongSentence(t *testing.T) {
+	var long []string
+	for i := 1; i <= 25; i++ {
+		long = append(long, fmt.Sprintf("x%d", i))
+	}
+	text := "Short one. Long " + strings.Join(long, " ") + ". End here."
+	want := []string{
+		"Short one.",
+		"Long x1 x2 x3 x4 x5 x6 x7 x8 x9",
+		"x10 x11 x12 x13 x14 x15 x16 x17 x18 x19",
+		"x20 x21 x22 x23 x24 x25. End here.",
+	}
+	// Parts of a split sentence are never used as overlap.
+	for _, overlap := range []int{0, 3} {
+		if got := ChunkTextRecursive(text, 10, overlap); !reflect.DeepEqual(got, want) {
+			t.Errorf("overlap %d: chunks %q, want %q", overlap, got, want)
+		}
+	}
+
+	// Sizes measured by a counter other than words.
+	for _, c := range ChunkTextRecursiveCounted(text, 20, 0, charCounter{}) {
+		if len(c) > 20 && len(strings.Fields(c)) > 1 {
+			t.Errorf("chunk %q has %d characters", c, len(c))
+		}
+	}
+	if got := ChunkTextRecursive(text, 0, 0); got != nil {
+		t.Errorf("size 0 gives %q", got)
+	}
+}
+
+func TestOverlapPieces(t *testing.T) {
+	prev := []recursivePiece{
+		{text: "One two. Three four five.", tokens: 5, paragraph: 0},
+		{text: "Six seven. Eight.", tokens: 3, paragraph: 1},
+	}
+	for _, tc := range []struct {
+		overlap, room int
+		want          []string
+	}{
+		{0, 10, nil},
+		{1, 10, []string{"Eight."}},
+		{2, 10, []string{"Eight."}},
+		{3, 10, []string{"Six seven.", "Eight."}},
+		{6, 10, []string{"Three four five.", "Six seven.", "Eight."}},
+		{10, 10, []string{"One two.", "Three four five.", "Six seven.", "Eight."}},
+		{10, 3, []string{"Six seven.", "Eight."}},
+	} {
+		var got []string
+		for _, p := range overlapPieces(prev, tc.overlap, tc.room, WordCounter{}) {
+			got = append(got, p.text)
+			if want := map[bool]int{true: 1}[strings.Contains(prev[1].text, p.text)]; p.paragraph != want || p.tokens != len(strings.Fields(p.text)) {
+				t.Errorf("piece %+v", p)
+			}
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("overlapPieces(%d, %d) = %q, want %q", tc.overlap, tc.room, got, tc.want)
+		}
+	}
+}
+
+// topicEmbedder embeds a text as its counts of "cat" and "car" words.
+type topicEmbedder struct {
+	calls int
+	err   error
+	short bool
+}
+
+func (e *topicEmbedder) Embed(texts []string) ([][]float64, error) {
+	e.calls++
+	if e.err != nil {
+		return nil, e.err
+	}
+	var vectors [][]float64
+	for _, t := range texts {
+		vectors = append(vectors, []float64{float64(strings.Count(t, "cat")), float64(strings.Count(t, "car"))})
+	}
+	if e.short {
+		vectors = vectors[1:]
+	}
+	return vectors, nil
+}
+
+func TestSemanticChunker(t *testing.T) {
+	text := "The cat sleeps. A cat purrs. My cat eats. The car starts. A car stops. My car rusts."
+	s := NewSemanticChunker(&topicEmbedder{}, 0, nil)
+	s.Window = 0
+	chunks, err := s.Chunk(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"The cat sleeps. A cat purrs. My cat eats.", "The car starts. A car stops. My car rusts."}; !reflect.DeepEqual(chunks, want) {
+		t.Errorf("Chunk = %q, want %q", chunks, want)
+	}
+
+	// MaxTokens packs sentences and splits a longer sentence into words.
+	s.MaxTokens = 6
+	chunks, _ = s.Chunk("The cat sleeps. A cat purrs. My cat eats and eats and eats all day. The car starts. A car stops.")
+	want := []string{"The cat sleeps. A cat purrs.", "My cat eats and eats and", "eats all day.", "The car starts. A car stops."}
+	if !reflect.DeepEqual(chunks, want) {
+		t.Errorf("Chunk with MaxTokens = %q, want %q", chunks, want)
+	}
+
+	// Fewer than three sentences are not embedded.
+	short := &topicEmbedder{err: errors.New("unreachable")}
+	if chunks, err := NewSemanticChunker(short, 0, nil).Chunk("One cat. Two cars."); err != nil || short.calls != 0 || len(chunks) != 1 {
+		t.Errorf("short text: %q, %v after %d calls", chunks, err, short.calls)
+	}
+	if chunks, _ := NewSemanticChunker(short, 0, nil).Chunk("  "); chunks != nil {
+		t.Errorf("empty text gives %q", chunks)
+	}
+	if _, err := NewSemanticChunker(&topicEmbedder{err: errors.New("down")}, 0, nil).Chunk(text); err == nil {
+		t.Error("embedder error ignored")
+	}
+	if _, err := NewSemanticChunker(&topicEmbedder{short: true}, 0, nil).Chunk(text); err == nil {
+		t.Error("missing vectors ignored")
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	values := []float64{4, 1, 3, 2, 5}
+	for _, tc := range []struct{ p, want float64 }{
+		{0, 1}, {50, 3}, {95, 4.8}, {100, 5}, {25, 2}, {10, 1.4},
+	} {
+		if got := percentile(values, tc.p); got < tc.want-1e-9 || got > tc.want+1e-9 {
+			t.Errorf("percentile(%v) = %v, want %v", tc.p, got, tc.want)
+		}
+	}
+	if got := percentile([]float64{7}, 95); got != 7 {
+		t.Errorf("percentile of one value = %v", got)
+	}
+	if !reflect.DeepEqual(values, []float64{4, 1, 3, 2, 5}) {
+		t.Error("percentile sorted its input")
+	}
+}
@@ -37,3 +37,20 @@ func CleanText(text string) string {
 
 	return text
 }
+
+// CleanTextKeepParagraphs cleans text like CleanText but keeps paragraph
+// breaks (blank lines) as "\n\n", for chunkers that split at paragraphs.
+// Single line breaks inside a paragraph become spaces.
+func CleanTextKeepParagraphs(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	reBreaks := regexp.MustCompile(`\n[ \t\f\v]*\n\s*`)
+	var paragraphs []string
+	for _, p := range reBreaks.Split(text, -1) {
+		if p = CleanText(p); p != "" {
+			paragraphs = append(paragraphs, p)
+		}
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
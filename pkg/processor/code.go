@@ -0,0 +1,213 @@
+// processor/code.go
+package processor
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strings"
+)
+
+// ChunkFile chunks the extracted text of source. With ChunkerCode, Go
+// sources are split by ChunkGoSource; Go sources that do not parse and all
+// other files go through ChunkDocument. Parse failures are reported to
+// CodeFallback. text is extracted text, with literal markers escaped.
+func (o ChunkOptions) ChunkFile(source, text string) ([]Chunk, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	if o.Chunker == ChunkerCode && strings.EqualFold(filepath.Ext(source), ".go") {
+		chunks, err := ChunkGoSource(UnescapeMarkers(text), o.Size, o.Counter)
+		if err == nil {
+			return chunks, nil
+		}
+		if o.CodeFallback != nil {
+			o.CodeFallback(source, err)
+		}
+	}
+	return o.ChunkDocument(text)
+}
+
+// ChunkGoSource splits Go source into one chunk per function, method and
+// type declaration, each with its doc comment and prefixed by the file's
+// package clause and imports. Consecutive const and var declarations are
+// grouped. A function longer than maxTokens, as measured by counter, is
+// split between the top-level statements of its body and every part
+// repeats the signature; a single statement is never split. maxTokens <= 0
+// disables splitting. Comments outside declarations are dropped.
+func ChunkGoSource(src string, maxTokens int, counter TokenCounter) ([]Chunk, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	if counter == nil {
+		counter = WordCounter{}
+	}
+	g := goChunker{src: src, fset: fset, counter: counter}
+
+	header := "package " + file.Name.Name + "\n"
+	for _, decl := range file.Decls {
+		if d, ok := decl.(*ast.GenDecl); ok && d.Tok == token.IMPORT {
+			header += "\n" + g.text(d.Pos(), d.End()) + "\n"
+		}
+	}
+	// The header is repeated in every chunk, so it counts against the limit
+	// unless it leaves no room at all.
+	g.budget = maxTokens
+	if maxTokens > 0 && maxTokens-counter.CountTokens(header) > 0 {
+		g.budget = maxTokens - counter.CountTokens(header)
+	}
+
+	var chunks []Chunk
+	emit := func(symbol, body string) {
+		chunks = append(chunks, Chunk{Text: header + "\n" + body, Symbol: symbol})
+	}
+	var values, names []string
+	flushValues := func() {
+		if len(values) > 0 {
+			emit(strings.Join(names, ", "), strings.Join(values, "\n\n"))
+			values, names = nil, nil
+		}
+	}
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			flushValues()
+			for _, part := range g.splitFunc(d) {
+				emit(funcSymbol(d), part)
+			}
+		case *ast.GenDecl:
+			switch d.Tok {
+			case token.IMPORT:
+			case token.TYPE:
+				flushValues()
+				emit("type "+strings.Join(specNames(d), ", "), g.withDoc(d.Doc, d))
+			default:
+				text := g.withDoc(d.Doc, d)
+				if len(values) > 0 && g.budget > 0 && g.count(append(values, text)) > g.budget {
+					flushValues()
+				}
+				values = append(values, text)
+				names = append(names, d.Tok.String()+" "+strings.Join(specNames(d), ", "))
+			}
+		}
+	}
+	flushValues()
+	return chunks, nil
+}
+
+// goChunker holds the parsed source shared by the helpers of ChunkGoSource.
+type goChunker struct {
+	src     string
+	fset    *token.FileSet
+	counter TokenCounter
+	budget  int
+}
+
+func (g goChunker) offset(p token.Pos) int {
+	return g.fset.Position(p).Offset
+}
+
+func (g goChunker) text(from, to token.Pos) string {
+	return g.src[g.offset(from):g.offset(to)]
+}
+
+func (g goChunker) count(parts []string) int {
+	return g.counter.CountTokens(strings.Join(parts, "\n"))
+}
+
+// withDoc returns the source of node preceded by its doc comment.
+func (g goChunker) withDoc(doc *ast.CommentGroup, node ast.Node) string {
+	start := node.Pos()
+	if doc != nil {
+		start = doc.Pos()
+	}
+	return g.text(start, node.End())
+}
+
+// splitFunc returns the function whole if it fits the budget, otherwise
+// parts of its body packed statement by statement, each wrapped in the
+// doc comment, signature and closing brace. Comments between statements
+// stay with the statement that follows them.
+func (g goChunker) splitFunc(d *ast.FuncDecl) []string {
+	whole := g.withDoc(d.Doc, d)
+	if g.budget <= 0 || d.Body == nil || len(d.Body.List) < 2 || g.counter.CountTokens(whole) <= g.budget {
+		return []string{whole}
+	}
+	start := d.Pos()
+	if d.Doc != nil {
+		start = d.Doc.Pos()
+	}
+	open := g.text(start, d.Body.Lbrace) + "{\n"
+	room := g.budget - g.counter.CountTokens(open+"}")
+
+	// bounds[i] is where the source of statement i starts: just after the
+	// line that ends the previous statement (or the opening brace), or at
+	// the statement itself if it starts on that same line, as in
+	// "{ a(); b() }".
+	bounds := []int{min(g.lineEnd(g.offset(d.Body.Lbrace)), g.offset(d.Body.List[0].Pos()))}
+	for i, stmt := range d.Body.List[1:] {
+		prev := d.Body.List[i]
+		bounds = append(bounds, min(g.lineEnd(g.offset(prev.End())), g.offset(stmt.Pos())))
+	}
+	// The last statement ends at the line of the closing brace, or at the
+	// brace if they share a line.
+	closing := strings.LastIndex(g.src[:g.offset(d.Body.Rbrace)], "\n") + 1
+	if last := d.Body.List[len(d.Body.List)-1]; closing < g.offset(last.End()) {
+		closing = g.offset(d.Body.Rbrace)
+	}
+	bounds = append(bounds, closing)
+
+	var parts, current []string
+	for i := 0; i+1 < len(bounds); i++ {
+		stmt := strings.TrimRight(g.src[bounds[i]:bounds[i+1]], "; \t\n")
+		if len(current) > 0 && g.count(append(current, stmt)) > room {
+			parts = append(parts, open+strings.Join(current, "\n")+"\n}")
+			current = nil
+		}
+		current = append(current, stmt)
+	}
+	if len(current) > 0 {
+		parts = append(parts, open+strings.Join(current, "\n")+"\n}")
+	}
+	return parts
+}
+
+// lineEnd returns the offset just past the newline at or after off.
+func (g goChunker) lineEnd(off int) int {
+	if i := strings.IndexByte(g.src[off:], '\n'); i >= 0 {
+		return off + i + 1
+	}
+	return len(g.src)
+}
+
+// funcSymbol names a function or method, e.g. "ChunkText" or "(*Tokenizer).ApplyBPE".
+func funcSymbol(d *ast.FuncDecl) string {
+	if d.Recv == nil || len(d.Recv.List) == 0 {
+		return d.Name.Name
+	}
+	recv := types.ExprString(d.Recv.List[0].Type)
+	if strings.HasPrefix(recv, "*") {
+		recv = "(" + recv + ")"
+	}
+	return recv + "." + d.Name.Name
+}
+
+// specNames lists the names declared by a type, const or var declaration.
+func specNames(d *ast.GenDecl) []string {
+	var names []string
+	for _, spec := range d.Specs {
+		switch s := spec.(type) {
+		case *ast.TypeSpec:
+			names = append(names, s.Name.Name)
+		case *ast.ValueSpec:
+			for _, n := range s.Names {
+				names = append(names, n.Name)
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,248 @@
+package processor
+
+import (
+	"go/parser"
+	"go/token"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const codeHeader = `package demo
+
+import (
+	"fmt"
+	"strings"
+)
+`
+
+const codeSource = codeHeader + `
+// Limit is the largest size.
+const Limit = 10
+
+var name = "demo"
+
+// Point is a point.
+type Point struct{ X, Y int }
+
+// Add returns the sum of p and q.
+func (p *Point) Add(q Point) Point {
+	return Point{p.X + q.X, p.Y + q.Y}
+}
+
+func Hello() string { return fmt.Sprint(strings.ToUpper(name)) }
+`
+
+// parseChunk fails unless the chunk is a Go file of its own.
+func parseChunk(t *testing.T, c Chunk) {
+	t.Helper()
+	if _, err := parser.ParseFile(token.NewFileSet(), "", c.Text, parser.ParseComments); err != nil {
+		t.Errorf("chunk %s does not parse: %v\n%s", c.Symbol, err, c.Text)
+	}
+}
+
+func TestChunkGoSourceDeclarations(t *testing.T) {
+	chunks, err := ChunkGoSource(codeSource, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ symbol, body string }{
+		{"const Limit, var name", "// Limit is the largest size.\nconst Limit = 10\n\nvar name = \"demo\""},
+		{"type Point", "// Point is a point.\ntype Point struct{ X, Y int }"},
+		{"(*Point).Add", "// Add returns the sum of p and q.\nfunc (p *Point) Add(q Point) Point {\n\treturn Point{p.X + q.X, p.Y + q.Y}\n}"},
+		{"Hello", "func Hello() string { return fmt.Sprint(strings.ToUpper(name)) }"},
+	}
+	if len(chunks) != len(want) {
+		t.Fatalf("got %d chunks, want %d", len(chunks), len(want))
+	}
+	for i, w := range want {
+		if chunks[i].Symbol != w.symbol {
+			t.Errorf("chunk %d: symbol %q, want %q", i, chunks[i].Symbol, w.symbol)
+		}
+		if body := codeHeader + "\n" + w.body; chunks[i].Text != body {
+			t.Errorf("chunk %d = %q, want %q", i, chunks[i].Text, body)
+		}
+		parseChunk(t, chunks[i])
+	}
+
+	if _, err := ChunkGoSource("package demo\nfunc (", 0, nil); err == nil {
+		t.Error("ChunkGoSource accepted source that does not parse")
+	}
+}
+
+func TestChunkGoSourceGroupsValues(t *testing.T) {
+	var src strings.Builder
+	src.WriteString("package demo\n")
+	var names []string
+	for _, name := range []string{"A", "B", "C", "D", "E", "F"} {
+		src.WriteString("\nconst " + name + " = \"one two three\"\n")
+		names = append(names, "const "+name)
+	}
+	// Each declaration is six words and the header two.
+	for _, tc := range []struct {
+		maxTokens int
+		symbols   []string
+	}{
+		{0, []string{strings.Join(names, ", ")}},
+		{14, []string{"const A, const B", "const C, const D", "const E, const F"}},
+		{20, []string{"const A, const B, const C", "const D, const E, const F"}},
+		{4, names},
+	} {
+		chunks, err := ChunkGoSource(src.String(), tc.maxTokens, WordCounter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var symbols []string
+		for _, c := range chunks {
+			symbols = append(symbols, c.Symbol)
+			parseChunk(t, c)
+		}
+		if !reflect.DeepEqual(symbols, tc.symbols) {
+			t.Errorf("maxTokens %d: groups %q, want %q", tc.maxTokens, symbols, tc.symbols)
+		}
+	}
+}
+
+func TestChunkGoSourceSplitsFunctions(t *testing.T) {
+	const long = `package demo
+
+// Sum adds up the first n numbers.
+func Sum(n int) int {
+	total := 0
+	// Count up.
+	for i := 0; i < n; i++ {
+		total += i
+	}
+	if total < 0 {
+		panic("overflow")
+	}
+	total *= 2
+	total /= 2
+	return total
+}
+`
+	const signature = "package demo\n\n// Sum adds up the first n numbers.\nfunc Sum(n int) int {\n"
+	statements := []string{
+		"\ttotal := 0",
+		"\t// Count up.\n\tfor i := 0; i < n; i++ {\n\t\ttotal += i\n\t}",
+		"\tif total < 0 {\n\t\tpanic(\"overflow\")\n\t}",
+		"\ttotal *= 2",
+		"\ttotal /= 2",
+		"\treturn total",
+	}
+	for _, maxTokens := range []int{20, 30, 45} {
+		chunks, err := ChunkGoSource(long, maxTokens, WordCounter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(chunks) < 2 {
+			t.Fatalf("maxTokens %d: the function was not split", maxTokens)
+		}
+		// Every part repeats the signature and the statements come out
+		// whole, in order and once.
+		var got []string
+		for _, c := range chunks {
+			if c.Symbol != "Sum" || !strings.HasPrefix(c.Text, signature) || !strings.HasSuffix(c.Text, "\n}") {
+				t.Errorf("maxTokens %d: part %q of %s", maxTokens, c.Text, c.Symbol)
+				continue
+			}
+			body := strings.TrimSuffix(strings.TrimPrefix(c.Text, signature), "\n}")
+			if n := (WordCounter{}).CountTokens(c.Text); n > maxTokens && !slices.Contains(statements, body) {
+				t.Errorf("maxTokens %d: part of %d tokens with more than one statement", maxTokens, n)
+			}
+			got = append(got, body)
+			parseChunk(t, c)
+		}
+		if joined := strings.Join(got, "\n"); joined != strings.Join(statements, "\n") {
+			t.Errorf("maxTokens %d: statements %q", maxTokens, joined)
+		}
+	}
+
+	// A function that fits, or has a single statement, stays whole.
+	for _, maxTokens := range []int{0, 200} {
+		if chunks, _ := ChunkGoSource(long, maxTokens, nil); len(chunks) != 1 {
+			t.Errorf("maxTokens %d: %d chunks", maxTokens, len(chunks))
+		}
+	}
+	single := "package demo\n\nfunc F() {\n\tfmt.Println(\"a\", \"b\", \"c\", \"d\", \"e\", \"f\", \"g\")\n}\n"
+	if chunks, _ := ChunkGoSource(single, 5, nil); len(chunks) != 1 || !strings.HasSuffix(chunks[0].Text, "\"g\")\n}") {
+		t.Errorf("single statement split into %+v", chunks)
+	}
+}
+
+func TestChunkGoSourceOneLineBodies(t *testing.T) {
+	for _, tc := range []struct {
+		name, src  string
+		statements []string
+	}{
+		{"one line", "package demo\n\nfunc F() { a := 1; b := a + 1; c := b * 2; println(a, b, c) }\n",
+			[]string{"a := 1", "b := a + 1", "c := b * 2", "println(a, b, c)"}},
+		{"first statement on the brace line", "package demo\n\nfunc F() { a := 1\n\tb := a + 1\n\tprintln(a, b) }\n",
+			[]string{"a := 1", "b := a + 1", "println(a, b)"}},
+		{"two statements per line", "package demo\n\nfunc F() {\n\ta := 1; b := a + 1\n\tc := b * 2; println(a, b, c)\n}\n",
+			[]string{"a := 1", "b := a + 1", "c := b * 2", "println(a, b, c)"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// Room for the signature and one statement per part.
+			chunks, err := ChunkGoSource(tc.src, 10, WordCounter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, c := range chunks {
+				body, ok := strings.CutPrefix(c.Text, "package demo\n\nfunc F() {")
+				if !ok || !strings.HasSuffix(body, "\n}") {
+					t.Fatalf("part %q", c.Text)
+				}
+				for _, stmt := range strings.Split(strings.TrimSpace(strings.TrimSuffix(body, "}")), "\n") {
+					got = append(got, strings.TrimSpace(stmt))
+				}
+				parseChunk(t, c)
+			}
+			if !reflect.DeepEqual(got, tc.statements) {
+				t.Errorf("statements %q, want %q", got, tc.statements)
+			}
+		})
+	}
+}
+
+func TestChunkFileFallback(t *testing.T) {
+	broken := "package demo\n\nfunc Broken( {\n\treturn\n}\n"
+	var fallbacks []string
+	opts := ChunkOptions{Chunker: ChunkerCode, Size: 100, CodeFallback: func(source string, err error) {
+		if err == nil {
+			t.Error("CodeFallback called without an error")
+		}
+		fallbacks = append(fallbacks, source)
+	}}
+
+	chunks, err := opts.ChunkFile("pkg/broken.go", broken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := opts.ChunkDocument(broken)
+	if !reflect.DeepEqual(chunks, want) || len(chunks) == 0 {
+		t.Errorf("broken source chunked as %+v, want %+v", chunks, want)
+	}
+	if !reflect.DeepEqual(fallbacks, []string{"pkg/broken.go"}) {
+		t.Errorf("fallbacks = %q", fallbacks)
+	}
+
+	// Go sources that parse, other files and other chunkers do not fall back.
+	fallbacks = nil
+	if chunks, _ := opts.ChunkFile("demo.GO", codeSource); len(chunks) != 4 || chunks[0].Symbol == "" {
+		t.Errorf("Go source chunked as %d chunks", len(chunks))
+	}
+	opts.ChunkFile("notes.txt", broken)
+	opts.Chunker = ChunkerToken
+	opts.ChunkFile("pkg/broken.go", broken)
+	if len(fallbacks) != 0 {
+		t.Errorf("unexpected fallbacks %q", fallbacks)
+	}
+	// Without a callback the fallback still chunks the text.
+	opts = ChunkOptions{Chunker: ChunkerCode, Size: 100}
+	if chunks, err := opts.ChunkFile("pkg/broken.go", broken); err != nil || len(chunks) == 0 {
+		t.Errorf("ChunkFile = %d chunks, %v", len(chunks), err)
+	}
+}
@@ -0,0 +1,289 @@
+// processor/dedup.go
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// DedupMode selects which duplicates a Deduplicator removes.
+type DedupMode string
+
+const (
+	// DedupOff keeps every chunk.
+	DedupOff DedupMode = "off"
+	// DedupExact removes chunks whose normalized text was already seen.
+	DedupExact DedupMode = "exact"
+	// DedupNear additionally removes chunks whose estimated Jaccard
+	// similarity to an earlier chunk reaches the threshold.
+	DedupNear DedupMode = "near"
+)
+
+// ParseDedupMode converts a flag or API value into a DedupMode.
+// An empty string selects DedupOff.
+func ParseDedupMode(s string) (DedupMode, error) {
+	switch m := DedupMode(strings.ToLower(strings.TrimSpace(s))); m {
+	case "":
+		return DedupOff, nil
+	case DedupOff, DedupExact, DedupNear:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown dedup mode %q (want off, exact or near)", s)
+	}
+}
+
+const (
+	// minHashPermutations is the MinHash signature length.
+	minHashPermutations = 128
+	// shingleSize is the number of words per shingle.
+	shingleSize = 5
+)
+
+// Deduplicator removes exact and near-duplicate chunks. It is stateful:
+// every chunk passed to Keep is remembered, so one Deduplicator shared by
+// all files of a run deduplicates across them.
+type Deduplicator struct {
+	Mode      DedupMode
+	Threshold float64
+
+	bands, rows int
+	seeds       []uint64
+	exact       map[string]struct{}
+	buckets     []map[uint64][]int
+	signatures  [][]uint32
+
+	// ExactRemoved and NearRemoved count the chunks dropped by Keep.
+	ExactRemoved int
+	NearRemoved  int
+}
+
+// NewDeduplicator creates a Deduplicator. threshold is the Jaccard
+// similarity at which two chunks count as near-duplicates; it is only used
+// in DedupNear mode.
+func NewDeduplicator(mode DedupMode, threshold float64) (*Deduplicator, error) {
+	if !(threshold > 0 && threshold <= 1) {
+		return nil, fmt.Errorf("dedup threshold must be in (0, 1], got %v", threshold)
+	}
+	d := &Deduplicator{
+		Mode:      mode,
+		Threshold: threshold,
+		exact:     make(map[string]struct{}),
+	}
+	d.bands, d.rows = lshParams(minHashPermutations, threshold)
+	d.buckets = make([]map[uint64][]int, d.bands)
+	for i := range d.buckets {
+		d.buckets[i] = make(map[uint64][]int)
+	}
+	d.seeds = make([]uint64, minHashPermutations)
+	seed := uint64(0x9E3779B97F4A7C15)
+	for i := range d.seeds {
+		seed = splitMix64(seed)
+		d.seeds[i] = seed
+	}
+	return d, nil
+}
+
+// lshRecall is the probability with which LSH must make a pair of chunks
+// whose similarity equals the threshold a candidate for comparison.
+const lshRecall = 0.99
+
+// lshParams picks the band/row split of n permutations with the most rows,
+// and so the fewest candidates, under which a pair at the threshold shares
+// a band with probability 1-(1-t^r)^b of at least lshRecall. Centring the
+// S-curve on the threshold instead would miss most pairs right at it.
+func lshParams(n int, threshold float64) (bands, rows int) {
+	bands, rows = n, 1
+	for r := 2; r <= n; r++ {
+		if n%r != 0 {
+			continue
+		}
+		b := n / r
+		if 1-math.Pow(1-math.Pow(threshold, float64(r)), float64(b)) >= lshRecall {
+			bands, rows = b, r
+		}
+	}
+	return bands, rows
+}
+
+// Keep reports whether chunk is new. Chunks that are not kept are counted
+// in ExactRemoved or NearRemoved.
+func (d *Deduplicator) Keep(chunk string) bool {
+	if d == nil || d.Mode == DedupOff {
+		return true
+	}
+	words := normalizeWords(chunk)
+	key := exactKey(words)
+	if _, seen := d.exact[key]; seen {
+		d.ExactRemoved++
+		return false
+	}
+	d.exact[key] = struct{}{}
+	if d.Mode != DedupNear {
+		return true
+	}
+
+	sig := d.signature(words)
+	if d.hasNearDuplicate(sig) {
+		d.NearRemoved++
+		return false
+	}
+	d.addSignature(sig)
+	return true
+}
+
+func (d *Deduplicator) hasNearDuplicate(sig []uint32) bool {
+	checked := make(map[int]struct{})
+	for b := 0; b < d.bands; b++ {
+		for _, idx := range d.buckets[b][d.bandKey(sig, b)] {
+			if _, ok := checked[idx]; ok {
+				continue
+			}
+			checked[idx] = struct{}{}
+			if estimateJaccard(sig, d.signatures[idx]) >= d.Threshold {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (d *Deduplicator) addSignature(sig []uint32) {
+	idx := len(d.signatures)
+	d.signatures = append(d.signatures, sig)
+	for b := 0; b < d.bands; b++ {
+		key := d.bandKey(sig, b)
+		d.buckets[b][key] = append(d.buckets[b][key], idx)
+	}
+}
+
+func (d *Deduplicator) bandKey(sig []uint32, band int) uint64 {
+	h := fnv.New64a()
+	var buf [4]byte
+	for _, v := range sig[band*d.rows : (band+1)*d.rows] {
+		buf[0], buf[1], buf[2], buf[3] = byte(v), byte(v>>8), byte(v>>16), byte(v>>24)
+		h.Write(buf[:])
+	}
+	return h.Sum64()
+}
+
+// signature computes the MinHash signature of the word shingles of a chunk.
+func (d *Deduplicator) signature(words []string) []uint32 {
+	sig := make([]uint32, minHashPermutations)
+	for i := range sig {
+		sig[i] = math.MaxUint32
+	}
+	for _, sh := range shingles(words) {
+		for i, seed := range d.seeds {
+			if v := uint32(splitMix64(sh^seed) >> 32); v < sig[i] {
+				sig[i] = v
+			}
+		}
+	}
+	return sig
+}
+
+// shingles hashes every run of shingleSize consecutive words. Texts shorter
+// than a shingle produce a single shingle of all their words.
+func shingles(words []string) []uint64 {
+	if len(words) == 0 {
+		return nil
+	}
+	n := shingleSize
+	if len(words) < n {
+		n = len(words)
+	}
+	out := make([]uint64, 0, len(words)-n+1)
+	for i := 0; i+n <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:i+n], " ")))
+		out = append(out, h.Sum64())
+	}
+	return out
+}
+
+func estimateJaccard(a, b []uint32) float64 {
+	same := 0
+	for i := range a {
+		if a[i] == b[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(a))
+}
+
+func splitMix64(x uint64) uint64 {
+	x += 0x9E3779B97F4A7C15
+	x = (x ^ (x >> 30)) * 0xBF58476D1CE4E5B9
+	x = (x ^ (x >> 27)) * 0x94D049BB133111EB
+	return x ^ (x >> 31)
+}
+
+// normalizeWords lowercases text, drops punctuation and splits it into words,
+// so that chunks differing only in case, punctuation or spacing compare equal.
+func normalizeWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+func exactKey(words []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(words, " ")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// dedupIndex is the on-disk form of a Deduplicator's state.
+type dedupIndex struct {
+	Threshold  float64    `json:"threshold"`
+	Hashes     []string   `json:"hashes"`
+	Signatures [][]uint32 `json:"signatures,omitempty"`
+}
+
+// LoadIndex merges a persisted index from an earlier run into d, so chunks
+// already emitted by that run are treated as seen. A missing file is not an error.
+func (d *Deduplicator) LoadIndex(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read dedup index: %v", err)
+	}
+	var idx dedupIndex
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return fmt.Errorf("failed to parse dedup index: %v", err)
+	}
+	for _, h := range idx.Hashes {
+		d.exact[h] = struct{}{}
+	}
+	for _, sig := range idx.Signatures {
+		if len(sig) == minHashPermutations {
+			d.addSignature(sig)
+		}
+	}
+	return nil
+}
+
+// SaveIndex writes the hashes and signatures of every chunk seen so far.
+func (d *Deduplicator) SaveIndex(path string) error {
+	idx := dedupIndex{Threshold: d.Threshold, Hashes: make([]string, 0, len(d.exact)), Signatures: d.signatures}
+	for h := range d.exact {
+		idx.Hashes = append(idx.Hashes, h)
+	}
+	sort.Strings(idx.Hashes)
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write dedup index: %v", err)
+	}
+	return nil
+}
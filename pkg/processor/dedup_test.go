@@ -0,0 +1,243 @@
+package processor
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// dedupText returns 200 distinct words with changed words replaced at
+// evenly spaced positions. Every changed word breaks the five shingles it
+// is part of, so one change leaves a Jaccard similarity of about 0.95 to
+// the unchanged text and four about 0.81.
+func dedupText(changed int) string {
+	words := make([]string, 200)
+	for i := range words {
+		words[i] = fmt.Sprintf("w%d", i)
+	}
+	for j := 0; j < changed; j++ {
+		words[(j+1)*len(words)/(changed+1)] = "changed"
+	}
+	return strings.Join(words, " ")
+}
+
+// shingleJaccard is the exact Jaccard similarity of the shingles of a and b.
+func shingleJaccard(a, b string) float64 {
+	sa, sb := make(map[uint64]bool), make(map[uint64]bool)
+	for _, s := range shingles(normalizeWords(a)) {
+		sa[s] = true
+	}
+	for _, s := range shingles(normalizeWords(b)) {
+		sb[s] = true
+	}
+	same := 0
+	for s := range sa {
+		if sb[s] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(sa)+len(sb)-same)
+}
+
+func TestDeduplicatorExact(t *testing.T) {
+	for _, mode := range []DedupMode{DedupExact, DedupNear} {
+		d, err := NewDeduplicator(mode, 0.8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tc := range []struct {
+			text string
+			keep bool
+		}{
+			{"Hello, World! This is a test.", true},
+			{"hello world this is a test", false},
+			{"  HELLO\tworld -- this IS a test...", false},
+			{"Hello, World! This is another test.", true},
+			{"", true},
+			{"?!", false},
+		} {
+			if got := d.Keep(tc.text); got != tc.keep {
+				t.Errorf("%s: Keep(%q) = %v, want %v", mode, tc.text, got, tc.keep)
+			}
+		}
+		if d.ExactRemoved != 3 || d.NearRemoved != 0 {
+			t.Errorf("%s: removed %d exact and %d near duplicates, want 3 and 0", mode, d.ExactRemoved, d.NearRemoved)
+		}
+	}
+
+	// Exact mode keeps near-duplicates; a nil or off Deduplicator keeps all.
+	d, _ := NewDeduplicator(DedupExact, 0.5)
+	if !d.Keep(dedupText(0)) || !d.Keep(dedupText(1)) {
+		t.Error("exact mode removed a near-duplicate")
+	}
+	var none *Deduplicator
+	off, _ := NewDeduplicator(DedupOff, 0.5)
+	for _, d := range []*Deduplicator{none, off} {
+		if !d.Keep("same") || !d.Keep("same") {
+			t.Error("a disabled Deduplicator removed a duplicate")
+		}
+	}
+}
+
+func TestDeduplicatorNear(t *testing.T) {
+	base := dedupText(0)
+	if j := shingleJaccard(base, dedupText(4)); j < 0.8 || j > 0.82 {
+		t.Fatalf("four changes leave a similarity of %v", j)
+	}
+	if j := shingleJaccard(base, dedupText(5)); j < 0.76 || j > 0.8 {
+		t.Fatalf("five changes leave a similarity of %v", j)
+	}
+	probe, _ := NewDeduplicator(DedupNear, 0.8)
+	estimate := estimateJaccard(probe.signature(normalizeWords(base)), probe.signature(normalizeWords(dedupText(4))))
+
+	for _, tc := range []struct {
+		name      string
+		threshold float64
+		changed   int
+		removed   bool
+	}{
+		{"above the threshold", 0.8, 1, true},
+		{"at the threshold", 0.8, 4, true},
+		{"just below the threshold", 0.8, 5, false},
+		{"well below the threshold", 0.8, 8, false},
+		{"estimate equals the threshold", estimate, 4, true},
+		{"estimate one permutation short", estimate + 1.0/minHashPermutations, 4, false},
+		{"low threshold", 0.5, 8, true},
+		{"threshold 1 is exact", 1, 1, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := NewDeduplicator(DedupNear, tc.threshold)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !d.Keep(base) {
+				t.Fatal("first text removed")
+			}
+			if kept := d.Keep(dedupText(tc.changed)); kept == tc.removed {
+				t.Errorf("Keep with %d changes at threshold %v = %v, want %v", tc.changed, tc.threshold, kept, !tc.removed)
+			}
+			if want := map[bool]int{true: 1}[tc.removed]; d.NearRemoved != want || d.ExactRemoved != 0 {
+				t.Errorf("removed %d near and %d exact duplicates", d.NearRemoved, d.ExactRemoved)
+			}
+		})
+	}
+}
+
+func TestLSHParams(t *testing.T) {
+	for _, tc := range []struct {
+		threshold   float64
+		bands, rows int
+	}{
+		{0.5, 64, 2},
+		{0.7, 32, 4},
+		{0.8, 32, 4},
+		{0.9, 16, 8},
+		{0.95, 8, 16},
+		{1, 1, 128},
+	} {
+		bands, rows := lshParams(minHashPermutations, tc.threshold)
+		if bands != tc.bands || rows != tc.rows {
+			t.Errorf("lshParams(%d, %v) = %d bands of %d rows, want %d of %d", minHashPermutations, tc.threshold, bands, rows, tc.bands, tc.rows)
+		}
+	}
+	// Every split uses all permutations, finds a pair at the threshold
+	// with probability lshRecall, unless not even one row per band does,
+	// and no split with more rows does.
+	recall := func(t float64, b, r int) float64 { return 1 - math.Pow(1-math.Pow(t, float64(r)), float64(b)) }
+	for _, threshold := range []float64{0.1, 0.3, 0.55, 0.75, 0.85, 0.99} {
+		for _, n := range []int{12, 100, 128} {
+			bands, rows := lshParams(n, threshold)
+			if bands*rows != n || (rows > 1 && recall(threshold, bands, rows) < lshRecall) {
+				t.Fatalf("lshParams(%d, %v) = %d x %d with recall %v", n, threshold, bands, rows, recall(threshold, bands, rows))
+			}
+			for r := rows + 1; r <= n; r++ {
+				if n%r == 0 && recall(threshold, n/r, r) >= lshRecall {
+					t.Errorf("lshParams(%d, %v) = %d x %d, but %d x %d also reaches the recall", n, threshold, bands, rows, n/r, r)
+				}
+			}
+		}
+	}
+}
+
+func TestDeduplicatorIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dedup.json")
+	first, _ := NewDeduplicator(DedupNear, 0.8)
+	for _, text := range []string{"Seen in the first run.", dedupText(0)} {
+		first.Keep(text)
+	}
+	if err := first.SaveIndex(path); err != nil {
+		t.Fatalf("SaveIndex: %v", err)
+	}
+
+	second, _ := NewDeduplicator(DedupNear, 0.8)
+	if err := second.LoadIndex(path); err != nil {
+		t.Fatalf("LoadIndex: %v", err)
+	}
+	for _, tc := range []struct {
+		text string
+		keep bool
+	}{
+		{"seen in the FIRST run", false},
+		{dedupText(1), false},
+		{"New in the second run.", true},
+	} {
+		if got := second.Keep(tc.text); got != tc.keep {
+			t.Errorf("after LoadIndex, Keep(%.30q) = %v, want %v", tc.text, got, tc.keep)
+		}
+	}
+	if second.ExactRemoved != 1 || second.NearRemoved != 1 {
+		t.Errorf("removed %d exact and %d near duplicates, want 1 and 1", second.ExactRemoved, second.NearRemoved)
+	}
+
+	// Saving again keeps the earlier run's entries.
+	if err := second.SaveIndex(path); err != nil {
+		t.Fatal(err)
+	}
+	third, _ := NewDeduplicator(DedupExact, 0.8)
+	if err := third.LoadIndex(path); err != nil {
+		t.Fatal(err)
+	}
+	if third.Keep("Seen in the first run") || third.Keep("new in the second run") {
+		t.Error("entries lost in the second save")
+	}
+
+	missing, _ := NewDeduplicator(DedupNear, 0.8)
+	if err := missing.LoadIndex(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Errorf("LoadIndex of a missing file: %v", err)
+	}
+	if !missing.Keep("anything") {
+		t.Error("a missing index marked a chunk as seen")
+	}
+	corrupt := filepath.Join(t.TempDir(), "corrupt.json")
+	os.WriteFile(corrupt, []byte("{not json"), 0644)
+	if err := missing.LoadIndex(corrupt); err == nil {
+		t.Error("LoadIndex accepted a corrupt file")
+	}
+}
+
+func TestNewDeduplicatorThreshold(t *testing.T) {
+	for _, tc := range []struct {
+		threshold float64
+		ok        bool
+	}{
+		{0, false}, {-0.5, false}, {1.01, false}, {2, false}, {math.NaN(), false},
+		{0.01, true}, {0.8, true}, {1, true},
+	} {
+		if _, err := NewDeduplicator(DedupNear, tc.threshold); (err == nil) != tc.ok {
+			t.Errorf("NewDeduplicator(%v) error = %v", tc.threshold, err)
+		}
+	}
+	for _, tc := range []struct {
+		in   string
+		want DedupMode
+	}{
+		{"", DedupOff}, {"off", DedupOff}, {" Exact ", DedupExact}, {"NEAR", DedupNear}, {"fuzzy", ""},
+	} {
+		if got, err := ParseDedupMode(tc.in); got != tc.want || (err == nil) != (tc.want != "") {
+			t.Errorf("ParseDedupMode(%q) = %q, %v", tc.in, got, err)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+// processor/embed.go
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Embedder turns texts into vectors. Vectors of one call must share a dimension.
+type Embedder interface {
+	Embed(texts []string) ([][]float64, error)
+}
+
+// HashingEmbedder is an offline embedder: TF-IDF weighted word unigrams and
+// bigrams, feature-hashed into a fixed number of dimensions. IDF is computed
+// over the texts of each Embed call, so related calls should be batched.
+type HashingEmbedder struct {
+	Dimensions int
+}
+
+// NewHashingEmbedder returns a HashingEmbedder; dims <= 0 selects 512.
+func NewHashingEmbedder(dims int) *HashingEmbedder {
+	if dims <= 0 {
+		dims = 512
+	}
+	return &HashingEmbedder{Dimensions: dims}
+}
+
+// Embed returns one L2-normalized vector per text.
+func (e *HashingEmbedder) Embed(texts []string) ([][]float64, error) {
+	terms := make([]map[string]int, len(texts))
+	df := make(map[string]int)
+	for i, t := range texts {
+		words := normalizeWords(t)
+		terms[i] = make(map[string]int)
+		for j, w := range words {
+			terms[i][w]++
+			if j > 0 {
+				terms[i][words[j-1]+" "+w]++
+			}
+		}
+		for term := range terms[i] {
+			df[term]++
+		}
+	}
+
+	n := float64(len(texts))
+	vectors := make([][]float64, len(texts))
+	for i := range texts {
+		v := make([]float64, e.Dimensions)
+		for term, tf := range terms[i] {
+			h := fnv.New32a()
+			h.Write([]byte(term))
+			sum := h.Sum32()
+			idf := math.Log((1+n)/(1+float64(df[term]))) + 1
+			weight := float64(tf) * idf
+			// The top bit picks the sign so that hash collisions tend to cancel.
+			if sum&0x80000000 != 0 {
+				weight = -weight
+			}
+			v[int(sum&0x7fffffff)%e.Dimensions] += weight
+		}
+		normalize(v)
+		vectors[i] = v
+	}
+	return vectors, nil
+}
+
+// DefaultEmbedBatchSize is the number of texts HTTPEmbedder sends per request.
+const DefaultEmbedBatchSize = 64
+
+// HTTPEmbedder calls an OpenAI-compatible /v1/embeddings endpoint, such as
+// the ones served by llama.cpp, Ollama or vLLM.
+type HTTPEmbedder struct {
+	URL    string
+	Model  string
+	APIKey string
+	Client *http.Client
+	// BatchSize is the most texts sent in one request, so that large
+	// documents stay within the endpoint's input limits; zero or less
+	// means DefaultEmbedBatchSize.
+	BatchSize int
+}
+
+// NewHTTPEmbedder returns an HTTPEmbedder with a 60 second timeout.
+func NewHTTPEmbedder(url, model, apiKey string) *HTTPEmbedder {
+	return &HTTPEmbedder{URL: url, Model: model, APIKey: apiKey, Client: &http.Client{Timeout: 60 * time.Second}}
+}
+
+// Embed sends texts in batches of BatchSize and returns the vectors in
+// input order.
+func (e *HTTPEmbedder) Embed(texts []string) ([][]float64, error) {
+	size := e.BatchSize
+	if size <= 0 {
+		size = DefaultEmbedBatchSize
+	}
+	vectors := make([][]float64, 0, len(texts))
+	for start := 0; start < len(texts); start += size {
+		batch, err := e.embedBatch(texts[start:min(start+size, len(texts))])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+// embedBatch sends texts in one request.
+func (e *HTTPEmbedder) embedBatch(texts []string) ([][]float64, error) {
+	body, err := json.Marshal(map[string]interface{}{"model": e.Model, "input": texts})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.APIKey)
+	}
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embedding request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embedding request failed: %s", resp.Status)
+	}
+
+	var out struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to decode embedding response: %v", err)
+	}
+	if len(out.Data) != len(texts) {
+		return nil, fmt.Errorf("embedding response has %d vectors for %d inputs", len(out.Data), len(texts))
+	}
+	vectors := make([][]float64, len(texts))
+	for _, d := range out.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding response index %d out of range", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, nil
+}
+
+// LoadEmbedder returns the Embedder selected by a flag or API value:
+// "hashing" (or empty) for the offline embedder, "http" for an
+// OpenAI-compatible endpoint at url that is sent batchSize texts per
+// request.
+func LoadEmbedder(kind, url, model, apiKey string, batchSize int) (Embedder, error) {
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case "", "hashing":
+		return NewHashingEmbedder(0), nil
+	case "http":
+		if url == "" {
+			return nil, fmt.Errorf("http embedder needs an endpoint URL")
+		}
+		if batchSize < 0 {
+			return nil, fmt.Errorf("embedding batch size must not be negative, got %d", batchSize)
+		}
+		e := NewHTTPEmbedder(url, model, apiKey)
+		e.BatchSize = batchSize
+		return e, nil
+	default:
+		return nil, fmt.Errorf("unknown embedder %q (want hashing or http)", kind)
+	}
+}
+
+func normalize(v []float64) {
+	norm := 0.0
+	for _, x := range v {
+		norm += x * x
+	}
+	if norm == 0 {
+		return
+	}
+	norm = math.Sqrt(norm)
+	for i := range v {
+		v[i] /= norm
+	}
+}
+
+// cosineSimilarity returns the cosine of the angle between a and b.
+func cosineSimilarity(a, b []float64) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
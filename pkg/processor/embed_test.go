@@ -0,0 +1,95 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// mockEmbeddings answers /v1/embeddings requests for texts "t<n>" with the
+// vector [n], listing the data in reverse order, and records the size of
+// every request.
+type mockEmbeddings struct {
+	mu      sync.Mutex
+	batches []int
+}
+
+func (m *mockEmbeddings) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Input []string `json:"input"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	m.mu.Lock()
+	m.batches = append(m.batches, len(req.Input))
+	m.mu.Unlock()
+	type datum struct {
+		Index     int       `json:"index"`
+		Embedding []float64 `json:"embedding"`
+	}
+	var data []datum
+	for i := len(req.Input) - 1; i >= 0; i-- {
+		n, err := strconv.Atoi(strings.TrimPrefix(req.Input[i], "t"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data = append(data, datum{Index: i, Embedding: []float64{float64(n)}})
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+func TestHTTPEmbedderBatches(t *testing.T) {
+	for _, tc := range []struct {
+		texts, batchSize int
+		want             []int
+	}{
+		{0, 4, nil},
+		{3, 4, []int{3}},
+		{10, 4, []int{4, 4, 2}},
+		{8, 4, []int{4, 4}},
+		{130, 0, []int{DefaultEmbedBatchSize, DefaultEmbedBatchSize, 2}},
+	} {
+		t.Run(fmt.Sprintf("%d texts by %d", tc.texts, tc.batchSize), func(t *testing.T) {
+			mock := &mockEmbeddings{}
+			srv := httptest.NewServer(mock)
+			defer srv.Close()
+			e, err := LoadEmbedder("http", srv.URL, "test", "", tc.batchSize)
+			if err != nil {
+				t.Fatalf("LoadEmbedder: %v", err)
+			}
+			texts := make([]string, tc.texts)
+			for i := range texts {
+				texts[i] = fmt.Sprintf("t%d", i)
+			}
+			vectors, err := e.Embed(texts)
+			if err != nil {
+				t.Fatalf("Embed: %v", err)
+			}
+			if fmt.Sprint(mock.batches) != fmt.Sprint(tc.want) {
+				t.Errorf("request sizes = %v, want %v", mock.batches, tc.want)
+			}
+			if len(vectors) != len(texts) {
+				t.Fatalf("%d vectors for %d texts", len(vectors), len(texts))
+			}
+			for i, v := range vectors {
+				if len(v) != 1 || v[0] != float64(i) {
+					t.Errorf("vector %d = %v, want [%d]", i, v, i)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadEmbedderNegativeBatch(t *testing.T) {
+	if _, err := LoadEmbedder("http", "http://localhost/v1/embeddings", "", "", -1); err == nil {
+		t.Error("LoadEmbedder accepted a negative batch size")
+	}
+}
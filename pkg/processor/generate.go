@@ -0,0 +1,433 @@
+// processor/generate.go
+package processor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// GenerationTask selects what Generator asks the model to write for a chunk.
+type GenerationTask string
+
+const (
+	// GenerateQA writes question/answer pairs answerable from the chunk.
+	GenerateQA GenerationTask = "qa"
+	// GenerateSummary writes a summary; the sample's instruction comes
+	// from the instruction template and the chunk is its input.
+	GenerateSummary GenerationTask = "summary"
+	// GenerateDialogue writes a multi-turn conversation about the chunk.
+	GenerateDialogue GenerationTask = "dialogue"
+)
+
+// ParseGenerationTask validates a -generate flag or API value; "" and
+// "off" disable generation and return "".
+func ParseGenerationTask(s string) (GenerationTask, error) {
+	switch t := GenerationTask(strings.ToLower(strings.TrimSpace(s))); t {
+	case "", "off":
+		return "", nil
+	case GenerateQA, GenerateSummary, GenerateDialogue:
+		return t, nil
+	default:
+		return "", fmt.Errorf("unknown generation task %q (want off, qa, summary or dialogue)", s)
+	}
+}
+
+// Turn is one message of a generated sample.
+type Turn struct {
+	// Role is "user" or "assistant".
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// GeneratedSample is an instruction-tuning example generated from a chunk.
+type GeneratedSample struct {
+	// Turns alternate between user and assistant, starting with the user.
+	// An empty first user turn is filled with the sample's rendered
+	// instruction when it is written.
+	Turns []Turn
+	// Context, if set, is the chunk text the first user turn refers to.
+	Context string
+}
+
+// defaultGenerationPrompts holds the system prompt and the user prompt
+// template of every task. Prompts of tasks answered in JSON spell out the
+// expected keys, which the mock endpoint relies on as well.
+var defaultGenerationPrompts = map[GenerationTask][2]string{
+	GenerateQA: {
+		`You write question and answer pairs for training a language model. Questions must be answerable from the given text alone, answers must be correct and complete. Reply with only a JSON array of objects with "question" and "answer" keys.`,
+		"Write {{.N}} question and answer pairs about this text:\n\n{{.Text}}",
+	},
+	GenerateSummary: {
+		"You summarize texts accurately and concisely, without adding information.",
+		"Summarize this text:\n\n{{.Text}}",
+	},
+	GenerateDialogue: {
+		`You write realistic conversations between a curious user and a knowledgeable assistant. The assistant only states facts from the given text. Reply with only a JSON array of messages with "role" ("user" or "assistant") and "content" keys, starting with the user.`,
+		"Write a conversation of {{.N}} exchanges about this text:\n\n{{.Text}}",
+	},
+}
+
+// GenerationPromptData is what a user prompt template can reference.
+type GenerationPromptData struct {
+	Text    string
+	Source  string
+	Page    int
+	Section string
+	// N is Generator.Pairs: question/answer pairs or dialogue exchanges.
+	N int
+}
+
+// GenerationStats summarizes a generation run.
+type GenerationStats struct {
+	Chunks    int
+	Samples   int
+	CacheHits int
+	// Failed counts chunks dropped because the model gave no usable
+	// answer within the retries; FirstError tells why.
+	Failed     int
+	FirstError string
+}
+
+// Generator sends chunks to an OpenAI-compatible /v1/chat/completions
+// endpoint, such as the ones served by llama.cpp, Ollama or vLLM, and
+// attaches the samples it writes to every chunk.
+type Generator struct {
+	URL    string
+	Model  string
+	APIKey string
+	Client *http.Client
+	Task   GenerationTask
+	// System and Prompt override the default prompts of Task. Prompt is a
+	// text/template over GenerationPromptData.
+	System string
+	Prompt string
+	// Pairs is the number of question/answer pairs or dialogue exchanges
+	// requested per chunk.
+	Pairs       int
+	Temperature float64
+	// WithContext puts the chunk into question/answer and dialogue samples
+	// as well, for retrieval-style training data.
+	WithContext bool
+	// Concurrency bounds the requests in flight; Retries is the number of
+	// further attempts after a failed request or an unusable answer,
+	// waiting Backoff, then twice as long, and so on.
+	Concurrency int
+	Retries     int
+	Backoff     time.Duration
+	// CacheDir, if set, stores every answer under a hash of the request
+	// body (model, prompts and temperature), so that reruns only ask for
+	// chunks that changed.
+	CacheDir string
+
+	prompt *template.Template
+}
+
+// NewGenerator returns a Generator for task with the default prompts, 3
+// pairs per chunk, 4 concurrent requests, 3 retries starting at one second
+// and a 5 minute request timeout.
+func NewGenerator(task GenerationTask, url, model, apiKey string) *Generator {
+	return &Generator{
+		URL:         url,
+		Model:       model,
+		APIKey:      apiKey,
+		Client:      &http.Client{Timeout: 5 * time.Minute},
+		Task:        task,
+		Pairs:       3,
+		Temperature: 0.7,
+		Concurrency: 4,
+		Retries:     3,
+		Backoff:     time.Second,
+	}
+}
+
+// Validate checks the settings and parses the prompt template.
+func (g *Generator) Validate() error {
+	prompts, ok := defaultGenerationPrompts[g.Task]
+	if !ok {
+		return fmt.Errorf("unknown generation task %q", g.Task)
+	}
+	if g.URL == "" {
+		return fmt.Errorf("generation needs a chat completions endpoint URL")
+	}
+	if g.Pairs < 1 {
+		return fmt.Errorf("generation pairs must be at least 1, got %d", g.Pairs)
+	}
+	if g.Concurrency < 1 {
+		return fmt.Errorf("generation concurrency must be at least 1, got %d", g.Concurrency)
+	}
+	if g.Retries < 0 {
+		return fmt.Errorf("generation retries must not be negative, got %d", g.Retries)
+	}
+	prompt := g.Prompt
+	if prompt == "" {
+		prompt = prompts[1]
+	}
+	tmpl, err := template.New("prompt").Parse(prompt)
+	if err == nil {
+		err = tmpl.Execute(&bytes.Buffer{}, GenerationPromptData{})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse generation prompt: %v", err)
+	}
+	g.prompt = tmpl
+	return nil
+}
+
+// errUnusable marks an answer that arrived but could not be parsed.
+var errUnusable = errors.New("unusable answer")
+
+// Generate asks the model about every chunk and returns the chunks with
+// their GeneratedSamples attached, in input order. Chunks the model gave
+// no usable answer for are dropped; an endpoint that stays unreachable
+// fails the run. progress, if set, is called after each chunk.
+func (g *Generator) Generate(chunks []Chunk, progress func(done, total int)) ([]Chunk, GenerationStats, error) {
+	stats := GenerationStats{Chunks: len(chunks)}
+	if err := g.Validate(); err != nil {
+		return nil, stats, err
+	}
+	if g.CacheDir != "" {
+		if err := os.MkdirAll(g.CacheDir, 0755); err != nil {
+			return nil, stats, fmt.Errorf("failed to create generation cache: %v", err)
+		}
+	}
+	type result struct {
+		samples []GeneratedSample
+		cached  bool
+		err     error
+	}
+	results := make([]result, len(chunks))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	done, stop := 0, false
+	for w := 0; w < g.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				samples, cached, err := g.generateChunk(chunks[i])
+				results[i] = result{samples, cached, err}
+				mu.Lock()
+				done++
+				// An endpoint that fails beyond the retries will fail the
+				// other chunks too, so no further chunks are sent.
+				stop = stop || err != nil && !errors.Is(err, errUnusable)
+				if progress != nil {
+					progress(done, len(chunks))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range chunks {
+		mu.Lock()
+		failed := stop
+		mu.Unlock()
+		if failed {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	kept := make([]Chunk, 0, len(chunks))
+	for i, r := range results {
+		switch {
+		case errors.Is(r.err, errUnusable):
+			stats.Failed++
+			if stats.FirstError == "" {
+				stats.FirstError = fmt.Sprintf("%s: %v", chunks[i].Source, r.err)
+			}
+		case r.err != nil:
+			return nil, stats, r.err
+		default:
+			if r.cached {
+				stats.CacheHits++
+			}
+			c := chunks[i]
+			c.Generated = r.samples
+			stats.Samples += len(r.samples)
+			kept = append(kept, c)
+		}
+	}
+	return kept, stats, nil
+}
+
+// chatMessage is a message of a chat completions request or answer.
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// chatRequest is the body of a chat completions request.
+type chatRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	Temperature float64       `json:"temperature"`
+	Stream      bool          `json:"stream"`
+}
+
+// generateChunk returns the samples for one chunk, from the cache if possible.
+func (g *Generator) generateChunk(chunk Chunk) ([]GeneratedSample, bool, error) {
+	var prompt strings.Builder
+	if err := g.prompt.Execute(&prompt, GenerationPromptData{
+		Text: chunk.Text, Source: chunk.Source, Page: chunk.Page, Section: chunk.Section, N: g.Pairs,
+	}); err != nil {
+		return nil, false, fmt.Errorf("failed to render generation prompt: %v", err)
+	}
+	system := g.System
+	if system == "" {
+		system = defaultGenerationPrompts[g.Task][0]
+	}
+	req := chatRequest{
+		Model:       g.Model,
+		Messages:    []chatMessage{{Role: "system", Content: system}, {Role: "user", Content: prompt.String()}},
+		Temperature: g.Temperature,
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, false, err
+	}
+	key := sha256.Sum256(body)
+	cachePath := ""
+	if g.CacheDir != "" {
+		cachePath = filepath.Join(g.CacheDir, hex.EncodeToString(key[:])+".txt")
+		if answer, err := os.ReadFile(cachePath); err == nil {
+			if samples, err := g.parseAnswer(string(answer), chunk.Text); err == nil {
+				return samples, true, nil
+			}
+		}
+	}
+
+	wait := g.Backoff
+	for attempt := 0; ; attempt++ {
+		answer, err := g.complete(body)
+		var samples []GeneratedSample
+		if err == nil {
+			if samples, err = g.parseAnswer(answer, chunk.Text); err == nil {
+				if cachePath != "" {
+					if err := os.WriteFile(cachePath, []byte(answer), 0644); err != nil {
+						return nil, false, fmt.Errorf("failed to write generation cache: %v", err)
+					}
+				}
+				return samples, false, nil
+			}
+		}
+		if attempt >= g.Retries {
+			return nil, false, err
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+// complete sends one chat completions request and returns the answer text.
+// An answer without text is reported as errUnusable.
+func (g *Generator) complete(body []byte) (string, error) {
+	req, err := http.NewRequest(http.MethodPost, g.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if g.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+g.APIKey)
+	}
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("generation request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("generation request failed: %s", resp.Status)
+	}
+	var out struct {
+		Choices []struct {
+			Message chatMessage `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("failed to decode generation response: %v", err)
+	}
+	if len(out.Choices) == 0 || strings.TrimSpace(out.Choices[0].Message.Content) == "" {
+		return "", fmt.Errorf("%w: empty answer", errUnusable)
+	}
+	return out.Choices[0].Message.Content, nil
+}
+
+// parseAnswer turns the model's answer into samples for the task.
+func (g *Generator) parseAnswer(answer, text string) ([]GeneratedSample, error) {
+	context := ""
+	if g.WithContext {
+		context = text
+	}
+	switch g.Task {
+	case GenerateSummary:
+		summary := strings.TrimSpace(answer)
+		return []GeneratedSample{{Turns: []Turn{{Role: "user"}, {Role: "assistant", Content: summary}}, Context: text}}, nil
+	case GenerateQA:
+		var pairs []struct {
+			Question string `json:"question"`
+			Answer   string `json:"answer"`
+		}
+		if err := json.Unmarshal([]byte(jsonArray(answer)), &pairs); err != nil {
+			return nil, fmt.Errorf("%w: %v", errUnusable, err)
+		}
+		var samples []GeneratedSample
+		for _, p := range pairs {
+			q, a := strings.TrimSpace(p.Question), strings.TrimSpace(p.Answer)
+			if q != "" && a != "" {
+				samples = append(samples, GeneratedSample{Turns: []Turn{{"user", q}, {"assistant", a}}, Context: context})
+			}
+		}
+		if len(samples) == 0 {
+			return nil, fmt.Errorf("%w: no question/answer pairs", errUnusable)
+		}
+		return samples, nil
+	default:
+		var messages []Turn
+		if err := json.Unmarshal([]byte(jsonArray(answer)), &messages); err != nil {
+			return nil, fmt.Errorf("%w: %v", errUnusable, err)
+		}
+		var turns []Turn
+		for _, m := range messages {
+			role, content := strings.ToLower(strings.TrimSpace(m.Role)), strings.TrimSpace(m.Content)
+			want := "user"
+			if len(turns)%2 == 1 {
+				want = "assistant"
+			}
+			if role != want || content == "" {
+				break
+			}
+			turns = append(turns, Turn{role, content})
+		}
+		if len(turns)%2 == 1 {
+			turns = turns[:len(turns)-1]
+		}
+		if len(turns) == 0 {
+			return nil, fmt.Errorf("%w: no user/assistant exchange", errUnusable)
+		}
+		return []GeneratedSample{{Turns: turns, Context: context}}, nil
+	}
+}
+
+// jsonArray returns the outermost [...] of s, dropping the Markdown code
+// fences and chatter models tend to put around JSON.
+func jsonArray(s string) string {
+	start, end := strings.Index(s, "["), strings.LastIndex(s, "]")
+	if start < 0 || end < start {
+		return s
+	}
+	return s[start : end+1]
+}
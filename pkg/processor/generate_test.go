@@ -0,0 +1,245 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// MockChat is an OpenAI-compatible chat completions endpoint that answers
+// from the text in the prompt without a model. It recognizes the task by
+// the JSON keys the default system prompts ask for: question/answer pairs
+// and dialogues are built from the first sentences of the text, a summary
+// is its first sentence.
+type MockChat struct {
+	// FailEvery, if positive, answers every FailEvery-th request with
+	// 503 Service Unavailable, to exercise retries.
+	FailEvery int
+	// Delay holds every answer back, so that concurrent requests overlap.
+	Delay time.Duration
+	// Unusable answers prose instead of JSON for texts containing it.
+	Unusable string
+
+	mu                  sync.Mutex
+	requests            int
+	inFlight, maxFlight int
+}
+
+// Requests returns the number of requests served so far.
+func (m *MockChat) Requests() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.requests
+}
+
+// MaxInFlight returns the most requests that were served at once.
+func (m *MockChat) MaxInFlight() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.maxFlight
+}
+
+func (m *MockChat) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	m.requests++
+	fail := m.FailEvery > 0 && m.requests%m.FailEvery == 0
+	m.inFlight++
+	if m.inFlight > m.maxFlight {
+		m.maxFlight = m.inFlight
+	}
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.inFlight--
+		m.mu.Unlock()
+	}()
+	time.Sleep(m.Delay)
+	if fail {
+		http.Error(w, "mock failure", http.StatusServiceUnavailable)
+		return
+	}
+	var req chatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Messages) == 0 {
+		http.Error(w, "invalid chat request", http.StatusBadRequest)
+		return
+	}
+	system, prompt := "", req.Messages[len(req.Messages)-1].Content
+	for _, msg := range req.Messages {
+		if msg.Role == "system" {
+			system = msg.Content
+		}
+	}
+	// The default prompts put the text after the first blank line.
+	text := prompt
+	if i := strings.Index(prompt, "\n\n"); i >= 0 {
+		text = prompt[i+2:]
+	}
+	sentences := SplitSentences(text)
+	if len(sentences) > 3 {
+		sentences = sentences[:3]
+	}
+
+	var answer string
+	switch {
+	case m.Unusable != "" && strings.Contains(text, m.Unusable):
+		answer = "Sorry, I cannot help with that."
+	case strings.Contains(system, `"question"`):
+		pairs := make([]map[string]string, len(sentences))
+		for i, s := range sentences {
+			pairs[i] = map[string]string{"question": fmt.Sprintf("What does sentence %d of the text say?", i+1), "answer": s}
+		}
+		b, _ := json.MarshalIndent(pairs, "", "  ")
+		answer = "```json\n" + string(b) + "\n```"
+	case strings.Contains(system, `"role"`):
+		var turns []Turn
+		for _, s := range sentences {
+			turns = append(turns, Turn{"user", "What comes next?"}, Turn{"assistant", s})
+		}
+		b, _ := json.Marshal(turns)
+		answer = string(b)
+	default:
+		if len(sentences) > 0 {
+			answer = sentences[0]
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"object": "chat.completion",
+		"model":  req.Model,
+		"choices": []map[string]interface{}{
+			{"index": 0, "message": chatMessage{Role: "assistant", Content: answer}, "finish_reason": "stop"},
+		},
+	})
+}
+
+func testChunks(n int) []Chunk {
+	chunks := make([]Chunk, n)
+	for i := range chunks {
+		chunks[i] = Chunk{
+			Text:   fmt.Sprintf("Chunk %d talks about goats. Goats climb trees in Morocco.", i),
+			Source: fmt.Sprintf("doc%d.txt", i),
+		}
+	}
+	return chunks
+}
+
+func testGenerator(url string, task GenerationTask) *Generator {
+	g := NewGenerator(task, url, "test-model", "")
+	g.Backoff = time.Millisecond
+	return g
+}
+
+func TestGenerateRetries(t *testing.T) {
+	mock := &MockChat{FailEvery: 2}
+	server := httptest.NewServer(mock)
+	defer server.Close()
+
+	g := testGenerator(server.URL, GenerateQA)
+	g.Concurrency, g.Retries = 1, 1
+	chunks, stats, err := g.Generate(testChunks(4), nil)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	// Every chunk but the first needs a second attempt.
+	if got := mock.Requests(); got != 7 {
+		t.Errorf("requests = %d, want 7", got)
+	}
+	if len(chunks) != 4 || stats.Samples != 8 || stats.Failed != 0 {
+		t.Errorf("got %d chunks, %d samples, %d failed; want 4, 8, 0", len(chunks), stats.Samples, stats.Failed)
+	}
+	for i, c := range chunks {
+		if want := fmt.Sprintf("doc%d.txt", i); c.Source != want {
+			t.Errorf("chunk %d source = %q, want %q", i, c.Source, want)
+		}
+	}
+
+	g = testGenerator(server.URL, GenerateQA)
+	g.Concurrency, g.Retries = 1, 0
+	if _, _, err := g.Generate(testChunks(4), nil); err == nil {
+		t.Error("Generate without retries succeeded against a failing endpoint")
+	}
+}
+
+func TestGenerateConcurrencyBound(t *testing.T) {
+	for _, concurrency := range []int{1, 3} {
+		mock := &MockChat{Delay: 20 * time.Millisecond}
+		server := httptest.NewServer(mock)
+		g := testGenerator(server.URL, GenerateSummary)
+		g.Concurrency = concurrency
+		chunks, _, err := g.Generate(testChunks(12), nil)
+		server.Close()
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+		if len(chunks) != 12 {
+			t.Errorf("concurrency %d: kept %d chunks, want 12", concurrency, len(chunks))
+		}
+		if got := mock.MaxInFlight(); got > concurrency || (concurrency > 1 && got < 2) {
+			t.Errorf("concurrency %d: %d requests in flight", concurrency, got)
+		}
+	}
+}
+
+func TestGenerateCache(t *testing.T) {
+	mock := &MockChat{}
+	server := httptest.NewServer(mock)
+	defer server.Close()
+	dir := t.TempDir()
+
+	g := testGenerator(server.URL, GenerateDialogue)
+	g.CacheDir = dir
+	first, stats, err := g.Generate(testChunks(5), nil)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if stats.CacheHits != 0 || mock.Requests() != 5 {
+		t.Fatalf("first run: %d cache hits, %d requests; want 0, 5", stats.CacheHits, mock.Requests())
+	}
+
+	second, stats, err := g.Generate(testChunks(5), nil)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if stats.CacheHits != 5 || mock.Requests() != 5 {
+		t.Errorf("second run: %d cache hits, %d requests; want 5, 5", stats.CacheHits, mock.Requests())
+	}
+	a, _ := json.Marshal(first)
+	b, _ := json.Marshal(second)
+	if string(a) != string(b) {
+		t.Errorf("cached samples differ:\n%s\n%s", a, b)
+	}
+
+	// A different temperature is a different request.
+	g.Temperature = 0.1
+	if _, stats, _ = g.Generate(testChunks(1), nil); stats.CacheHits != 0 {
+		t.Errorf("cache hit after changing the temperature")
+	}
+}
+
+func TestGenerateDropsUnusableAnswers(t *testing.T) {
+	mock := &MockChat{Unusable: "Chunk 1 "}
+	server := httptest.NewServer(mock)
+	defer server.Close()
+
+	g := testGenerator(server.URL, GenerateQA)
+	g.Concurrency, g.Retries = 2, 2
+	chunks, stats, err := g.Generate(testChunks(3), nil)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if stats.Failed != 1 || !strings.Contains(stats.FirstError, "doc1.txt") {
+		t.Errorf("failed = %d (%q), want 1 for doc1.txt", stats.Failed, stats.FirstError)
+	}
+	if len(chunks) != 2 || chunks[0].Source != "doc0.txt" || chunks[1].Source != "doc2.txt" {
+		t.Errorf("kept %+v, want doc0.txt and doc2.txt", chunks)
+	}
+	// The unusable answer is asked for again Retries times.
+	if got := mock.Requests(); got != 5 {
+		t.Errorf("requests = %d, want 5", got)
+	}
+}
@@ -0,0 +1,268 @@
+// processor/langid.go
+package processor
+
+import (
+	"embed"
+	"math"
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// LanguageUnknown is returned when a text has too few letters to classify.
+const LanguageUnknown = "und"
+
+// langdata holds one sample text per language; the n-gram profiles are
+// built from it at start-up, so detection needs no files or network.
+//
+//go:embed langdata/*.txt
+var langdata embed.FS
+
+// scriptLanguages maps scripts used by a single language (or a language
+// family we do not distinguish further) straight to an ISO 639-1 code.
+var scriptLanguages = []struct {
+	table *unicode.RangeTable
+	code  string
+}{
+	{unicode.Hangul, "ko"},
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Han, "zh"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Greek, "el"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Thai, "th"},
+	{unicode.Armenian, "hy"},
+	{unicode.Georgian, "ka"},
+}
+
+// langProfile is the smoothed log-probability table of one language's
+// character 1- to 3-grams.
+type langProfile struct {
+	code     string
+	script   *unicode.RangeTable
+	logProb  map[string]float64
+	floorLog float64
+}
+
+// LanguageDetector identifies the language of a text from character n-gram
+// profiles compiled into the binary.
+type LanguageDetector struct {
+	profiles []langProfile
+}
+
+// NewLanguageDetector builds the detector from the embedded profiles.
+func NewLanguageDetector() *LanguageDetector {
+	entries, _ := langdata.ReadDir("langdata")
+	d := &LanguageDetector{}
+	for _, e := range entries {
+		data, err := langdata.ReadFile(path.Join("langdata", e.Name()))
+		if err != nil {
+			continue
+		}
+		text := string(data)
+		counts := ngramCounts(text, 0)
+		total := 0
+		for _, c := range counts {
+			total += c
+		}
+		vocab := float64(len(counts) + 1)
+		p := langProfile{
+			code:     strings.TrimSuffix(e.Name(), ".txt"),
+			script:   dominantScript(text),
+			logProb:  make(map[string]float64, len(counts)),
+			floorLog: math.Log(1 / (float64(total) + vocab)),
+		}
+		for g, c := range counts {
+			p.logProb[g] = math.Log((float64(c) + 1) / (float64(total) + vocab))
+		}
+		d.profiles = append(d.profiles, p)
+	}
+	sort.Slice(d.profiles, func(i, j int) bool { return d.profiles[i].code < d.profiles[j].code })
+	return d
+}
+
+// Languages lists the ISO codes the detector can return.
+func (d *LanguageDetector) Languages() []string {
+	var codes []string
+	for _, p := range d.profiles {
+		codes = append(codes, p.code)
+	}
+	for _, s := range scriptLanguages {
+		codes = append(codes, s.code)
+	}
+	return codes
+}
+
+// maxDetectGrams bounds the work done on long documents.
+const maxDetectGrams = 4000
+
+// detectEvidence is the number of n-grams whose evidence the confidence
+// weighs. Scores are averaged per n-gram and scaled to it, so the
+// confidence reflects how clearly the text's n-grams favour one language
+// rather than how long the text is.
+const detectEvidence = 20
+
+// detectMinGrams is the number of n-grams, about ten words, below which
+// the confidence is scaled down in proportion.
+const detectMinGrams = 100
+
+// Detect returns the ISO 639-1 code of the most likely language of text and
+// a confidence in [0, 1]. Texts without enough letters yield LanguageUnknown.
+//
+// For Latin and Cyrillic text the confidence is the smallest posterior of
+// the detected language over the whole text and over either half of its
+// words, so text mixing two languages scores low, and it is scaled down
+// for texts shorter than detectMinGrams n-grams.
+func (d *LanguageDetector) Detect(text string) (string, float64) {
+	letters := 0
+	scripts := make(map[string]int)
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for _, s := range scriptLanguages {
+			if unicode.Is(s.table, r) {
+				scripts[s.code]++
+				break
+			}
+		}
+	}
+	if letters < 3 {
+		return LanguageUnknown, 0
+	}
+
+	// Kana marks Japanese even when Han characters dominate.
+	if scripts["ja"] > 0 && scripts["ja"]+scripts["zh"] > letters/2 {
+		return "ja", float64(scripts["ja"]+scripts["zh"]) / float64(letters)
+	}
+	best, bestCount := "", 0
+	for code, n := range scripts {
+		if n > bestCount || (n == bestCount && code < best) {
+			best, bestCount = code, n
+		}
+	}
+	if bestCount > letters/2 {
+		return best, float64(bestCount) / float64(letters)
+	}
+
+	script := dominantScript(text)
+	words := letterWords(text)
+	grams, used, total := countNgrams(words, maxDetectGrams)
+	posterior := d.posterior(script, grams, total)
+	if posterior == nil {
+		return LanguageUnknown, 0
+	}
+	lang, conf := "", -1.0
+	for _, p := range d.profiles {
+		if pr, ok := posterior[p.code]; ok && pr > conf {
+			lang, conf = p.code, pr
+		}
+	}
+	half := used / 2
+	for _, part := range [][]string{words[:half], words[half:used]} {
+		if grams, _, n := countNgrams(part, 0); n > 0 {
+			conf = min(conf, d.posterior(script, grams, n)[lang])
+		}
+	}
+	conf *= min(1, float64(total)/detectMinGrams)
+	return lang, math.Round(conf*100) / 100
+}
+
+// posterior returns the probability of each language of script given the
+// n-gram counts grams, which add up to total, under a uniform prior, with
+// the evidence scaled to detectEvidence n-grams. It returns nil if no
+// profile has the script or there are no n-grams.
+func (d *LanguageDetector) posterior(script *unicode.RangeTable, grams map[string]int, total int) map[string]float64 {
+	if total == 0 {
+		return nil
+	}
+	scale := detectEvidence / float64(total)
+	scores := make(map[string]float64)
+	maxScore := math.Inf(-1)
+	for _, p := range d.profiles {
+		if p.script != script {
+			continue
+		}
+		score := 0.0
+		for g, c := range grams {
+			lp, ok := p.logProb[g]
+			if !ok {
+				lp = p.floorLog
+			}
+			score += float64(c) * lp
+		}
+		scores[p.code] = score * scale
+		maxScore = math.Max(maxScore, score*scale)
+	}
+	if len(scores) == 0 {
+		return nil
+	}
+	sum := 0.0
+	for _, s := range scores {
+		sum += math.Exp(s - maxScore)
+	}
+	for code, s := range scores {
+		scores[code] = math.Exp(s-maxScore) / sum
+	}
+	return scores
+}
+
+// dominantScript returns Latin or Cyrillic, whichever covers more letters of text.
+func dominantScript(text string) *unicode.RangeTable {
+	latin, cyrillic := 0, 0
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		}
+	}
+	if cyrillic > latin {
+		return unicode.Cyrillic
+	}
+	return unicode.Latin
+}
+
+// ngramCounts counts the character 1- to 3-grams of the lowercased words of
+// text; see countNgrams. limit caps the number of grams counted; zero means
+// no limit.
+func ngramCounts(text string, limit int) map[string]int {
+	counts, _, _ := countNgrams(letterWords(text), limit)
+	return counts
+}
+
+// letterWords returns the lowercased runs of letters of text.
+func letterWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) })
+}
+
+// countNgrams counts the character 1- to 3-grams of words, padded with '_'
+// at word boundaries. It stops after the word that reaches limit grams, if
+// limit is positive, and returns the counts, the number of words counted
+// and the number of grams.
+func countNgrams(words []string, limit int) (map[string]int, int, int) {
+	counts := make(map[string]int)
+	n := 0
+	for k, w := range words {
+		runes := []rune("_" + w + "_")
+		for size := 1; size <= 3; size++ {
+			for i := 0; i+size <= len(runes); i++ {
+				g := string(runes[i : i+size])
+				if g == "_" {
+					continue
+				}
+				counts[g]++
+				n++
+			}
+		}
+		if limit > 0 && n >= limit {
+			return counts, k + 1, n
+		}
+	}
+	return counts, len(words), n
+}
@@ -0,0 +1,78 @@
+package processor
+
+import (
+	"slices"
+	"testing"
+)
+
+// The sentences are not taken from the embedded samples.
+var langSentences = map[string]string{
+	"de": "Das Wetter war heute Morgen angenehm, also beschlossen wir, zum Markt zu laufen und frisches Gemüse für das Abendessen zu kaufen.",
+	"en": "The weather was pleasant this morning, so we decided to walk to the market and buy fresh vegetables for dinner. Later we met some friends at the cafe near the river.",
+	"es": "El tiempo era agradable esta mañana, así que decidimos caminar al mercado y comprar verduras frescas para la cena.",
+	"fr": "Le temps était agréable ce matin, alors nous avons décidé d'aller au marché à pied pour acheter des légumes frais pour le dîner.",
+	"id": "Cuaca pagi ini menyenangkan, jadi kami memutuskan untuk berjalan ke pasar dan membeli sayuran segar untuk makan malam.",
+	"it": "Il tempo era piacevole stamattina, così abbiamo deciso di andare a piedi al mercato e comprare verdure fresche per la cena.",
+	"nl": "Het weer was vanochtend aangenaam, dus besloten we naar de markt te lopen en verse groenten te kopen voor het avondeten.",
+	"pl": "Pogoda była dziś rano przyjemna, więc postanowiliśmy pójść na targ i kupić świeże warzywa na kolację.",
+	"pt": "O tempo estava agradável esta manhã, então decidimos caminhar até o mercado e comprar legumes frescos para o jantar.",
+	"ru": "Погода была приятной сегодня утром, поэтому мы решили пойти на рынок и купить свежие овощи к ужину.",
+	"sv": "Vädret var behagligt i morse, så vi bestämde oss för att gå till marknaden och köpa färska grönsaker till middagen.",
+	"tr": "Bu sabah hava güzeldi, bu yüzden pazara yürüyüp akşam yemeği için taze sebze almaya karar verdik.",
+	"uk": "Погода була приємною сьогодні вранці, тому ми вирішили піти на ринок і купити свіжі овочі на вечерю.",
+
+	"ja": "今日はとても良い天気なので、公園を散歩しました。",
+	"zh": "今天天气很好，我们去公园散步了。",
+	"ko": "오늘은 날씨가 좋아서 공원에서 산책을 했습니다.",
+	"el": "Ο καιρός ήταν ευχάριστος σήμερα το πρωί.",
+	"ar": "كان الطقس لطيفا هذا الصباح.",
+}
+
+func TestDetectLanguages(t *testing.T) {
+	d := NewLanguageDetector()
+	for lang, text := range langSentences {
+		got, conf := d.Detect(text)
+		if got != lang {
+			t.Errorf("Detect(%s sentence) = %s (%.2f)", lang, got, conf)
+		}
+		if conf < 0.5 || conf > 1 {
+			t.Errorf("%s: confidence %.2f for a clear sentence", lang, conf)
+		}
+		if !slices.Contains(d.Languages(), lang) {
+			t.Errorf("Languages() lacks %s", lang)
+		}
+	}
+	for _, text := range []string{"", "12 + 34 = 46", "a b"} {
+		if got, conf := d.Detect(text); got != LanguageUnknown || conf != 0 {
+			t.Errorf("Detect(%q) = %s, %.2f; want und", text, got, conf)
+		}
+	}
+}
+
+func TestDetectConfidence(t *testing.T) {
+	d := NewLanguageDetector()
+	for _, tc := range []struct {
+		name string
+		text string
+		max  float64
+	}{
+		{"english and french", "The weather was pleasant this morning, so we walked to the market. Le temps était agréable ce matin, alors nous sommes allés au marché.", 0.3},
+		{"english and german", "The weather was pleasant this morning, so we walked to the market. Das Wetter war heute Morgen angenehm, also liefen wir zum Markt.", 0.3},
+		{"spanish and portuguese", "El tiempo era agradable esta mañana. O tempo estava agradável esta manhã.", 0.3},
+		{"two words", "Hello world", 0.5},
+		{"three words", "the big dog", 0.5},
+		{"made-up words", "Xyz Qwrt Zzk", 0.5},
+	} {
+		if lang, conf := d.Detect(tc.text); conf > tc.max {
+			t.Errorf("%s: Detect = %s with confidence %.2f, want at most %.2f", tc.name, lang, conf, tc.max)
+		}
+	}
+
+	// A longer text in one language is more certain than its first words.
+	text := langSentences["en"]
+	_, short := d.Detect(text[:30])
+	_, long := d.Detect(text)
+	if short >= long {
+		t.Errorf("confidence %.2f for the first words, %.2f for the whole text", short, long)
+	}
+}
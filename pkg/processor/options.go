@@ -0,0 +1,220 @@
+// processor/options.go
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidChunkOptions is matched by every *ChunkOptionsError via errors.Is.
+var ErrInvalidChunkOptions = errors.New("invalid chunk options")
+
+// ChunkOptionsError reports which chunk option is invalid and why.
+type ChunkOptionsError struct {
+	Field  string
+	Value  interface{}
+	Reason string
+}
+
+func (e *ChunkOptionsError) Error() string {
+	return fmt.Sprintf("invalid %s %v: %s", e.Field, e.Value, e.Reason)
+}
+
+// Is makes errors.Is(err, ErrInvalidChunkOptions) true for option errors.
+func (e *ChunkOptionsError) Is(target error) bool {
+	return target == ErrInvalidChunkOptions
+}
+
+// TailPolicy says what to do with a final chunk smaller than ChunkOptions.MinSize.
+type TailPolicy string
+
+const (
+	// TailKeep emits small trailing chunks unchanged.
+	TailKeep TailPolicy = "keep"
+	// TailDrop removes a small trailing chunk unless it is the only chunk.
+	TailDrop TailPolicy = "drop"
+	// TailMerge appends a small trailing chunk to the chunk before it.
+	TailMerge TailPolicy = "merge"
+)
+
+// ChunkOptions configures how a document is split into chunks.
+type ChunkOptions struct {
+	// Chunker is one of the Chunker* names; empty selects ChunkerToken.
+	Chunker string
+	// Size is the maximum chunk size: characters for ChunkerFixed, tokens
+	// as measured by Counter otherwise. It is ignored by ChunkerParagraph.
+	Size int
+	// Overlap is the number of characters or tokens shared by consecutive
+	// chunks. It must be smaller than Size.
+	Overlap int
+	// OverlapPercent, if non-zero, replaces Overlap with this percentage of Size.
+	OverlapPercent float64
+	// MinSize is the size below which the last chunk of a document is
+	// handled according to Tail.
+	MinSize int
+	Tail    TailPolicy
+	// Counter measures Size, Overlap and MinSize; nil means whitespace words.
+	Counter TokenCounter
+	// Semantic overrides the default configuration of ChunkerSemantic.
+	Semantic *SemanticChunker
+	// CodeFallback, if set, is called by ChunkFile for every Go source that
+	// ChunkerCode chunks as text because it does not parse.
+	CodeFallback func(source string, err error)
+}
+
+// ParseOverlap parses an overlap given either as a count ("20") or as a
+// percentage of the chunk size ("15%").
+func ParseOverlap(s string) (overlap int, percent float64, err error) {
+	s = strings.TrimSpace(s)
+	if strings.HasSuffix(s, "%") {
+		percent, err = strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(s, "%")), 64)
+		if err != nil {
+			return 0, 0, &ChunkOptionsError{Field: "overlap", Value: s, Reason: "not a percentage"}
+		}
+		return 0, percent, nil
+	}
+	overlap, err = strconv.Atoi(s)
+	if err != nil {
+		return 0, 0, &ChunkOptionsError{Field: "overlap", Value: s, Reason: "not an integer or percentage"}
+	}
+	return overlap, 0, nil
+}
+
+// Validate checks the options and normalizes them: the chunker name is made
+// canonical, a percentage overlap is converted to a count and an empty tail
+// policy becomes TailKeep. Problems are reported as *ChunkOptionsError.
+func (o *ChunkOptions) Validate() error {
+	chunker, err := ParseChunker(o.Chunker)
+	if err != nil {
+		return &ChunkOptionsError{Field: "chunker", Value: o.Chunker, Reason: "want fixed, token, paragraph, recursive, semantic or code"}
+	}
+	o.Chunker = chunker
+	if chunker == ChunkerParagraph {
+		return o.validateTail()
+	}
+
+	if o.Size <= 0 {
+		return &ChunkOptionsError{Field: "chunk size", Value: o.Size, Reason: "must be positive"}
+	}
+	if o.OverlapPercent != 0 {
+		if o.OverlapPercent < 0 || o.OverlapPercent >= 100 {
+			return &ChunkOptionsError{Field: "overlap", Value: fmt.Sprintf("%g%%", o.OverlapPercent), Reason: "percentage must be in [0, 100)"}
+		}
+		o.Overlap = int(math.Floor(float64(o.Size) * o.OverlapPercent / 100))
+		o.OverlapPercent = 0
+	}
+	if o.Overlap < 0 {
+		return &ChunkOptionsError{Field: "overlap", Value: o.Overlap, Reason: "must not be negative"}
+	}
+	if o.Overlap >= o.Size {
+		return &ChunkOptionsError{Field: "overlap", Value: o.Overlap, Reason: fmt.Sprintf("must be smaller than the chunk size %d", o.Size)}
+	}
+	if o.MinSize < 0 || o.MinSize >= o.Size {
+		return &ChunkOptionsError{Field: "min chunk size", Value: o.MinSize, Reason: fmt.Sprintf("must be in [0, %d)", o.Size)}
+	}
+	return o.validateTail()
+}
+
+func (o *ChunkOptions) validateTail() error {
+	switch p := TailPolicy(strings.ToLower(strings.TrimSpace(string(o.Tail)))); p {
+	case "":
+		o.Tail = TailKeep
+	case TailKeep, TailDrop, TailMerge:
+		o.Tail = p
+	default:
+		return &ChunkOptionsError{Field: "tail policy", Value: o.Tail, Reason: "want keep, drop or merge"}
+	}
+	return nil
+}
+
+// counter returns the configured TokenCounter or WordCounter.
+func (o ChunkOptions) counter() TokenCounter {
+	if o.Counter == nil {
+		return WordCounter{}
+	}
+	return o.Counter
+}
+
+// Chunk validates the options and splits text with the selected chunker,
+// then applies the tail policy to the last chunk.
+func (o ChunkOptions) Chunk(text string) ([]string, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	var chunks []string
+	var err error
+	switch o.Chunker {
+	case ChunkerFixed:
+		chunks = ChunkText(text, o.Size, o.Overlap)
+	case ChunkerToken:
+		if _, words := o.counter().(WordCounter); words {
+			chunks = ChunkTextBySearchableTokens(text, o.Size, o.Overlap)
+		} else {
+			chunks = ChunkTextByCountedTokens(text, o.Size, o.Overlap, o.Counter)
+		}
+	case ChunkerParagraph:
+		chunks = ChunkTextByParagraph(text)
+	case ChunkerSemantic:
+		semantic := o.Semantic
+		if semantic == nil {
+			semantic = NewSemanticChunker(NewHashingEmbedder(0), o.Size, o.counter())
+		}
+		chunks, err = semantic.Chunk(text)
+	default:
+		chunks = ChunkTextRecursiveCounted(text, o.Size, o.Overlap, o.counter())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return o.applyTail(chunks), nil
+}
+
+// applyTail drops or merges a last chunk smaller than MinSize. A document
+// that yields a single chunk is never dropped.
+func (o ChunkOptions) applyTail(chunks []string) []string {
+	if o.MinSize <= 0 || o.Tail == TailKeep || len(chunks) < 2 {
+		return chunks
+	}
+	last := chunks[len(chunks)-1]
+	size := len([]rune(last))
+	if o.Chunker != ChunkerFixed {
+		size = o.counter().CountTokens(last)
+	}
+	if size >= o.MinSize {
+		return chunks
+	}
+	chunks = chunks[:len(chunks)-1]
+	if o.Tail == TailMerge {
+		prev := &chunks[len(chunks)-1]
+		if o.Chunker == ChunkerFixed {
+			// Fixed chunks overlap by exactly Overlap characters.
+			runes := []rune(last)
+			*prev += string(runes[min(o.Overlap, len(runes)):])
+		} else {
+			*prev = mergeOverlapping(*prev, last)
+		}
+	}
+	return chunks
+}
+
+// mergeOverlapping appends tail to prev, dropping the longest run of whole
+// words at the start of tail that prev already ends with, so overlapping
+// text is not repeated.
+func mergeOverlapping(prev, tail string) string {
+	for k := min(len(prev), len(tail)); k > 0; k-- {
+		if !strings.HasSuffix(prev, tail[:k]) {
+			continue
+		}
+		if (k == len(tail) || isSpace(tail[k])) && (k == len(prev) || isSpace(prev[len(prev)-k-1])) {
+			return prev + tail[k:]
+		}
+	}
+	return prev + " " + tail
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\n'
+}
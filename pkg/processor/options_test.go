@@ -0,0 +1,163 @@
+package processor
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChunkOptionsValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		opts  ChunkOptions
+		field string // empty if valid
+		want  ChunkOptions
+	}{
+		{"defaults", ChunkOptions{Size: 100}, "", ChunkOptions{Chunker: ChunkerToken, Size: 100, Tail: TailKeep}},
+		{"canonical names", ChunkOptions{Chunker: " Fixed ", Size: 10, Overlap: 9, Tail: "MERGE"}, "", ChunkOptions{Chunker: ChunkerFixed, Size: 10, Overlap: 9, Tail: TailMerge}},
+		{"percentage", ChunkOptions{Size: 200, OverlapPercent: 15}, "", ChunkOptions{Chunker: ChunkerToken, Size: 200, Overlap: 30, Tail: TailKeep}},
+		{"percentage rounds down", ChunkOptions{Size: 7, OverlapPercent: 99}, "", ChunkOptions{Chunker: ChunkerToken, Size: 7, Overlap: 6, Tail: TailKeep}},
+		{"paragraph ignores size", ChunkOptions{Chunker: ChunkerParagraph, Size: -1, Overlap: 5}, "", ChunkOptions{Chunker: ChunkerParagraph, Size: -1, Overlap: 5, Tail: TailKeep}},
+		{"min size", ChunkOptions{Size: 10, MinSize: 9, Tail: TailDrop}, "", ChunkOptions{Chunker: ChunkerToken, Size: 10, MinSize: 9, Tail: TailDrop}},
+
+		{"unknown chunker", ChunkOptions{Chunker: "sliding", Size: 10}, "chunker", ChunkOptions{}},
+		{"zero size", ChunkOptions{Size: 0}, "chunk size", ChunkOptions{}},
+		{"negative size", ChunkOptions{Chunker: ChunkerFixed, Size: -5}, "chunk size", ChunkOptions{}},
+		{"overlap equals size", ChunkOptions{Size: 10, Overlap: 10}, "overlap", ChunkOptions{}},
+		{"overlap above size", ChunkOptions{Chunker: ChunkerRecursive, Size: 10, Overlap: 50}, "overlap", ChunkOptions{}},
+		{"negative overlap", ChunkOptions{Size: 10, Overlap: -1}, "overlap", ChunkOptions{}},
+		{"percentage of 100", ChunkOptions{Size: 10, OverlapPercent: 100}, "overlap", ChunkOptions{}},
+		{"negative percentage", ChunkOptions{Size: 10, OverlapPercent: -5}, "overlap", ChunkOptions{}},
+		{"percentage rounding to zero is fine", ChunkOptions{Size: 3, OverlapPercent: 10}, "", ChunkOptions{Chunker: ChunkerToken, Size: 3, Tail: TailKeep}},
+		{"negative min size", ChunkOptions{Size: 10, MinSize: -1}, "min chunk size", ChunkOptions{}},
+		{"min size equals size", ChunkOptions{Size: 10, MinSize: 10}, "min chunk size", ChunkOptions{}},
+		{"unknown tail", ChunkOptions{Size: 10, Tail: "trim"}, "tail policy", ChunkOptions{}},
+		{"unknown tail for paragraphs", ChunkOptions{Chunker: ChunkerParagraph, Tail: "trim"}, "tail policy", ChunkOptions{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := tc.opts
+			err := opts.Validate()
+			if tc.field == "" {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				if !reflect.DeepEqual(opts, tc.want) {
+					t.Errorf("Validate normalized to %+v, want %+v", opts, tc.want)
+				}
+				return
+			}
+			var optErr *ChunkOptionsError
+			if !errors.As(err, &optErr) || optErr.Field != tc.field || !errors.Is(err, ErrInvalidChunkOptions) {
+				t.Fatalf("Validate = %v, want an invalid %s error", err, tc.field)
+			}
+			if _, err := tc.opts.Chunk("some text"); !errors.Is(err, ErrInvalidChunkOptions) {
+				t.Errorf("Chunk = %v, want the validation error", err)
+			}
+		})
+	}
+}
+
+func TestParseOverlap(t *testing.T) {
+	for _, tc := range []struct {
+		in      string
+		overlap int
+		percent float64
+		ok      bool
+	}{
+		{"20", 20, 0, true},
+		{" 15% ", 0, 15, true},
+		{"12.5 %", 0, 12.5, true},
+		{"-3", -3, 0, true},
+		{"abc", 0, 0, false},
+		{"x%", 0, 0, false},
+		{"1.5", 0, 0, false},
+	} {
+		overlap, percent, err := ParseOverlap(tc.in)
+		if (err == nil) != tc.ok || overlap != tc.overlap || percent != tc.percent {
+			t.Errorf("ParseOverlap(%q) = %d, %g, %v", tc.in, overlap, percent, err)
+		}
+	}
+}
+
+func TestChunkOptionsTail(t *testing.T) {
+	// Twelve words: chunks of 5 end in one of 2 words, with an overlap of 1
+	// in one of 4 and with an overlap of 2 in one of 3.
+	text := "one two three four five six seven eight nine ten eleven twelve"
+	for _, tc := range []struct {
+		name string
+		opts ChunkOptions
+		want []string
+	}{
+		{"keep", ChunkOptions{Size: 5, MinSize: 3, Tail: TailKeep},
+			[]string{"one two three four five", "six seven eight nine ten", "eleven twelve"}},
+		{"tail above min size", ChunkOptions{Size: 5, MinSize: 2, Tail: TailDrop},
+			[]string{"one two three four five", "six seven eight nine ten", "eleven twelve"}},
+		{"drop", ChunkOptions{Size: 5, MinSize: 3, Tail: TailDrop},
+			[]string{"one two three four five", "six seven eight nine ten"}},
+		{"merge", ChunkOptions{Size: 5, MinSize: 3, Tail: TailMerge},
+			[]string{"one two three four five", "six seven eight nine ten eleven twelve"}},
+		{"tail at min size", ChunkOptions{Size: 5, Overlap: 1, MinSize: 4, Tail: TailMerge},
+			[]string{"one two three four five", "five six seven eight nine", "nine ten eleven twelve"}},
+		{"merge drops the overlap", ChunkOptions{Size: 5, Overlap: 2, MinSize: 4, Tail: TailMerge},
+			[]string{"one two three four five", "four five six seven eight", "seven eight nine ten eleven twelve"}},
+		{"fixed merge drops the overlap", ChunkOptions{Chunker: ChunkerFixed, Size: 30, Overlap: 4, MinSize: 11, Tail: TailMerge},
+			[]string{"one two three four five six se", "x seven eight nine ten eleven twelve"}},
+		{"single chunk is never dropped", ChunkOptions{Size: 50, MinSize: 49, Tail: TailDrop},
+			[]string{text}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			chunks, err := tc.opts.Chunk(text)
+			if err != nil {
+				t.Fatalf("Chunk: %v", err)
+			}
+			if !reflect.DeepEqual(chunks, tc.want) {
+				t.Errorf("Chunk = %q, want %q", chunks, tc.want)
+			}
+		})
+	}
+}
+
+func TestChunkTextBadSizes(t *testing.T) {
+	text := strings.Repeat("word ", 20)
+	for _, tc := range []struct {
+		name          string
+		size, overlap int
+		chunks        int
+	}{
+		{"zero size", 0, 0, 0},
+		{"negative size", -3, 0, 0},
+		// An overlap clamped to size-1 advances one word at a time.
+		{"overlap equals size", 4, 4, 17},
+		{"overlap above size", 4, 100, 17},
+		{"negative overlap", 4, -2, 5},
+		{"size one", 1, 1, 20},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ChunkTextByTokens(text, tc.size, tc.overlap); len(got) != tc.chunks {
+				t.Errorf("ChunkTextByTokens = %d chunks, want %d", len(got), tc.chunks)
+			}
+			if got := ChunkTextBySearchableTokens(text, tc.size, tc.overlap); len(got) != tc.chunks {
+				t.Errorf("ChunkTextBySearchableTokens = %d chunks, want %d", len(got), tc.chunks)
+			}
+			for _, c := range ChunkText(text, tc.size, tc.overlap) {
+				if n := len([]rune(c)); n > tc.size {
+					t.Errorf("ChunkText returned a chunk of %d characters, size %d", n, tc.size)
+				}
+			}
+		})
+	}
+	if got := ChunkText("abcdef", 3, 3); !reflect.DeepEqual(got, []string{"abc", "bcd", "cde", "def"}) {
+		t.Errorf("ChunkText with overlap = size gives %q", got)
+	}
+}
+
+func TestClampOverlap(t *testing.T) {
+	for _, tc := range []struct{ overlap, size, want int }{
+		{0, 10, 0}, {5, 10, 5}, {9, 10, 9}, {10, 10, 9}, {50, 10, 9}, {-1, 10, 0}, {3, 1, 0},
+	} {
+		if got := clampOverlap(tc.overlap, tc.size); got != tc.want {
+			t.Errorf("clampOverlap(%d, %d) = %d, want %d", tc.overlap, tc.size, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// processor/packing.go
+package processor
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/anurag-bit/goetl/utils"
+)
+
+// PackOptions configures PackChunks.
+type PackOptions struct {
+	// Length is the number of tokens in every packed sequence.
+	Length    int
+	Tokenizer *utils.Tokenizer
+	// Separator is appended to every chunk; it defaults to utils.EndOfTextToken.
+	// Padding uses utils.PadToken if the vocabulary has it, else Separator.
+	Separator string
+	// Boundaries records per-document segment IDs with every sequence, so
+	// that attention can be kept from crossing documents.
+	Boundaries bool
+}
+
+// PackedSequence is one training example of exactly PackOptions.Length tokens.
+type PackedSequence struct {
+	Tokens []int
+	// Segments holds, per token, 1 + the index of its document among the
+	// documents in this sequence, or 0 for padding. Only set with Boundaries.
+	Segments []int
+	// Sources lists the documents in the sequence in order of appearance.
+	Sources []string
+}
+
+// PackStats summarizes a packing run.
+type PackStats struct {
+	Chunks    int
+	Sequences int
+	Tokens    int
+	Padding   int
+	// Split counts chunks longer than a sequence, which are cut into
+	// sequence-sized pieces.
+	Split int
+}
+
+// PaddingWaste returns the share of padding tokens in percent.
+func (s PackStats) PaddingWaste() float64 {
+	if s.Tokens == 0 {
+		return 0
+	}
+	return 100 * float64(s.Padding) / float64(s.Tokens)
+}
+
+// Add adds the counts of another packing run.
+func (s *PackStats) Add(o PackStats) {
+	s.Chunks += o.Chunks
+	s.Sequences += o.Sequences
+	s.Tokens += o.Tokens
+	s.Padding += o.Padding
+	s.Split += o.Split
+}
+
+// packItem is a run of tokens to place in a sequence.
+type packItem struct {
+	ids    []int
+	source string
+}
+
+// packBin is a sequence being filled.
+type packBin struct {
+	items []packItem
+	free  int
+}
+
+// PackChunks tokenizes chunks, appends the separator to each and packs
+// them into sequences of exactly opts.Length tokens by best-fit decreasing:
+// longest chunks first, each into the fullest sequence it still fits.
+// Remaining space is padded. Chunks longer than a sequence are cut into
+// full sequences and a remainder that is packed like any other chunk.
+func PackChunks(chunks []Chunk, opts PackOptions) ([]PackedSequence, PackStats, error) {
+	stats := PackStats{Chunks: len(chunks)}
+	if opts.Length < 2 {
+		return nil, stats, fmt.Errorf("packed sequence length must be at least 2, got %d", opts.Length)
+	}
+	if opts.Tokenizer == nil {
+		return nil, stats, fmt.Errorf("packing needs a tokenizer with a vocabulary")
+	}
+	if opts.Separator == "" {
+		opts.Separator = utils.EndOfTextToken
+	}
+	sep, ok := opts.Tokenizer.TokenID(opts.Separator)
+	if !ok {
+		return nil, stats, fmt.Errorf("separator token %q is not in the vocabulary", opts.Separator)
+	}
+	pad, ok := opts.Tokenizer.TokenID(utils.PadToken)
+	if !ok {
+		pad = sep
+	}
+
+	var full, items []packItem
+	for _, c := range chunks {
+		ids, err := opts.Tokenizer.Encode(c.Text)
+		if err != nil {
+			return nil, stats, err
+		}
+		ids = append(ids, sep)
+		if len(ids) > opts.Length {
+			stats.Split++
+		}
+		for len(ids) > opts.Length {
+			full = append(full, packItem{ids[:opts.Length], c.Source})
+			ids = ids[opts.Length:]
+		}
+		items = append(items, packItem{ids, c.Source})
+	}
+	sort.SliceStable(items, func(i, j int) bool { return len(items[i].ids) > len(items[j].ids) })
+
+	var bins []*packBin
+	for _, it := range full {
+		bins = append(bins, &packBin{items: []packItem{it}})
+	}
+	// byFree[n] holds the indices of the open bins with n free tokens.
+	byFree := make([][]int, opts.Length+1)
+	for _, it := range items {
+		need, placed := len(it.ids), false
+		for free := need; free <= opts.Length && !placed; free++ {
+			if n := len(byFree[free]); n > 0 {
+				b := byFree[free][n-1]
+				byFree[free] = byFree[free][:n-1]
+				bins[b].items = append(bins[b].items, it)
+				bins[b].free -= need
+				byFree[bins[b].free] = append(byFree[bins[b].free], b)
+				placed = true
+			}
+		}
+		if !placed {
+			bins = append(bins, &packBin{items: []packItem{it}, free: opts.Length - need})
+			byFree[opts.Length-need] = append(byFree[opts.Length-need], len(bins)-1)
+		}
+	}
+
+	seqs := make([]PackedSequence, len(bins))
+	for i, b := range bins {
+		seq := PackedSequence{Tokens: make([]int, 0, opts.Length)}
+		segment := make(map[string]int)
+		for _, it := range b.items {
+			if _, ok := segment[it.source]; !ok {
+				segment[it.source] = len(segment) + 1
+				seq.Sources = append(seq.Sources, it.source)
+			}
+			seq.Tokens = append(seq.Tokens, it.ids...)
+			if opts.Boundaries {
+				for range it.ids {
+					seq.Segments = append(seq.Segments, segment[it.source])
+				}
+			}
+		}
+		for len(seq.Tokens) < opts.Length {
+			seq.Tokens = append(seq.Tokens, pad)
+			if opts.Boundaries {
+				seq.Segments = append(seq.Segments, 0)
+			}
+			stats.Padding++
+		}
+		seqs[i] = seq
+	}
+	stats.Sequences = len(seqs)
+	stats.Tokens = len(seqs) * opts.Length
+	return seqs, stats, nil
+}
@@ -0,0 +1,149 @@
+package processor
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/anurag-bit/goetl/utils"
+)
+
+// byteTokenizer encodes every byte as one token, so that a text of n bytes
+// plus its separator is n+1 tokens.
+func byteTokenizer(t *testing.T) *utils.Tokenizer {
+	t.Helper()
+	tok := utils.NewByteLevelTokenizer(nil)
+	tok.BuildVocab(nil)
+	return tok
+}
+
+func TestPackChunksBestFit(t *testing.T) {
+	tok := byteTokenizer(t)
+	eos, _ := tok.TokenID(utils.EndOfTextToken)
+	pad, _ := tok.TokenID(utils.PadToken)
+	a, _ := tok.Encode("aaaaa")
+	b, _ := tok.Encode("bb")
+	c, _ := tok.Encode("c")
+	d, _ := tok.Encode("ddd")
+	chunks := []Chunk{{Text: "aaaaa", Source: "A"}, {Text: "bb", Source: "B"}, {Text: "c", Source: "C"}, {Text: "ddd", Source: "D"}}
+	seqs, stats, err := PackChunks(chunks, PackOptions{Length: 8, Tokenizer: tok, Boundaries: true})
+	if err != nil {
+		t.Fatalf("PackChunks: %v", err)
+	}
+	// Longest first, each into the fullest sequence it fits: A (6) opens a
+	// sequence, D (4) a second, B (3) fills the second and C (2) the first.
+	join := func(parts ...[]int) []int {
+		var out []int
+		for _, p := range parts {
+			out = append(out, p...)
+		}
+		return out
+	}
+	want := []PackedSequence{
+		{Tokens: join(a, []int{eos}, c, []int{eos}), Segments: []int{1, 1, 1, 1, 1, 1, 2, 2}, Sources: []string{"A", "C"}},
+		{Tokens: join(d, []int{eos}, b, []int{eos}, []int{pad}), Segments: []int{1, 1, 1, 1, 2, 2, 2, 0}, Sources: []string{"D", "B"}},
+	}
+	if !reflect.DeepEqual(seqs, want) {
+		t.Errorf("PackChunks = %+v, want %+v", seqs, want)
+	}
+	if want := (PackStats{Chunks: 4, Sequences: 2, Tokens: 16, Padding: 1}); stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+}
+
+func TestPackChunks(t *testing.T) {
+	tok := byteTokenizer(t)
+	eos, _ := tok.TokenID(utils.EndOfTextToken)
+	var chunks []Chunk
+	for i := 0; i < 30; i++ {
+		chunks = append(chunks, Chunk{Text: strings.Repeat(string(rune('a'+i%26)), (i*7)%23+1), Source: fmt.Sprintf("doc%d", i/3)})
+	}
+	// Tokens per document, counting the separator after every chunk.
+	wantTokens := make(map[string]int)
+	longest := 0
+	for _, c := range chunks {
+		wantTokens[c.Source] += len(c.Text) + 1
+		longest = max(longest, len(c.Text)+1)
+	}
+
+	for _, tc := range []struct {
+		length     int
+		boundaries bool
+	}{
+		{2, true}, {8, true}, {8, false}, {16, true}, {24, true}, {64, true}, {64, false}, {1000, true},
+	} {
+		t.Run(fmt.Sprintf("length %d boundaries %v", tc.length, tc.boundaries), func(t *testing.T) {
+			seqs, stats, err := PackChunks(chunks, PackOptions{Length: tc.length, Tokenizer: tok, Boundaries: tc.boundaries})
+			if err != nil {
+				t.Fatalf("PackChunks: %v", err)
+			}
+			gotTokens := make(map[string]int)
+			padding := 0
+			for i, seq := range seqs {
+				if len(seq.Tokens) != tc.length {
+					t.Fatalf("sequence %d has %d tokens, want %d", i, len(seq.Tokens), tc.length)
+				}
+				if !tc.boundaries {
+					if seq.Segments != nil {
+						t.Errorf("sequence %d has segments without Boundaries", i)
+					}
+					continue
+				}
+				if len(seq.Segments) != tc.length {
+					t.Fatalf("sequence %d has %d segment IDs, want %d", i, len(seq.Segments), tc.length)
+				}
+				for j, s := range seq.Segments {
+					switch {
+					case s == 0:
+						padding++
+						if j+1 < len(seq.Segments) && seq.Segments[j+1] != 0 {
+							t.Errorf("sequence %d: padding at %d is followed by tokens", i, j)
+						}
+					case s > len(seq.Sources):
+						t.Fatalf("sequence %d: segment %d at %d but only %d sources", i, s, j, len(seq.Sources))
+					default:
+						gotTokens[seq.Sources[s-1]]++
+						// Every document run ends in the separator, unless a
+						// chunk was cut at the end of a full sequence.
+						end := j+1 == len(seq.Segments) || seq.Segments[j+1] != s
+						if end && seq.Tokens[j] != eos && j+1 < len(seq.Segments) {
+							t.Errorf("sequence %d: segment %d ends at %d without the separator", i, s, j)
+						}
+					}
+				}
+			}
+			if tc.boundaries {
+				if !reflect.DeepEqual(gotTokens, wantTokens) {
+					t.Errorf("tokens per document = %v, want %v", gotTokens, wantTokens)
+				}
+				if padding != stats.Padding {
+					t.Errorf("%d padding segment IDs, stats say %d", padding, stats.Padding)
+				}
+			}
+			total := 0
+			for _, n := range wantTokens {
+				total += n
+			}
+			if stats.Sequences != len(seqs) || stats.Tokens != len(seqs)*tc.length || stats.Tokens-stats.Padding != total {
+				t.Errorf("stats = %+v for %d sequences holding %d tokens", stats, len(seqs), total)
+			}
+			if split := stats.Split; (longest > tc.length) != (split > 0) {
+				t.Errorf("%d chunks split at length %d with the longest chunk at %d tokens", split, tc.length, longest)
+			}
+		})
+	}
+}
+
+func TestPackChunksErrors(t *testing.T) {
+	tok := byteTokenizer(t)
+	for _, opts := range []PackOptions{
+		{Length: 1, Tokenizer: tok},
+		{Length: 8},
+		{Length: 8, Tokenizer: tok, Separator: "<|missing|>"},
+	} {
+		if _, _, err := PackChunks([]Chunk{{Text: "x"}}, opts); err == nil {
+			t.Errorf("PackChunks(%+v) succeeded", opts)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// processor/position.go
+package processor
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Position markers are lines in extracted text that record where a page
+// ("[[page 3]]") or a section ("[[section: Results]]") begins. ChunkDocument
+// removes them and tags every chunk with the page and section it starts in.
+const (
+	pageMarker    = "[[page "
+	sectionMarker = "[[section: "
+)
+
+// FormatPageMarker returns the line that marks the start of a page.
+func FormatPageMarker(page int) string {
+	return pageMarker + strconv.Itoa(page) + "]]"
+}
+
+// FormatSectionMarker returns the line that marks the start of a section.
+// Line breaks in title become spaces.
+func FormatSectionMarker(title string) string {
+	return sectionMarker + strings.Join(strings.Fields(title), " ") + "]]"
+}
+
+// position is the page and section in effect from a word of a text on.
+type position struct {
+	word    int
+	page    int
+	section string
+}
+
+// parsePositionMarker updates pos if line is a position marker.
+func parsePositionMarker(line string, pos *position) bool {
+	line = strings.TrimSpace(line)
+	if !strings.HasSuffix(line, "]]") {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(line, pageMarker):
+		page, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(line, pageMarker), "]]"))
+		if err != nil {
+			return false
+		}
+		pos.page = page
+	case strings.HasPrefix(line, sectionMarker):
+		pos.section = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(line, sectionMarker), "]]"))
+	default:
+		return false
+	}
+	return true
+}
+
+// EscapeMarkers prefixes a backslash to every line of text that reads as a
+// position marker or a table block line, so that literal occurrences in
+// extracted text are kept as text. Lines that are already escaped markers
+// get another backslash; UnescapeMarkers removes one again.
+func EscapeMarkers(text string) string {
+	return mapMarkerLines(text, func(line string, at int) string {
+		return line[:at] + `\` + line[at:]
+	})
+}
+
+// UnescapeMarkers reverses EscapeMarkers.
+func UnescapeMarkers(text string) string {
+	return mapMarkerLines(text, func(line string, at int) string {
+		if line[at] != '\\' {
+			return line
+		}
+		return line[:at] + line[at+1:]
+	})
+}
+
+// mapMarkerLines replaces every line of text that, without leading
+// backslashes, is a marker line by fn(line, offset of its first non-space byte).
+func mapMarkerLines(text string, fn func(line string, at int) string) string {
+	if !strings.Contains(text, "[[") {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " \t")
+		if isMarkerLine(strings.TrimLeft(trimmed, `\`)) {
+			lines[i] = fn(line, len(line)-len(trimmed))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// isMarkerLine reports whether line is a position marker or a table block
+// start or end line.
+func isMarkerLine(line string) bool {
+	var pos position
+	_, table := parseTableStart(line)
+	return table || parsePositionMarker(line, &pos) || strings.TrimSpace(line) == tableEnd
+}
+
+// StripPositionMarkers removes the page and section marker lines of text
+// and unescapes the remaining lines.
+func StripPositionMarkers(text string) string {
+	text, _ = stripPositions(text, position{})
+	return text
+}
+
+// stripPositions removes the marker lines of text, which starts at start,
+// unescapes the remaining lines and returns the positions the markers set,
+// counted in words of the remaining text.
+func stripPositions(text string, start position) (string, []position) {
+	lines := strings.Split(text, "\n")
+	kept := lines[:0]
+	positions := []position{start}
+	cur, words := start, 0
+	for _, line := range lines {
+		if parsePositionMarker(line, &cur) {
+			cur.word = words
+			positions = append(positions, cur)
+			continue
+		}
+		kept = append(kept, line)
+		words += len(strings.Fields(line))
+	}
+	return UnescapeMarkers(strings.Join(kept, "\n")), positions
+}
+
+// locateChunks sets the page and section of chunks cut from text by
+// finding the word each chunk starts at. Chunks are searched in order from
+// the start of the previous one, so overlapping chunks are placed correctly;
+// a chunk that cannot be found keeps the position of the previous one.
+func locateChunks(text string, chunks []Chunk, positions []position) {
+	words := strings.Fields(text)
+	cursor, at := 0, 0
+	for i := range chunks {
+		head := strings.Fields(chunks[i].Text)
+		if len(head) > 8 {
+			head = head[:8]
+		}
+		if n := findWords(words, head, cursor); n >= 0 {
+			at, cursor = n, n+1
+		}
+		pos := positions[0]
+		for _, p := range positions[1:] {
+			if p.word > at {
+				break
+			}
+			pos = p
+		}
+		chunks[i].Page, chunks[i].Section = pos.page, pos.section
+	}
+}
+
+// findWords returns the first index from start on at which words holds
+// head. The first word may be the tail and the last word the start of a
+// longer word, as the fixed-size and token chunkers cut through words.
+func findWords(words, head []string, start int) int {
+	if len(head) == 0 {
+		return -1
+	}
+	last := len(head) - 1
+	for i := start; i+len(head) <= len(words); i++ {
+		var match bool
+		if last == 0 {
+			match = strings.Contains(words[i], head[0])
+		} else {
+			match = strings.HasSuffix(words[i], head[0]) && strings.HasPrefix(words[i+last], head[last])
+		}
+		for j := 1; j < last && match; j++ {
+			match = words[i+j] == head[j]
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,205 @@
+// processor/quality.go
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// QualityThresholds configures the Gopher/C4-style chunk heuristics.
+// A zero value disables the corresponding check.
+type QualityThresholds struct {
+	MinWords             int     `json:"min_words"`
+	MaxWords             int     `json:"max_words"`
+	MinMeanWordLength    float64 `json:"min_mean_word_length"`
+	MaxMeanWordLength    float64 `json:"max_mean_word_length"`
+	MaxSymbolWordRatio   float64 `json:"max_symbol_word_ratio"`
+	MinPunctLineFraction float64 `json:"min_punct_line_fraction"`
+	MaxDupNgramFraction  float64 `json:"max_dup_ngram_fraction"`
+	DupNgramSize         int     `json:"dup_ngram_size"`
+	MinStopWords         int     `json:"min_stop_words"`
+}
+
+// DefaultQualityThresholds returns thresholds close to the Gopher rules,
+// scaled down for chunk-sized texts. The line-punctuation check is off by
+// default because PDF and hard-wrapped text break lines mid-sentence.
+func DefaultQualityThresholds() QualityThresholds {
+	return QualityThresholds{
+		MinWords:            20,
+		MinMeanWordLength:   3,
+		MaxMeanWordLength:   10,
+		MaxSymbolWordRatio:  0.1,
+		MaxDupNgramFraction: 0.3,
+		DupNgramSize:        3,
+		MinStopWords:        2,
+	}
+}
+
+// LoadQualityThresholds reads thresholds from a JSON file. Fields missing
+// from the file keep their default values.
+func LoadQualityThresholds(path string) (QualityThresholds, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return DefaultQualityThresholds(), fmt.Errorf("failed to read quality config: %v", err)
+	}
+	return ParseQualityThresholds(data)
+}
+
+// ParseQualityThresholds decodes thresholds from JSON. Fields missing from
+// data keep their default values.
+func ParseQualityThresholds(data []byte) (QualityThresholds, error) {
+	t := DefaultQualityThresholds()
+	if err := json.Unmarshal(data, &t); err != nil {
+		return t, fmt.Errorf("failed to parse quality config: %v", err)
+	}
+	return t, nil
+}
+
+// stopWords are the Gopher stop words; an English chunk contains several.
+var stopWords = map[string]bool{
+	"the": true, "be": true, "to": true, "of": true,
+	"and": true, "that": true, "have": true, "with": true,
+}
+
+// CheckDocument applies the line-based checks to the raw text of a
+// document, before cleaning joins its lines. It returns an empty string if
+// the document passes, otherwise the reason for the first failed check.
+func (t QualityThresholds) CheckDocument(text string) string {
+	if t.MinPunctLineFraction > 0 {
+		if frac := punctLineFraction(text); frac < t.MinPunctLineFraction {
+			return fmt.Sprintf("punct_line_fraction %.2f < %.2f", frac, t.MinPunctLineFraction)
+		}
+	}
+	return ""
+}
+
+// CheckQuality applies the thresholds to a chunk in the given language. It
+// returns an empty string if the chunk passes, otherwise the reason for the
+// first failed check. The stop words are English, so that check only applies
+// to chunks in "en"; the line-based checks are left to CheckDocument.
+func (t QualityThresholds) CheckQuality(chunk, language string) string {
+	words := strings.Fields(chunk)
+	n := len(words)
+	if t.MinWords > 0 && n < t.MinWords {
+		return fmt.Sprintf("word_count %d < %d", n, t.MinWords)
+	}
+	if t.MaxWords > 0 && n > t.MaxWords {
+		return fmt.Sprintf("word_count %d > %d", n, t.MaxWords)
+	}
+	if n == 0 {
+		return "empty"
+	}
+
+	chars := 0
+	for _, w := range words {
+		chars += len([]rune(w))
+	}
+	mean := float64(chars) / float64(n)
+	if t.MinMeanWordLength > 0 && mean < t.MinMeanWordLength {
+		return fmt.Sprintf("mean_word_length %.2f < %.2f", mean, t.MinMeanWordLength)
+	}
+	if t.MaxMeanWordLength > 0 && mean > t.MaxMeanWordLength {
+		return fmt.Sprintf("mean_word_length %.2f > %.2f", mean, t.MaxMeanWordLength)
+	}
+
+	if t.MaxSymbolWordRatio > 0 {
+		symbols := strings.Count(chunk, "#") + strings.Count(chunk, "...") + strings.Count(chunk, "…")
+		if ratio := float64(symbols) / float64(n); ratio > t.MaxSymbolWordRatio {
+			return fmt.Sprintf("symbol_word_ratio %.2f > %.2f", ratio, t.MaxSymbolWordRatio)
+		}
+	}
+
+	if t.MaxDupNgramFraction > 0 && t.DupNgramSize > 0 {
+		if frac := dupNgramFraction(words, t.DupNgramSize); frac > t.MaxDupNgramFraction {
+			return fmt.Sprintf("dup_%dgram_fraction %.2f > %.2f", t.DupNgramSize, frac, t.MaxDupNgramFraction)
+		}
+	}
+
+	if t.MinStopWords > 0 && language == "en" {
+		found := 0
+		for _, w := range words {
+			if stopWords[strings.ToLower(strings.TrimFunc(w, unicode.IsPunct))] {
+				found++
+			}
+		}
+		if found < t.MinStopWords {
+			return fmt.Sprintf("stop_words %d < %d", found, t.MinStopWords)
+		}
+	}
+	return ""
+}
+
+// punctLineFraction is the fraction of non-empty lines ending in terminal punctuation.
+func punctLineFraction(text string) float64 {
+	total, ended := 0, 0
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		total++
+		if strings.ContainsAny(line[len(line)-1:], ".!?\"'") || strings.HasSuffix(line, "…") {
+			ended++
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(ended) / float64(total)
+}
+
+// dupNgramFraction is the fraction of characters covered by word n-grams
+// that occur more than once in the text.
+func dupNgramFraction(words []string, n int) float64 {
+	if len(words) < n {
+		return 0
+	}
+	counts := make(map[string]int)
+	for i := 0; i+n <= len(words); i++ {
+		counts[strings.Join(words[i:i+n], " ")]++
+	}
+	covered := make([]bool, len(words))
+	for i := 0; i+n <= len(words); i++ {
+		if counts[strings.Join(words[i:i+n], " ")] > 1 {
+			for j := i; j < i+n; j++ {
+				covered[j] = true
+			}
+		}
+	}
+	total, dup := 0, 0
+	for i, w := range words {
+		total += len(w)
+		if covered[i] {
+			dup += len(w)
+		}
+	}
+	return float64(dup) / float64(total)
+}
+
+// RejectedChunk is a chunk removed by a filter stage, kept for threshold tuning.
+type RejectedChunk struct {
+	Source string `json:"source"`
+	Reason string `json:"reason"`
+	Text   string `json:"text"`
+}
+
+// WriteRejectedChunks writes rejected chunks as JSON lines.
+func WriteRejectedChunks(path string, rejected []RejectedChunk) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create rejected file: %v", err)
+	}
+	defer file.Close()
+
+	enc := json.NewEncoder(file)
+	enc.SetEscapeHTML(false)
+	for _, r := range rejected {
+		if err := enc.Encode(r); err != nil {
+			return fmt.Errorf("failed to write rejected chunk: %v", err)
+		}
+	}
+	return nil
+}
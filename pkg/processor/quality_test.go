@@ -0,0 +1,68 @@
+package processor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseQualityThresholdsKeepsDefaults(t *testing.T) {
+	got, err := ParseQualityThresholds([]byte(`{"min_words": 5, "min_punct_line_fraction": 0.5}`))
+	if err != nil {
+		t.Fatalf("ParseQualityThresholds: %v", err)
+	}
+	want := DefaultQualityThresholds()
+	want.MinWords, want.MinPunctLineFraction = 5, 0.5
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if got, _ := ParseQualityThresholds([]byte("null")); got != DefaultQualityThresholds() {
+		t.Errorf("null thresholds = %+v, want the defaults", got)
+	}
+	if _, err := ParseQualityThresholds([]byte(`{"min_words": "many"}`)); err == nil {
+		t.Error("invalid thresholds were accepted")
+	}
+}
+
+func TestCheckQualityStopWords(t *testing.T) {
+	q := DefaultQualityThresholds()
+	german := "Die Verarbeitung großer Dokumentensammlungen erfordert sorgfältige Planung, " +
+		"denn jeder Schritt der Pipeline muss zuverlässig funktionieren und Fehler früh erkennen, " +
+		"damit später keine unbrauchbaren Daten entstehen oder wertvolle Rechenzeit verloren geht."
+	english := "The processing of large document collections requires careful planning, and every step " +
+		"of the pipeline has to work reliably with errors detected early, so that no unusable data " +
+		"reaches the training set."
+	noStopWords := "Quarterly revenue figures exceeded expectations across several regions, driven by strong " +
+		"demand for cloud storage products, new enterprise contracts, lower shipping costs, improved " +
+		"margins in Europe plus steady growth in Asian markets."
+	for _, tc := range []struct {
+		name, text, lang string
+		reject           bool
+	}{
+		{"german", german, "de", false},
+		{"english", english, "en", false},
+		{"english without stop words", noStopWords, "en", true},
+		{"unknown language", noStopWords, LanguageUnknown, false},
+	} {
+		reason := q.CheckQuality(tc.text, tc.lang)
+		if rejected := strings.HasPrefix(reason, "stop_words"); rejected != tc.reject {
+			t.Errorf("%s: CheckQuality = %q, want stop word rejection %v", tc.name, reason, tc.reject)
+		}
+	}
+}
+
+func TestCheckDocumentPunctLines(t *testing.T) {
+	q := DefaultQualityThresholds()
+	q.MinPunctLineFraction = 0.5
+	prose := "The first line ends a sentence.\nSo does the second one!\nDoes the third?\nA heading\n"
+	menu := "Home\nProducts\nAbout us\nContact\nLog in\nThe only sentence on the page.\n"
+	if reason := q.CheckDocument(prose); reason != "" {
+		t.Errorf("prose rejected: %s", reason)
+	}
+	if reason := q.CheckDocument(menu); !strings.HasPrefix(reason, "punct_line_fraction") {
+		t.Errorf("menu lines: CheckDocument = %q, want a punct_line_fraction rejection", reason)
+	}
+	// Cleaning joins the lines, so the check must not run on chunks.
+	if reason := q.CheckQuality(CleanText(menu+strings.Repeat("with the words of the text ", 5)), "en"); strings.HasPrefix(reason, "punct_line_fraction") {
+		t.Errorf("CheckQuality applied the line check to a chunk: %s", reason)
+	}
+}
@@ -0,0 +1,342 @@
+// processor/secrets.go
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SecretPolicy controls what happens to a document in which secrets are found.
+type SecretPolicy string
+
+const (
+	// SecretPolicyOff disables secret scanning.
+	SecretPolicyOff SecretPolicy = "off"
+	// SecretPolicyRedact replaces every match with a redaction marker.
+	SecretPolicyRedact SecretPolicy = "redact"
+	// SecretPolicyDrop removes the whole document from the run.
+	SecretPolicyDrop SecretPolicy = "drop"
+	// SecretPolicyFail aborts the job on the first document with findings.
+	SecretPolicyFail SecretPolicy = "fail"
+)
+
+// ParseSecretPolicy converts a flag or API value into a SecretPolicy.
+// An empty string selects SecretPolicyRedact.
+func ParseSecretPolicy(s string) (SecretPolicy, error) {
+	switch p := SecretPolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case "":
+		return SecretPolicyRedact, nil
+	case SecretPolicyOff, SecretPolicyRedact, SecretPolicyDrop, SecretPolicyFail:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown secret policy %q (want off, redact, drop or fail)", s)
+	}
+}
+
+// SecretRule is a named regular expression that matches one kind of secret.
+// If Group is non-zero only that submatch is treated as the secret, so that
+// e.g. the variable name in "API_KEY=..." survives redaction.
+type SecretRule struct {
+	Name    string
+	Pattern *regexp.Regexp
+	Group   int
+	// Sources, if set, limits the rule to documents whose source matches.
+	Sources *regexp.Regexp
+	// Exclude drops matches whose secret looks like code rather than a
+	// value, such as a member access or a variable reference.
+	Exclude *regexp.Regexp
+	// MinEntropy drops matches whose secret carries fewer bits per
+	// character, such as "xxxxxxxx" placeholders.
+	MinEntropy float64
+}
+
+// secretName matches the name of a variable or key that holds a credential.
+const secretName = `(?:secret|token|passwd|password|pwd|api_?key|access_?key|private_?key|credentials?)`
+
+// configSources matches the .env, YAML and other configuration files in
+// which a bare value after a key-like name is a value rather than code.
+var configSources = regexp.MustCompile(`(?i)(?:^|[/\\])\.env(?:\.[\w.-]*)?$|\.(?:env|ya?ml|ini|cfg|conf|toml|properties)$`)
+
+// codeValue matches bare values that are code: a dotted member access
+// such as config.Token, or a shell or template variable reference.
+var codeValue = regexp.MustCompile(`^(?:[A-Za-z_]\w*(?:\.[A-Za-z_]\w*)+|\$.*|\{\{.*)$`)
+
+// DefaultSecretRules covers the credentials most often leaked into repositories.
+var DefaultSecretRules = []SecretRule{
+	{Name: "private-key", Pattern: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY( BLOCK)?-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY( BLOCK)?-----`)},
+	{Name: "aws-access-key-id", Pattern: regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[0-9A-Z]{16}\b`)},
+	{Name: "aws-secret-access-key", Pattern: regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})`), Group: 1},
+	{Name: "jwt", Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`)},
+	{Name: "github-token", Pattern: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})\b`)},
+	{Name: "slack-token", Pattern: regexp.MustCompile(`\bxox[baprs]-[A-Za-z0-9-]{10,}`)},
+	{Name: "google-api-key", Pattern: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{Name: "stripe-key", Pattern: regexp.MustCompile(`\b(?:sk|rk)_live_[0-9A-Za-z]{24,}\b`)},
+	// An upper-case environment variable assigned a value that ends the
+	// line, as in "export DB_PASSWORD=hunter2hunter2".
+	{Name: "env-assignment", Pattern: regexp.MustCompile(`(?m)^[ \t]*(?:export[ \t]+)?[A-Z0-9_]*` + strings.ToUpper(secretName) + `[A-Z0-9_]*[ \t]*=[ \t]*["']?([^\s"'#,;()]{6,})["']?[ \t]*(?:#.*)?$`), Group: 1, Exclude: codeValue},
+	// Any key-like name with a value that ends the line, in configuration
+	// files only, as in "password: hunter2hunter2" in a YAML file.
+	{Name: "config-assignment", Pattern: regexp.MustCompile(`(?im)^[ \t]*(?:export[ \t]+)?["']?[A-Za-z0-9_.-]*` + secretName + `[A-Za-z0-9_.-]*["']?[ \t]*[:=][ \t]*["']?([^\s"'#,;()]{6,})["']?[ \t]*(?:[#;].*)?$`), Group: 1, Sources: configSources, Exclude: codeValue, MinEntropy: 2},
+	// A string literal assigned to a password in code or JSON, as in
+	// dbPassword := "..." or "password": "...". Values with spaces are
+	// prose. Keys and tokens are random and left to the entropy check.
+	{Name: "password-literal", Pattern: regexp.MustCompile(`(?i)(?:passw(?:or)?d|pwd)\w*["']?[ \t]*(?::=|=>|[:=])[ \t]*["']([^\s"'$]{8,})["']`), Group: 1, MinEntropy: 2},
+}
+
+// SecretFinding describes a single suspected secret in a document.
+// Preview is masked so the report itself does not leak the value.
+type SecretFinding struct {
+	Source  string  `json:"source"`
+	Rule    string  `json:"rule"`
+	Line    int     `json:"line"`
+	Column  int     `json:"column"`
+	Preview string  `json:"preview"`
+	Entropy float64 `json:"entropy"`
+
+	start, end int
+}
+
+// SecretScanner finds credentials in text using pattern rules and a
+// Shannon-entropy check on long base64/hex-looking tokens that follow a
+// key-like name.
+type SecretScanner struct {
+	Rules []SecretRule
+	// Base64Entropy and HexEntropy are the bits-per-character thresholds
+	// above which a token is reported as a high-entropy string.
+	// A zero value disables the respective check.
+	Base64Entropy float64
+	HexEntropy    float64
+	// MinTokenLength is the shortest token considered by the entropy check.
+	MinTokenLength int
+	// EntropyContext must match the line up to a token for the entropy
+	// check to report it, so that commit hashes and checksums in prose are
+	// left alone. A nil value reports high-entropy tokens anywhere.
+	EntropyContext *regexp.Regexp
+}
+
+// DefaultEntropyContext matches a key-like name followed by an assignment
+// or separator, as in `token=`, `"api_key": "` or `Authorization: Bearer `.
+var DefaultEntropyContext = regexp.MustCompile(`(?i)(?:key|token|secret|passw(?:or)?d|pwd|auth[a-z]*|bearer|credentials?)["'\]]*\s*(?:[:=]+>?|\s)\s*["']?$`)
+
+// NewSecretScanner returns a scanner with the default rules and thresholds.
+// Random hex carries at most 4 bits per character and a SHA-1 or SHA-256
+// sum as much as a hex key, which is why entropy hits need a key-like
+// context.
+func NewSecretScanner() *SecretScanner {
+	return &SecretScanner{
+		Rules:          DefaultSecretRules,
+		Base64Entropy:  4.5,
+		HexEntropy:     3.3,
+		MinTokenLength: 20,
+		EntropyContext: DefaultEntropyContext,
+	}
+}
+
+var (
+	entropyTokenRe = regexp.MustCompile(`[A-Za-z0-9+/=_-]{16,}`)
+	hexTokenRe     = regexp.MustCompile(`^[0-9a-fA-F]+$`)
+)
+
+// maxContext bounds how much of the line before a token EntropyContext sees.
+const maxContext = 64
+
+// Scan returns all findings in text, ordered by position. Overlapping
+// matches are reported once, by the first rule that matched.
+func (s *SecretScanner) Scan(source, text string) []SecretFinding {
+	var findings []SecretFinding
+	overlaps := func(start, end int) bool {
+		for _, f := range findings {
+			if start < f.end && f.start < end {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, rule := range s.Rules {
+		if rule.Sources != nil && !rule.Sources.MatchString(source) {
+			continue
+		}
+		for _, m := range rule.Pattern.FindAllStringSubmatchIndex(text, -1) {
+			start, end := m[0], m[1]
+			if rule.Group > 0 && len(m) > 2*rule.Group+1 && m[2*rule.Group] >= 0 {
+				start, end = m[2*rule.Group], m[2*rule.Group+1]
+			}
+			if overlaps(start, end) {
+				continue
+			}
+			if secret := text[start:end]; (rule.Exclude != nil && rule.Exclude.MatchString(secret)) || ShannonEntropy(secret) < rule.MinEntropy {
+				continue
+			}
+			findings = append(findings, newFinding(source, rule.Name, text, start, end))
+		}
+	}
+
+	if s.MinTokenLength > 0 {
+		for _, m := range entropyTokenRe.FindAllStringIndex(text, -1) {
+			// Base64 only ends in '=', so an earlier one separates a name
+			// from its value, as in "token=...".
+			if i := strings.LastIndexByte(strings.TrimRight(text[m[0]:m[1]], "="), '='); i >= 0 {
+				m[0] += i + 1
+			}
+			token := text[m[0]:m[1]]
+			if len(token) < s.MinTokenLength || overlaps(m[0], m[1]) {
+				continue
+			}
+			rule, threshold := "high-entropy-base64", s.Base64Entropy
+			if hexTokenRe.MatchString(token) {
+				rule, threshold = "high-entropy-hex", s.HexEntropy
+			}
+			if threshold <= 0 || ShannonEntropy(token) <= threshold {
+				continue
+			}
+			if s.EntropyContext != nil {
+				line := text[strings.LastIndexByte(text[:m[0]], '\n')+1 : m[0]]
+				if len(line) > maxContext {
+					line = line[len(line)-maxContext:]
+				}
+				if !s.EntropyContext.MatchString(line) {
+					continue
+				}
+			}
+			findings = append(findings, newFinding(source, rule, text, m[0], m[1]))
+		}
+	}
+
+	sort.Slice(findings, func(i, j int) bool { return findings[i].start < findings[j].start })
+	return findings
+}
+
+func newFinding(source, rule, text string, start, end int) SecretFinding {
+	before := text[:start]
+	line := strings.Count(before, "\n") + 1
+	column := start - strings.LastIndex(before, "\n")
+	secret := text[start:end]
+	return SecretFinding{
+		Source:  source,
+		Rule:    rule,
+		Line:    line,
+		Column:  column,
+		Preview: maskSecret(secret),
+		Entropy: math.Round(ShannonEntropy(secret)*100) / 100,
+		start:   start,
+		end:     end,
+	}
+}
+
+// maskSecret keeps just enough of a secret to recognise it in a report.
+func maskSecret(secret string) string {
+	if i := strings.IndexByte(secret, '\n'); i >= 0 {
+		secret = secret[:i]
+	}
+	if len(secret) <= 8 {
+		return strings.Repeat("*", len(secret))
+	}
+	return secret[:4] + strings.Repeat("*", len(secret)-6) + secret[len(secret)-2:]
+}
+
+// ShannonEntropy returns the entropy of s in bits per character.
+func ShannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := make(map[rune]int)
+	n := 0
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+	var entropy float64
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// RedactSecrets replaces every finding in text with "[REDACTED:<rule>]".
+// findings must come from Scan on the same text.
+func RedactSecrets(text string, findings []SecretFinding) string {
+	var b strings.Builder
+	last := 0
+	for _, f := range findings {
+		if f.start < last {
+			continue
+		}
+		b.WriteString(text[last:f.start])
+		b.WriteString("[REDACTED:" + f.Rule + "]")
+		last = f.end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// SecretError is returned under SecretPolicyFail when a document contains secrets.
+type SecretError struct {
+	Source   string
+	Findings []SecretFinding
+}
+
+func (e *SecretError) Error() string {
+	return fmt.Sprintf("%d secret(s) found in %s (first: %s at line %d)",
+		len(e.Findings), e.Source, e.Findings[0].Rule, e.Findings[0].Line)
+}
+
+// SecretReport is the machine-readable summary of a scan over one or more documents.
+type SecretReport struct {
+	Policy   SecretPolicy    `json:"policy"`
+	Scanned  int             `json:"scanned"`
+	Dropped  []string        `json:"dropped,omitempty"`
+	Findings []SecretFinding `json:"findings"`
+}
+
+// NewSecretReport creates an empty report for the given policy.
+func NewSecretReport(policy SecretPolicy) *SecretReport {
+	return &SecretReport{Policy: policy, Findings: []SecretFinding{}}
+}
+
+// Apply scans a document and applies policy to it. It returns the text to
+// keep (redacted if required) and whether the document should be kept at
+// all. Findings are recorded in report when it is non-nil. Under
+// SecretPolicyFail a *SecretError is returned.
+func (s *SecretScanner) Apply(policy SecretPolicy, report *SecretReport, source, text string) (string, bool, error) {
+	if policy == SecretPolicyOff {
+		return text, true, nil
+	}
+	findings := s.Scan(source, text)
+	if report != nil {
+		report.Scanned++
+		report.Findings = append(report.Findings, findings...)
+	}
+	if len(findings) == 0 {
+		return text, true, nil
+	}
+	switch policy {
+	case SecretPolicyDrop:
+		if report != nil {
+			report.Dropped = append(report.Dropped, source)
+		}
+		return "", false, nil
+	case SecretPolicyFail:
+		return "", false, &SecretError{Source: source, Findings: findings}
+	default:
+		return RedactSecrets(text, findings), true, nil
+	}
+}
+
+// WriteSecretReport saves the report as indented JSON.
+func WriteSecretReport(path string, report *SecretReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write secret report: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+// processor/semantic.go
+package processor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SemanticChunker places chunk boundaries where the topic shifts. It embeds
+// a window of sentences around every sentence, measures the cosine distance
+// between adjacent windows and splits wherever the distance exceeds the
+// given percentile of all distances in the document. Chunks that still
+// exceed MaxTokens are packed sentence by sentence into smaller ones.
+type SemanticChunker struct {
+	Embedder Embedder
+	// Percentile (0-100) of adjacent distances above which a boundary is placed.
+	Percentile float64
+	// Window is the number of neighbouring sentences on each side embedded
+	// together with a sentence, which smooths out very short sentences.
+	Window int
+	// MaxTokens bounds the size of each chunk as measured by Counter; 0 disables it.
+	MaxTokens int
+	Counter   TokenCounter
+}
+
+// NewSemanticChunker returns a SemanticChunker with the 95th percentile
+// breakpoint and a window of one sentence.
+func NewSemanticChunker(embedder Embedder, maxTokens int, counter TokenCounter) *SemanticChunker {
+	if counter == nil {
+		counter = WordCounter{}
+	}
+	return &SemanticChunker{Embedder: embedder, Percentile: 95, Window: 1, MaxTokens: maxTokens, Counter: counter}
+}
+
+// Chunk splits text into semantically coherent chunks.
+func (s *SemanticChunker) Chunk(text string) ([]string, error) {
+	sentences := SplitSentences(text)
+	if len(sentences) < 3 {
+		return s.limit(sentences), nil
+	}
+
+	windows := make([]string, len(sentences))
+	for i := range sentences {
+		lo, hi := max(0, i-s.Window), min(len(sentences), i+s.Window+1)
+		windows[i] = strings.Join(sentences[lo:hi], " ")
+	}
+	vectors, err := s.Embedder.Embed(windows)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(sentences) {
+		return nil, fmt.Errorf("embedder returned %d vectors for %d sentences", len(vectors), len(sentences))
+	}
+
+	distances := make([]float64, len(sentences)-1)
+	for i := range distances {
+		distances[i] = 1 - cosineSimilarity(vectors[i], vectors[i+1])
+	}
+	threshold := percentile(distances, s.Percentile)
+
+	var groups []string
+	start := 0
+	for i, d := range distances {
+		if d > threshold {
+			groups = append(groups, strings.Join(sentences[start:i+1], " "))
+			start = i + 1
+		}
+	}
+	groups = append(groups, strings.Join(sentences[start:], " "))
+
+	var chunks []string
+	for _, g := range groups {
+		chunks = append(chunks, s.limit(SplitSentences(g))...)
+	}
+	return chunks, nil
+}
+
+// limit packs sentences greedily into chunks of at most MaxTokens.
+func (s *SemanticChunker) limit(sentences []string) []string {
+	if len(sentences) == 0 {
+		return nil
+	}
+	if s.MaxTokens <= 0 {
+		return []string{strings.Join(sentences, " ")}
+	}
+	var chunks, current []string
+	size := 0
+	for _, sentence := range sentences {
+		n := s.Counter.CountTokens(sentence)
+		if n > s.MaxTokens {
+			if len(current) > 0 {
+				chunks = append(chunks, strings.Join(current, " "))
+				current, size = nil, 0
+			}
+			chunks = append(chunks, ChunkTextByCountedTokens(sentence, s.MaxTokens, 0, s.Counter)...)
+			continue
+		}
+		if size+n > s.MaxTokens && len(current) > 0 {
+			chunks = append(chunks, strings.Join(current, " "))
+			current, size = nil, 0
+		}
+		current = append(current, sentence)
+		size += n
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, strings.Join(current, " "))
+	}
+	return chunks
+}
+
+// percentile returns the p-th percentile of values using linear
+// interpolation between closest ranks.
+func percentile(values []float64, p float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(rank)
+	if lo >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	frac := rank - float64(lo)
+	return sorted[lo] + frac*(sorted[lo+1]-sorted[lo])
+}
@@ -0,0 +1,66 @@
+// processor/sentences.go
+package processor
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// abbreviations end in a period but rarely end a sentence.
+var abbreviations = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "prof": true, "sr": true, "jr": true, "st": true,
+	"vs": true, "etc": true, "e.g": true, "i.e": true, "cf": true, "al": true, "approx": true,
+	"no": true, "nos": true, "fig": true, "figs": true, "vol": true, "p": true, "pp": true, "ch": true,
+	"sec": true, "art": true, "ref": true, "ed": true, "eds": true, "inc": true, "ltd": true, "co": true,
+	"corp": true, "jan": true, "feb": true, "mar": true, "apr": true, "jun": true, "jul": true,
+	"aug": true, "sep": true, "sept": true, "oct": true, "nov": true, "dec": true,
+	"z.b": true, "bzw": true, "usw": true, "ca": true, "vgl": true,
+}
+
+// SplitSentences splits text into sentences at '.', '!' and '?' followed by
+// whitespace and an upper-case letter, digit or opening quote. Periods after
+// known abbreviations, single-letter initials and dotted acronyms such as
+// "U.S." do not end a sentence. Whitespace inside sentences is normalized to
+// single spaces.
+func SplitSentences(text string) []string {
+	words := strings.Fields(text)
+	var sentences []string
+	start := 0
+	for i, w := range words {
+		if i+1 < len(words) && !endsSentence(w, words[i+1]) {
+			continue
+		}
+		sentences = append(sentences, strings.Join(words[start:i+1], " "))
+		start = i + 1
+	}
+	return sentences
+}
+
+// endsSentence reports whether word closes a sentence given the word after it.
+func endsSentence(word, next string) bool {
+	core := strings.TrimRight(word, `"')]}»”’`)
+	if core == "" {
+		return false
+	}
+	last, _ := utf8.DecodeLastRuneInString(core)
+	if last != '.' && last != '!' && last != '?' && last != '…' {
+		return false
+	}
+	first, _ := utf8.DecodeRuneInString(strings.TrimLeft(next, `"'([{«“‘`))
+	if !unicode.IsUpper(first) && !unicode.IsDigit(first) && !strings.ContainsRune(`"'([{«“‘`, rune(next[0])) {
+		return false
+	}
+	if last != '.' {
+		return true
+	}
+	stem := strings.ToLower(strings.TrimLeft(strings.TrimSuffix(core, "."), `"'([{«“‘`))
+	if abbreviations[stem] {
+		return false
+	}
+	// Initials ("J.") and dotted acronyms ("U.S.") rarely end a sentence.
+	if utf8.RuneCountInString(stem) == 1 || strings.Contains(stem, ".") {
+		return false
+	}
+	return true
+}
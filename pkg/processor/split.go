@@ -0,0 +1,213 @@
+// processor/split.go
+package processor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SplitNames are the dataset splits, in the order their ratios are given.
+var SplitNames = []string{"train", "val", "test"}
+
+// Stratification keys for SplitOptions.
+const (
+	StratifyNone     = ""
+	StratifyDir      = "dir"
+	StratifyLanguage = "language"
+)
+
+// SplitOptions configures SplitChunks.
+type SplitOptions struct {
+	// Ratios are the shares of train, val and test; they sum to 1.
+	Ratios []float64
+	// Stratify is StratifyNone, StratifyDir (the source's directory) or
+	// StratifyLanguage (the most common language of its chunks).
+	Stratify string
+	Seed     int64
+}
+
+// ParseSplitRatios parses "train,val[,test]" ratios such as "0.8,0.1,0.1"
+// or "80,10,10" and scales them to sum to 1.
+func ParseSplitRatios(s string) ([]float64, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) < 2 || len(parts) > len(SplitNames) {
+		return nil, fmt.Errorf("split ratios must be train,val or train,val,test, got %q", s)
+	}
+	ratios := make([]float64, len(parts))
+	var sum float64
+	for i, part := range parts {
+		r, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || r < 0 || math.IsInf(r, 0) {
+			return nil, fmt.Errorf("invalid split ratio %q", part)
+		}
+		ratios[i] = r
+		sum += r
+	}
+	if sum == 0 {
+		return nil, fmt.Errorf("split ratios must not all be zero")
+	}
+	for i := range ratios {
+		ratios[i] /= sum
+	}
+	return ratios, nil
+}
+
+// ParseStratify validates a stratification key: none (or empty), dir or language.
+func ParseStratify(s string) (string, error) {
+	switch k := strings.ToLower(strings.TrimSpace(s)); k {
+	case "", "none":
+		return StratifyNone, nil
+	case StratifyDir, StratifyLanguage:
+		return k, nil
+	default:
+		return "", fmt.Errorf("unknown stratification %q (want none, dir or language)", s)
+	}
+}
+
+// SplitStats counts the chunks and distinct documents of one split.
+type SplitStats struct {
+	Chunks    int `json:"chunks"`
+	Documents int `json:"documents"`
+}
+
+// DocumentKey identifies a document by a hash of its text with runs of
+// whitespace collapsed. SplitChunks assigns documents by this key rather
+// than by path, so a split does not depend on the working directory or on
+// how the input was named, and identical files at two paths cannot end up
+// on both sides of a split.
+func DocumentKey(text string) string {
+	h := fnv.New64a()
+	for i, word := range strings.Fields(text) {
+		if i > 0 {
+			h.Write([]byte{' '})
+		}
+		h.Write([]byte(word))
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// documentOf returns the key SplitChunks groups c by.
+func documentOf(c Chunk) string {
+	if c.Document != "" {
+		return c.Document
+	}
+	return c.Source
+}
+
+// sourceHash is the stable position of a document key in [0, 1) for seed.
+func sourceHash(key string, seed int64) float64 {
+	h := fnv.New64a()
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(seed))
+	h.Write(b[:])
+	h.Write([]byte(key))
+	return float64(h.Sum64()>>11) / (1 << 53)
+}
+
+// splitAt returns the split whose cumulative ratio range holds x.
+func splitAt(ratios []float64, x float64) string {
+	var cum float64
+	for i, r := range ratios {
+		cum += r
+		if x < cum {
+			return SplitNames[i]
+		}
+	}
+	// Rounding can leave x just above the last bound.
+	for i := len(ratios) - 1; i > 0; i-- {
+		if ratios[i] > 0 {
+			return SplitNames[i]
+		}
+	}
+	return SplitNames[0]
+}
+
+// SplitChunks assigns every document to a split and groups its chunks
+// accordingly, so overlapping chunks of one document never end up in
+// different splits. Documents are told apart by Chunk.Document, so the
+// chunks of identical documents share a split. Without stratification a
+// document's split depends only on a seeded hash of its key, so it does
+// not change as the corpus grows. With stratification the documents of
+// each stratum, the directory of the first source or the dominant
+// language, are ordered by that hash and divided by the ratios, which
+// keeps the proportions even in small strata. It returns the non-zero
+// splits in SplitNames order.
+func SplitChunks(chunks []Chunk, opts SplitOptions) ([]string, map[string][]Chunk, map[string]SplitStats) {
+	var docs []string
+	byDoc := make(map[string][]Chunk)
+	for _, c := range chunks {
+		key := documentOf(c)
+		if _, ok := byDoc[key]; !ok {
+			docs = append(docs, key)
+		}
+		byDoc[key] = append(byDoc[key], c)
+	}
+
+	assigned := make(map[string]string, len(docs))
+	if opts.Stratify == StratifyNone {
+		for _, d := range docs {
+			assigned[d] = splitAt(opts.Ratios, sourceHash(d, opts.Seed))
+		}
+	} else {
+		strata := make(map[string][]string)
+		for _, d := range docs {
+			key := filepath.Dir(byDoc[d][0].Source)
+			if opts.Stratify == StratifyLanguage {
+				key = dominantLanguage(byDoc[d])
+			}
+			strata[key] = append(strata[key], d)
+		}
+		for _, members := range strata {
+			sort.Slice(members, func(i, j int) bool {
+				hi, hj := sourceHash(members[i], opts.Seed), sourceHash(members[j], opts.Seed)
+				if hi != hj {
+					return hi < hj
+				}
+				return members[i] < members[j]
+			})
+			for i, d := range members {
+				assigned[d] = splitAt(opts.Ratios, (float64(i)+0.5)/float64(len(members)))
+			}
+		}
+	}
+
+	groups := make(map[string][]Chunk)
+	stats := make(map[string]SplitStats)
+	for _, d := range docs {
+		split := assigned[d]
+		groups[split] = append(groups[split], byDoc[d]...)
+		st := stats[split]
+		st.Chunks += len(byDoc[d])
+		st.Documents++
+		stats[split] = st
+	}
+	var names []string
+	for i, name := range SplitNames {
+		if i < len(opts.Ratios) && opts.Ratios[i] > 0 {
+			names = append(names, name)
+		}
+	}
+	return names, groups, stats
+}
+
+// dominantLanguage returns the most common language of chunks, preferring
+// the alphabetically first on ties.
+func dominantLanguage(chunks []Chunk) string {
+	counts := make(map[string]int)
+	for _, c := range chunks {
+		counts[c.Language]++
+	}
+	best := ""
+	for lang, n := range counts {
+		if n > counts[best] || (n == counts[best] && lang < best) {
+			best = lang
+		}
+	}
+	return best
+}
@@ -0,0 +1,208 @@
+package processor
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// splitCorpus returns three chunks for each of n documents, spread over
+// dirs directories, with the document key set as the pipeline sets it.
+func splitCorpus(n, dirs int) []Chunk {
+	var chunks []Chunk
+	for i := 0; i < n; i++ {
+		source := fmt.Sprintf("dir%d/doc%03d.md", i%dirs, i)
+		text := fmt.Sprintf("document %d", i)
+		for j := 0; j < 3; j++ {
+			chunks = append(chunks, Chunk{Text: fmt.Sprintf("%s part %d", text, j), Source: source, Document: DocumentKey(text)})
+		}
+	}
+	return chunks
+}
+
+// assignment maps every source to the split its chunks went to, failing
+// if the chunks of one document are in more than one split.
+func assignment(t *testing.T, groups map[string][]Chunk) map[string]string {
+	t.Helper()
+	splits := make(map[string]string)
+	for name, chunks := range groups {
+		for _, c := range chunks {
+			if prev, ok := splits[c.Source]; ok && prev != name {
+				t.Fatalf("%s is in both %s and %s", c.Source, prev, name)
+			}
+			splits[c.Source] = name
+		}
+	}
+	return splits
+}
+
+func TestSplitChunks(t *testing.T) {
+	ratios := []float64{0.8, 0.1, 0.1}
+	chunks := splitCorpus(300, 3)
+	for _, stratify := range []string{StratifyNone, StratifyDir} {
+		t.Run("stratify "+stratify, func(t *testing.T) {
+			opts := SplitOptions{Ratios: ratios, Stratify: stratify, Seed: 7}
+			names, groups, stats := SplitChunks(chunks, opts)
+			if !reflect.DeepEqual(names, SplitNames) {
+				t.Errorf("names = %v", names)
+			}
+			splits := assignment(t, groups)
+			if len(splits) != 300 {
+				t.Fatalf("%d documents assigned, want 300", len(splits))
+			}
+			total := 0
+			for _, name := range names {
+				st := stats[name]
+				if st.Chunks != len(groups[name]) || st.Chunks != 3*st.Documents {
+					t.Errorf("%s: stats %+v for %d chunks", name, st, len(groups[name]))
+				}
+				total += st.Documents
+			}
+			if total != 300 {
+				t.Errorf("stats count %d documents, want 300", total)
+			}
+
+			// Reruns, and runs on a shuffled corpus, give the same splits.
+			shuffled := append([]Chunk(nil), chunks...)
+			for i, j := 0, len(shuffled)-1; i < j; i, j = i+1, j-1 {
+				shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+			}
+			_, again, _ := SplitChunks(shuffled, opts)
+			if got := assignment(t, again); !reflect.DeepEqual(got, splits) {
+				t.Error("splits differ between runs")
+			}
+		})
+	}
+}
+
+func TestSplitChunksStratifiedRatios(t *testing.T) {
+	// 50 documents in each of two directories.
+	chunks := splitCorpus(100, 2)
+	for _, ratios := range [][]float64{{0.8, 0.1, 0.1}, {0.5, 0.5}, {0.6, 0, 0.4}} {
+		_, groups, _ := SplitChunks(chunks, SplitOptions{Ratios: ratios, Stratify: StratifyDir, Seed: 1})
+		counts := make(map[string]map[string]int)
+		for source, split := range assignment(t, groups) {
+			dir := source[:4]
+			if counts[dir] == nil {
+				counts[dir] = make(map[string]int)
+			}
+			counts[dir][split]++
+		}
+		for dir, c := range counts {
+			for i, r := range ratios {
+				if want := int(math.Round(r * 50)); c[SplitNames[i]] != want {
+					t.Errorf("ratios %v: %s has %d %s documents, want %d", ratios, dir, c[SplitNames[i]], SplitNames[i], want)
+				}
+			}
+		}
+	}
+}
+
+func TestSplitChunksStableAsCorpusGrows(t *testing.T) {
+	opts := SplitOptions{Ratios: []float64{0.7, 0.2, 0.1}, Seed: 3}
+	_, small, _ := SplitChunks(splitCorpus(100, 1), opts)
+	_, large, _ := SplitChunks(splitCorpus(400, 1), opts)
+	before, after := assignment(t, small), assignment(t, large)
+	for source, split := range before {
+		if after[source] != split {
+			t.Errorf("%s moved from %s to %s as the corpus grew", source, split, after[source])
+		}
+	}
+	// Another seed gives another assignment.
+	opts.Seed = 4
+	_, other, _ := SplitChunks(splitCorpus(100, 1), opts)
+	if reflect.DeepEqual(assignment(t, other), before) {
+		t.Error("the seed does not change the assignment")
+	}
+}
+
+func TestSplitChunksByContent(t *testing.T) {
+	text := "The same README text."
+	var chunks []Chunk
+	for _, source := range []string{"a/README.md", "/abs/b/README.md", "./c/README.md"} {
+		chunks = append(chunks, Chunk{Text: text, Source: source, Document: DocumentKey(text)})
+	}
+	for i := 0; i < 50; i++ {
+		doc := fmt.Sprintf("other %d", i)
+		chunks = append(chunks, Chunk{Text: doc, Source: fmt.Sprintf("other%d.md", i), Document: DocumentKey(doc)})
+	}
+	for seed := int64(0); seed < 20; seed++ {
+		_, groups, stats := SplitChunks(chunks, SplitOptions{Ratios: []float64{0.34, 0.33, 0.33}, Seed: seed})
+		splits := assignment(t, groups)
+		if splits["a/README.md"] != splits["/abs/b/README.md"] || splits["a/README.md"] != splits["./c/README.md"] {
+			t.Fatalf("seed %d: identical documents in different splits: %v", seed, splits)
+		}
+		docs := 0
+		for _, st := range stats {
+			docs += st.Documents
+		}
+		if docs != 51 {
+			t.Errorf("seed %d: %d documents, want 51 distinct ones", seed, docs)
+		}
+	}
+
+	// The key ignores the path and differences in whitespace.
+	if DocumentKey("a  b\r\nc") != DocumentKey("a b\nc") || DocumentKey("a b") == DocumentKey("a c") {
+		t.Error("DocumentKey does not compare text by its words")
+	}
+	// Without keys, chunks are grouped by source.
+	_, groups, stats := SplitChunks([]Chunk{{Source: "x"}, {Source: "x"}, {Source: "y"}}, SplitOptions{Ratios: []float64{1, 0}})
+	if len(groups["train"]) != 3 || stats["train"].Documents != 2 {
+		t.Errorf("grouping by source: %d chunks in %+v", len(groups["train"]), stats)
+	}
+}
+
+func TestSplitAt(t *testing.T) {
+	for _, tc := range []struct {
+		ratios []float64
+		x      float64
+		want   string
+	}{
+		{[]float64{0.8, 0.1, 0.1}, 0, "train"},
+		{[]float64{0.8, 0.1, 0.1}, 0.79, "train"},
+		{[]float64{0.8, 0.1, 0.1}, 0.8, "val"},
+		{[]float64{0.8, 0.1, 0.1}, 0.95, "test"},
+		// Added up in float64, the ratios come to 0.9999999999999999, which
+		// leaves the largest float below 1 past the last bound.
+		{[]float64{0.7, 0.2, 0.1}, math.Nextafter(1, 0), "test"},
+		{[]float64{0.7, 0.2, 0.1}, 1, "test"},
+		// Rounding past the end goes to the last split with a share.
+		{[]float64{0.5, 0.5, 0}, 1, "val"},
+		{[]float64{0.5, 0.5}, 1, "val"},
+		{[]float64{1, 0, 0}, 1, "train"},
+		{[]float64{0, 1, 0}, 0, "val"},
+	} {
+		if got := splitAt(tc.ratios, tc.x); got != tc.want {
+			t.Errorf("splitAt(%v, %v) = %s, want %s", tc.ratios, tc.x, got, tc.want)
+		}
+	}
+}
+
+func TestParseSplitRatios(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want []float64
+	}{
+		{"0.8,0.1,0.1", []float64{0.8, 0.1, 0.1}},
+		{"80, 10, 10", []float64{0.8, 0.1, 0.1}},
+		{"3,1", []float64{0.75, 0.25}},
+		{"1", nil},
+		{"1,1,1,1", nil},
+		{"0,0", nil},
+		{"0.8,-0.1,0.3", nil},
+		{"a,b", nil},
+	} {
+		got, err := ParseSplitRatios(tc.in)
+		if (err == nil) != (tc.want != nil) {
+			t.Errorf("ParseSplitRatios(%q) error = %v", tc.in, err)
+			continue
+		}
+		for i := range tc.want {
+			if math.Abs(got[i]-tc.want[i]) > 1e-12 {
+				t.Errorf("ParseSplitRatios(%q) = %v, want %v", tc.in, got, tc.want)
+				break
+			}
+		}
+	}
+}
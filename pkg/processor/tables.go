@@ -0,0 +1,213 @@
+// processor/tables.go
+package processor
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Table blocks are serialized tables embedded in extracted text between a
+// start line ("[[table]]" or "[[table: caption]]") and an end line
+// ("[[/table]]"). ChunkDocument keeps each block whole.
+const (
+	tableStart = "[[table"
+	tableEnd   = "[[/table]]"
+)
+
+// FormatTableBlock wraps a serialized table in the lines that
+// SplitTableBlocks recognizes, escaping body lines that read as markers.
+// Line breaks in caption become spaces.
+func FormatTableBlock(body, caption string) string {
+	start := tableStart + "]]"
+	if caption = strings.Join(strings.Fields(caption), " "); caption != "" {
+		start = tableStart + ": " + caption + "]]"
+	}
+	return start + "\n" + EscapeMarkers(strings.TrimSpace(body)) + "\n" + tableEnd
+}
+
+// TextSegment is a run of prose or a single table block of a text.
+type TextSegment struct {
+	Text    string
+	Table   bool
+	Caption string
+	// Page and Section are those in effect where the segment starts, as
+	// set by position markers; prose may hold further markers.
+	Page    int
+	Section string
+}
+
+// SplitTableBlocks splits text into prose segments and table blocks, in
+// order. A start line without a matching end line is treated as prose.
+// Table bodies are unescaped; prose keeps its markers and escapes.
+func SplitTableBlocks(text string) []TextSegment {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(text, "\n")
+	var segments []TextSegment
+	var prose []string
+	var cur, start position
+	flush := func() {
+		if p := strings.Join(prose, "\n"); strings.TrimSpace(StripPositionMarkers(p)) != "" {
+			segments = append(segments, TextSegment{Text: p, Page: start.page, Section: start.section})
+		}
+		prose = nil
+	}
+	for i := 0; i < len(lines); i++ {
+		if len(prose) == 0 {
+			start = cur
+		}
+		if parsePositionMarker(lines[i], &cur) {
+			prose = append(prose, lines[i])
+			continue
+		}
+		caption, ok := parseTableStart(lines[i])
+		end := -1
+		if ok {
+			for j := i + 1; j < len(lines); j++ {
+				if strings.TrimSpace(lines[j]) == tableEnd {
+					end = j
+					break
+				}
+			}
+		}
+		if end < 0 {
+			prose = append(prose, lines[i])
+			continue
+		}
+		flush()
+		body := UnescapeMarkers(strings.TrimSpace(strings.Join(lines[i+1:end], "\n")))
+		if body != "" {
+			segments = append(segments, TextSegment{Text: body, Table: true, Caption: caption, Page: cur.page, Section: cur.section})
+		}
+		i = end
+	}
+	flush()
+	return segments
+}
+
+// StripMarkers removes the position markers and table block lines of text,
+// leaving the prose, the captions and the table bodies as paragraphs.
+func StripMarkers(text string) string {
+	var parts []string
+	for _, seg := range SplitTableBlocks(text) {
+		if !seg.Table {
+			parts = append(parts, StripPositionMarkers(seg.Text))
+			continue
+		}
+		if seg.Caption != "" {
+			parts = append(parts, seg.Caption)
+		}
+		parts = append(parts, seg.Text)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// parseTableStart reports whether line opens a table block and returns its caption.
+func parseTableStart(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, tableStart) || !strings.HasSuffix(line, "]]") {
+		return "", false
+	}
+	rest := strings.TrimSuffix(strings.TrimPrefix(line, tableStart), "]]")
+	if rest == "" {
+		return "", true
+	}
+	if !strings.HasPrefix(rest, ":") {
+		return "", false
+	}
+	return strings.TrimSpace(rest[1:]), true
+}
+
+// ChunkDocument chunks extracted text that may contain table blocks. Prose
+// between tables is cleaned and chunked according to o; tables become
+// chunks of their own with their caption attached, split between rows if
+// they exceed the chunk size, with the header repeated in every part.
+// Position markers are removed and every chunk is tagged with the page and
+// section it starts in.
+func (o ChunkOptions) ChunkDocument(text string) ([]Chunk, error) {
+	var chunks []Chunk
+	for _, seg := range SplitTableBlocks(text) {
+		if seg.Table {
+			for _, part := range o.splitTable(seg.Text) {
+				chunks = append(chunks, Chunk{Text: part, Table: true, Caption: seg.Caption, Page: seg.Page, Section: seg.Section})
+			}
+			continue
+		}
+		prose, positions := stripPositions(seg.Text, position{page: seg.Page, section: seg.Section})
+		var clean string
+		if KeepsParagraphs(o.Chunker) {
+			clean = CleanTextKeepParagraphs(prose)
+		} else {
+			clean = CleanText(prose)
+		}
+		texts, err := o.Chunk(clean)
+		if err != nil {
+			return nil, err
+		}
+		first := len(chunks)
+		for _, t := range texts {
+			chunks = append(chunks, Chunk{Text: t})
+		}
+		locateChunks(clean, chunks[first:], positions)
+	}
+	return chunks, nil
+}
+
+// markdownRule matches the line under the header of a Markdown table.
+var markdownRule = regexp.MustCompile(`^\|(\s*:?-+:?\s*\|)+$`)
+
+// splitTable divides a serialized table larger than the chunk size into
+// runs of whole rows, each led by the header: the first line, and the rule
+// under it for Markdown. CSV rows may span lines inside quotes. Sizes are
+// measured like chunks, in characters for ChunkerFixed and in tokens
+// otherwise; ChunkerParagraph has no size and keeps tables whole, as does
+// a single row larger than the chunk size.
+func (o ChunkOptions) splitTable(table string) []string {
+	if o.Size <= 0 || o.Chunker == ChunkerParagraph {
+		return []string{table}
+	}
+	size := func(s string) int {
+		if o.Chunker == ChunkerFixed {
+			return utf8.RuneCountInString(s) + 1
+		}
+		return o.counter().CountTokens(s)
+	}
+	if size(table) <= o.Size {
+		return []string{table}
+	}
+
+	lines := strings.Split(table, "\n")
+	headerLines := 1
+	markdown := len(lines) > 1 && markdownRule.MatchString(strings.TrimSpace(lines[1]))
+	if markdown {
+		headerLines = 2
+	}
+	var rows []string
+	for i := headerLines; i < len(lines); i++ {
+		row := lines[i]
+		// An odd number of quotes leaves a CSV field open.
+		for !markdown && strings.Count(row, `"`)%2 == 1 && i+1 < len(lines) {
+			i++
+			row += "\n" + lines[i]
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) < 2 {
+		return []string{table}
+	}
+
+	header := strings.Join(lines[:headerLines], "\n")
+	headerSize := size(header)
+	var parts []string
+	part, partSize := []string{header}, headerSize
+	for _, row := range rows {
+		n := size(row)
+		if len(part) > 1 && partSize+n > o.Size {
+			parts = append(parts, strings.Join(part, "\n"))
+			part, partSize = []string{header}, headerSize
+		}
+		part = append(part, row)
+		partSize += n
+	}
+	return append(parts, strings.Join(part, "\n"))
+}
@@ -0,0 +1,179 @@
+package processor
+
+import (
+	"encoding/csv"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func markdownTable(rows int) string {
+	lines := []string{"| Name | Value |", "| --- | --- |"}
+	for i := 0; i < rows; i++ {
+		lines = append(lines, fmt.Sprintf("| item %d | %d |", i, i*i))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestChunkDocumentSplitsLargeTables(t *testing.T) {
+	opts := ChunkOptions{Chunker: ChunkerRecursive, Size: 40}
+	text := "Intro paragraph.\n" + FormatTableBlock(markdownTable(20), "Squares") + "\nOutro paragraph."
+	chunks, err := opts.ChunkDocument(text)
+	if err != nil {
+		t.Fatalf("ChunkDocument: %v", err)
+	}
+	var parts []Chunk
+	for _, c := range chunks {
+		if c.Table {
+			parts = append(parts, c)
+		}
+	}
+	if len(parts) < 2 {
+		t.Fatalf("table became %d chunk(s), want several", len(parts))
+	}
+	var rows []string
+	for i, p := range parts {
+		lines := strings.Split(p.Text, "\n")
+		if lines[0] != "| Name | Value |" || lines[1] != "| --- | --- |" {
+			t.Errorf("part %d does not start with the header: %q", i, p.Text)
+		}
+		if p.Caption != "Squares" {
+			t.Errorf("part %d has caption %q", i, p.Caption)
+		}
+		if n := (WordCounter{}).CountTokens(p.Text); n > opts.Size {
+			t.Errorf("part %d has %d tokens, more than %d", i, n, opts.Size)
+		}
+		rows = append(rows, lines[2:]...)
+	}
+	if want := strings.Split(markdownTable(20), "\n")[2:]; strings.Join(rows, "\n") != strings.Join(want, "\n") {
+		t.Errorf("rows across parts = %q, want %q", rows, want)
+	}
+}
+
+func TestSplitTable(t *testing.T) {
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	w.Write([]string{"id", "note"})
+	for i := 0; i < 12; i++ {
+		w.Write([]string{fmt.Sprint(i), fmt.Sprintf("line one of %d\nline \"two\" of %d", i, i)})
+	}
+	w.Flush()
+	csvTable := strings.TrimSpace(b.String())
+
+	for _, tc := range []struct {
+		name  string
+		opts  ChunkOptions
+		table string
+		parts int
+	}{
+		{"small table", ChunkOptions{Chunker: ChunkerToken, Size: 200}, markdownTable(3), 1},
+		{"paragraph chunker", ChunkOptions{Chunker: ChunkerParagraph}, markdownTable(50), 1},
+		{"fixed chunker counts characters", ChunkOptions{Chunker: ChunkerFixed, Size: 100}, markdownTable(20), 6},
+		{"csv with multi-line fields", ChunkOptions{Chunker: ChunkerToken, Size: 30}, csvTable, 4},
+		{"row larger than the size", ChunkOptions{Chunker: ChunkerToken, Size: 3}, markdownTable(2), 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			parts := tc.opts.splitTable(tc.table)
+			if len(parts) != tc.parts {
+				t.Fatalf("%d parts, want %d: %q", len(parts), tc.parts, parts)
+			}
+			header := strings.SplitN(tc.table, "\n", 2)[0]
+			var records int
+			for i, p := range parts {
+				if !strings.HasPrefix(p, header+"\n") && len(parts) > 1 {
+					t.Errorf("part %d does not start with the header: %q", i, p)
+				}
+				if tc.opts.Chunker == ChunkerFixed && len(p) > tc.opts.Size {
+					t.Errorf("part %d has %d characters, more than %d", i, len(p), tc.opts.Size)
+				}
+				if strings.HasPrefix(tc.table, "id,") {
+					got, err := csv.NewReader(strings.NewReader(p)).ReadAll()
+					if err != nil {
+						t.Fatalf("part %d is not valid CSV: %v\n%s", i, err, p)
+					}
+					records += len(got) - 1
+				}
+			}
+			if strings.HasPrefix(tc.table, "id,") && records != 12 {
+				t.Errorf("parts hold %d records, want 12", records)
+			}
+		})
+	}
+}
+
+func TestStripMarkers(t *testing.T) {
+	text := strings.Join([]string{
+		FormatPageMarker(1),
+		FormatSectionMarker("Results"),
+		"Intro paragraph.",
+		FormatTableBlock("| a | b |\n| --- | --- |\n| 1 | 2 |", "Table 1: Scores"),
+		FormatPageMarker(2),
+		"Outro paragraph.",
+		FormatTableBlock("x,y", ""),
+	}, "\n")
+	want := "Intro paragraph.\n\nTable 1: Scores\n\n| a | b |\n| --- | --- |\n| 1 | 2 |\n\nOutro paragraph.\n\nx,y"
+	if got := StripMarkers(text); got != want {
+		t.Errorf("StripMarkers = %q, want %q", got, want)
+	}
+	if got := StripMarkers("no markers\nat all"); got != "no markers\nat all" {
+		t.Errorf("StripMarkers changed plain text to %q", got)
+	}
+}
+
+func TestEscapeMarkers(t *testing.T) {
+	literal := strings.Join([]string{
+		"Markers written as text:",
+		"[[page 2]]",
+		"  [[section: Intro]]",
+		"[[table: Totals]]",
+		"[[table]]",
+		"[[/table]]",
+		`\[[page 3]]`,
+		`\\[[/table]]`,
+		"inline [[page 2]] stays",
+		"[[page two]]",
+	}, "\n")
+	escaped := EscapeMarkers(literal)
+	want := strings.Join([]string{
+		"Markers written as text:",
+		`\[[page 2]]`,
+		`  \[[section: Intro]]`,
+		`\[[table: Totals]]`,
+		`\[[table]]`,
+		`\[[/table]]`,
+		`\\[[page 3]]`,
+		`\\\[[/table]]`,
+		"inline [[page 2]] stays",
+		"[[page two]]",
+	}, "\n")
+	if escaped != want {
+		t.Fatalf("EscapeMarkers = %q, want %q", escaped, want)
+	}
+	if got := UnescapeMarkers(escaped); got != literal {
+		t.Errorf("UnescapeMarkers = %q, want %q", got, literal)
+	}
+
+	// Escaped lines are prose to every reader of markers.
+	text := FormatPageMarker(4) + "\n" + escaped + "\n" + FormatTableBlock("a,b\n[[/table]]\n[[page 9]]", "")
+	segments := SplitTableBlocks(text)
+	if len(segments) != 2 || segments[0].Table || !segments[1].Table || segments[1].Page != 4 {
+		t.Fatalf("segments = %+v", segments)
+	}
+	if segments[1].Text != "a,b\n[[/table]]\n[[page 9]]" {
+		t.Errorf("table body = %q", segments[1].Text)
+	}
+	if got := StripMarkers(text); got != literal+"\n\na,b\n[[/table]]\n[[page 9]]" {
+		t.Errorf("StripMarkers = %q", got)
+	}
+	chunks, err := ChunkOptions{Chunker: ChunkerParagraph}.ChunkDocument(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 2 || chunks[0].Page != 4 || chunks[0].Section != "" || !chunks[1].Table {
+		t.Fatalf("chunks = %+v", chunks)
+	}
+	if got := strings.Fields(chunks[0].Text); !reflect.DeepEqual(got, strings.Fields(literal)) {
+		t.Errorf("prose chunk = %q, want the literal lines", chunks[0].Text)
+	}
+}
@@ -0,0 +1,136 @@
+// processor/tokens.go
+package processor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/anurag-bit/goetl/utils"
+)
+
+// TokenCounter measures the length of a text in model tokens.
+// *utils.Tokenizer implements it.
+type TokenCounter interface {
+	CountTokens(text string) int
+}
+
+// WordCounter counts whitespace-separated words, the unit chunk sizes were
+// historically measured in.
+type WordCounter struct{}
+
+// CountTokens returns the number of words in text.
+func (WordCounter) CountTokens(text string) int {
+	return len(strings.Fields(text))
+}
+
+// LoadTokenCounter returns the TokenCounter selected by a flag or API value:
+// "words" (or empty) counts words, "bpe" loads the merges file at path into
+// a utils.Tokenizer and "bytebpe" into a byte-level one. For both, a .json
+// path is read as a tokenizer saved by utils.SaveTokenizer instead, which
+// must be the case for "unigram" (see utils.TrainUnigram). "hf"
+// loads a Hugging Face tokenizer.json and "tiktoken" a tiktoken rank file
+// such as cl100k_base.tiktoken.
+func LoadTokenCounter(kind, path string) (TokenCounter, error) {
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	switch kind {
+	case "", "words":
+		return WordCounter{}, nil
+	case "bpe", "bytebpe":
+		if path == "" {
+			return nil, fmt.Errorf("%s tokenizer needs a merges file", kind)
+		}
+		if strings.EqualFold(filepath.Ext(path), ".json") {
+			t, err := utils.LoadTokenizer(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load tokenizer: %v", err)
+			}
+			return t, nil
+		}
+		merges, err := utils.LoadMerges(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load merges: %v", err)
+		}
+		if kind == "bytebpe" {
+			return utils.NewByteLevelTokenizer(merges), nil
+		}
+		return utils.NewTokenizer(merges, "</w>"), nil
+	case "unigram":
+		if path == "" {
+			return nil, fmt.Errorf("%s tokenizer needs a saved tokenizer file", kind)
+		}
+		t, err := utils.LoadTokenizer(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tokenizer: %v", err)
+		}
+		if t.Scores == nil {
+			return nil, fmt.Errorf("%s is not a unigram tokenizer", path)
+		}
+		return t, nil
+	case "hf", "tiktoken":
+		if path == "" {
+			return nil, fmt.Errorf("%s tokenizer needs a vocabulary file", kind)
+		}
+		load := utils.LoadHFTokenizer
+		if kind == "tiktoken" {
+			load = utils.LoadTiktoken
+		}
+		t, err := load(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tokenizer: %v", err)
+		}
+		return t, nil
+	default:
+		return nil, fmt.Errorf("unknown tokenizer %q (want words, bpe, bytebpe, unigram, hf or tiktoken)", kind)
+	}
+}
+
+// ChunkTextByCountedTokens works like ChunkTextBySearchableTokens but
+// measures tokenSize and overlap with counter, so that a chunk never holds
+// more than tokenSize model tokens. Chunks still break between words; a
+// single word longer than tokenSize becomes a chunk of its own. Words are
+// measured with the space before them, as byte-level tokenizers encode
+// " word" differently from "word", and every chunk is counted as a whole
+// before it is accepted.
+func ChunkTextByCountedTokens(text string, tokenSize int, overlap int, counter TokenCounter) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 || tokenSize <= 0 {
+		return nil
+	}
+	// counts[i] is the size of word i inside a chunk, spaced[i] of word i
+	// after another word.
+	counts := make([]int, len(words))
+	spaced := make([]int, len(words))
+	for i, w := range words {
+		counts[i] = counter.CountTokens(w)
+		spaced[i] = counter.CountTokens(" " + w)
+	}
+
+	var chunks []string
+	start := 0
+	for {
+		end, size := start+1, counts[start]
+		for end < len(words) && size+spaced[end] <= tokenSize {
+			size += spaced[end]
+			end++
+		}
+		chunk := strings.Join(words[start:end], " ")
+		for end-start > 1 && counter.CountTokens(chunk) > tokenSize {
+			end--
+			chunk = strings.Join(words[start:end], " ")
+		}
+		chunks = append(chunks, chunk)
+		if end == len(words) {
+			break
+		}
+		// Step back over trailing words worth at most overlap tokens, but
+		// always advance by at least one word.
+		next, back := end, 0
+		for next-1 > start && back+spaced[next-1] <= overlap {
+			next--
+			back += spaced[next]
+		}
+		start = next
+	}
+	return chunks
+}
@@ -0,0 +1,71 @@
+package processor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/anurag-bit/goetl/utils"
+)
+
+// lineInitialTokenizer is a byte-level BPE trained on words that only ever
+// start a line, so "word" is one token while " word" is several.
+func lineInitialTokenizer(t *testing.T) *utils.Tokenizer {
+	t.Helper()
+	words := []string{"alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf", "hotel"}
+	var corpus strings.Builder
+	for i := 0; i < 20; i++ {
+		corpus.WriteString(strings.Join(words, "\n") + "\n")
+	}
+	merges, err := utils.TrainByteLevelBPE(corpus.String(), 60)
+	if err != nil {
+		t.Fatalf("TrainByteLevelBPE: %v", err)
+	}
+	tok := utils.NewByteLevelTokenizer(merges)
+	if n, spaced := tok.CountTokens("charlie"), tok.CountTokens(" charlie"); n != 1 || spaced <= n {
+		t.Fatalf("tokenizer counts charlie as %d and \" charlie\" as %d tokens", n, spaced)
+	}
+	return tok
+}
+
+func TestChunkTextByCountedTokensLimit(t *testing.T) {
+	tok := lineInitialTokenizer(t)
+	text := strings.Repeat("alpha bravo charlie delta echo foxtrot golf hotel ", 10)
+	for _, tc := range []struct {
+		name          string
+		counter       TokenCounter
+		size, overlap int
+	}{
+		{"bytebpe", tok, 16, 0},
+		{"bytebpe overlap", tok, 16, 4},
+		{"bytebpe large", tok, 100, 10},
+		{"words", WordCounter{}, 16, 4},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			chunks := ChunkTextByCountedTokens(text, tc.size, tc.overlap, tc.counter)
+			if len(chunks) < 2 {
+				t.Fatalf("got %d chunks", len(chunks))
+			}
+			for _, c := range chunks {
+				if n := tc.counter.CountTokens(c); n > tc.size && len(strings.Fields(c)) > 1 {
+					t.Errorf("chunk %q counts %d tokens, limit %d", c, n, tc.size)
+				}
+			}
+			last := chunks[len(chunks)-1]
+			if !strings.HasSuffix(strings.TrimSpace(text), last) {
+				t.Errorf("last chunk %q does not end the text", last)
+			}
+		})
+	}
+}
+
+func TestChunkTextByCountedTokensLongWord(t *testing.T) {
+	chunks := ChunkTextByCountedTokens("a "+strings.Repeat("x", 50)+" b", 3, 0, charCounter{})
+	if len(chunks) != 3 || chunks[1] != strings.Repeat("x", 50) {
+		t.Errorf("got %q, want the long word as a chunk of its own", chunks)
+	}
+}
+
+// charCounter counts bytes, so a long word exceeds any small limit.
+type charCounter struct{}
+
+func (charCounter) CountTokens(text string) int { return len(text) }
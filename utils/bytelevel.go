@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// byteEncoder maps every byte to a printable rune that is not whitespace,
+// as in GPT-2: printable Latin-1 bytes map to themselves, the rest to runes
+// from U+0100 up. Tokens of a byte-level tokenizer are strings of these
+// runes, so they never contain spaces and can be stored in merges files.
+var byteEncoder, byteDecoder = bytesToUnicode()
+
+func bytesToUnicode() ([256]rune, map[rune]byte) {
+	var enc [256]rune
+	dec := make(map[rune]byte, 256)
+	n := 0
+	for b := 0; b < 256; b++ {
+		if (b >= '!' && b <= '~') || (b >= 0xA1 && b <= 0xAC) || (b >= 0xAE && b <= 0xFF) {
+			enc[b] = rune(b)
+		} else {
+			enc[b] = rune(256 + n)
+			n++
+		}
+		dec[enc[b]] = byte(b)
+	}
+	return enc, dec
+}
+
+// NewByteLevelTokenizer returns a Tokenizer that works on the UTF-8 bytes of
+// its input, so any text, including whitespace and invalid UTF-8, encodes
+// losslessly and Detokenize(Tokenize(x)) == x.
+func NewByteLevelTokenizer(merges []Pair) *Tokenizer {
+	t := NewTokenizer(merges, "")
+	t.ByteLevel = true
+	return t
+}
+
+// byteSymbols returns the byte-level symbols of a pre-token, one per byte.
+func byteSymbols(word string) []string {
+	symbols := make([]string, len(word))
+	for i := 0; i < len(word); i++ {
+		symbols[i] = string(byteEncoder[word[i]])
+	}
+	return symbols
+}
+
+// decodeBytes turns byte-level symbols back into the original bytes. Runes
+// outside the byte alphabet are kept as they are.
+func decodeBytes(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if c, ok := byteDecoder[r]; ok {
+			b.WriteByte(c)
+		} else if r != ' ' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// contractions are split off as tokens of their own, as in GPT-2.
+var contractions = []string{"'s", "'t", "'re", "'ve", "'m", "'ll", "'d"}
+
+// PreTokenize splits text into the pieces byte-level BPE merges within,
+// following the GPT-2 pattern
+//
+//	's|'t|'re|'ve|'m|'ll|'d| ?\p{L}+| ?\p{N}+| ?[^\s\p{L}\p{N}]+|\s+(?!\S)|\s+
+//
+// which Go's regexp cannot express because of the lookahead. Concatenating
+// the pieces gives back text.
+func PreTokenize(text string) []string {
+	var pieces []string
+	for i := 0; i < len(text); {
+		n := preTokenLen(text[i:])
+		pieces = append(pieces, text[i:i+n])
+		i += n
+	}
+	return pieces
+}
+
+// preTokenLen returns the length in bytes of the first pre-token of s.
+func preTokenLen(s string) int {
+	for _, c := range contractions {
+		if strings.HasPrefix(s, c) {
+			return len(c)
+		}
+	}
+	r, _ := utf8.DecodeRuneInString(s)
+	start := 0
+	if r == ' ' && len(s) > 1 {
+		if next, _ := utf8.DecodeRuneInString(s[1:]); !unicode.IsSpace(next) {
+			start, r = 1, next
+		}
+	}
+	if start == 0 && unicode.IsSpace(r) {
+		// Whitespace followed by a non-space leaves its last character to the
+		// next piece, which may then start with a space.
+		end, last := 0, 0
+		for end < len(s) {
+			c, n := utf8.DecodeRuneInString(s[end:])
+			if !unicode.IsSpace(c) {
+				break
+			}
+			last = end
+			end += n
+		}
+		if end < len(s) && last > 0 {
+			return last
+		}
+		return end
+	}
+	class := runeClass(r)
+	end := start
+	for end < len(s) {
+		c, n := utf8.DecodeRuneInString(s[end:])
+		if runeClass(c) != class {
+			break
+		}
+		end += n
+	}
+	return end
+}
+
+// runeClass groups runes the way the pre-tokenization pattern does.
+func runeClass(r rune) int {
+	switch {
+	case unicode.IsLetter(r):
+		return 1
+	case unicode.IsNumber(r):
+		return 2
+	case unicode.IsSpace(r):
+		return 3
+	default:
+		// Includes utf8.RuneError, so invalid bytes group with punctuation.
+		return 4
+	}
+}
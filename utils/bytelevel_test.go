@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+const byteLevelCorpus = `The quick brown fox jumps over the lazy dog.
+	Indented lines,   runs of spaces and tabs	stay as they are.
+Numbers like 12345 and 2024-06-01, contractions like don't and we'll.
+Ünïcödé text, emoji 🦊 and CJK 日本語 are bytes too.`
+
+func byteLevelTokenizer(t *testing.T) *Tokenizer {
+	t.Helper()
+	merges, err := TrainByteLevelBPE(strings.Repeat(byteLevelCorpus+"\n", 5), 200)
+	if err != nil {
+		t.Fatalf("TrainByteLevelBPE: %v", err)
+	}
+	tok := NewByteLevelTokenizer(merges)
+	tok.BuildVocab(nil)
+	return tok
+}
+
+// checkRoundTrip checks that text survives Tokenize, Detokenize, Encode and
+// Decode unchanged and that CountTokens agrees with Encode.
+func checkRoundTrip(t *testing.T, tok *Tokenizer, text string) {
+	t.Helper()
+	if got := tok.Detokenize(tok.Tokenize(text)); got != text {
+		t.Errorf("Detokenize(Tokenize(%q)) = %q", text, got)
+	}
+	ids, err := tok.Encode(text)
+	if err != nil {
+		t.Fatalf("Encode(%q): %v", text, err)
+	}
+	if n := tok.CountTokens(text); n != len(ids) {
+		t.Errorf("CountTokens(%q) = %d, Encode gave %d IDs", text, n, len(ids))
+	}
+	got, err := tok.Decode(ids)
+	if err != nil {
+		t.Fatalf("Decode(%q): %v", text, err)
+	}
+	if got != text {
+		t.Errorf("Decode(Encode(%q)) = %q", text, got)
+	}
+}
+
+func TestByteLevelRoundTrip(t *testing.T) {
+	tok := byteLevelTokenizer(t)
+	for _, text := range []string{
+		"",
+		byteLevelCorpus,
+		"the quick brown fox",
+		" leading and trailing spaces ",
+		"\t\ttabs\n\n\nnewlines\r\n",
+		"   ",
+		"a  b   c    d",
+		"\xff\xfe invalid \xc3 UTF-8 \xe2\x82",
+		"\x00\x01\x02 control bytes \x7f",
+		"mixed 日本語\xffand emoji 🦊🦊",
+		"don't 's 'll",
+	} {
+		checkRoundTrip(t, tok, text)
+	}
+}
+
+func TestByteLevelRandomBytes(t *testing.T) {
+	tok := byteLevelTokenizer(t)
+	rng := rand.New(rand.NewSource(1))
+	alphabet := []byte(" \t\nabcdefxyz.,'0123456789")
+	invalid := 0
+	for i := 0; i < 500; i++ {
+		b := make([]byte, rng.Intn(64))
+		for j := range b {
+			if rng.Intn(2) == 0 {
+				b[j] = byte(rng.Intn(256))
+			} else {
+				b[j] = alphabet[rng.Intn(len(alphabet))]
+			}
+		}
+		if !utf8.Valid(b) {
+			invalid++
+		}
+		checkRoundTrip(t, tok, string(b))
+	}
+	if invalid == 0 {
+		t.Error("no invalid UTF-8 among the random inputs")
+	}
+}
+
+func TestByteLevelCache(t *testing.T) {
+	tok := byteLevelTokenizer(t)
+	long := strings.Repeat("fox", maxCachedWord) // longer than any cached word
+	for _, text := range []string{"the quick brown fox", long, "the " + long + " the"} {
+		first := tok.Tokenize(text)
+		for i := 0; i < 3; i++ {
+			if again := tok.Tokenize(text); strings.Join(again, "\x00") != strings.Join(first, "\x00") {
+				t.Errorf("Tokenize(%q) changed from %q to %q on repeat", text, first, again)
+			}
+			checkRoundTrip(t, tok, text)
+		}
+	}
+	// Cached results must not share memory with the caller's slices.
+	tokens := tok.Tokenize("quick")
+	for i := range tokens {
+		tokens[i] = "x"
+	}
+	if got := tok.Detokenize(tok.Tokenize("quick")); got != "quick" {
+		t.Errorf("cache was modified through a returned slice: %q", got)
+	}
+}
+
+func TestSpecialTokensOptIn(t *testing.T) {
+	tok := byteLevelTokenizer(t)
+	eos, ok := tok.TokenID(EndOfTextToken)
+	if !ok {
+		t.Fatalf("%s is not in the vocabulary", EndOfTextToken)
+	}
+	text := "before " + EndOfTextToken + " after"
+	contains := func(ids []int) bool {
+		for _, id := range ids {
+			if id == eos {
+				return true
+			}
+		}
+		return false
+	}
+
+	// By default a special token in the text is plain text.
+	ids, err := tok.Encode(text)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if contains(ids) {
+		t.Errorf("Encode(%q) = %v contains EOS %d", text, ids, eos)
+	}
+	checkRoundTrip(t, tok, text)
+
+	tok.MatchSpecial = true
+	if ids, _ = tok.Encode(text); !contains(ids) {
+		t.Errorf("with MatchSpecial, Encode(%q) = %v lacks EOS %d", text, ids, eos)
+	}
+	checkRoundTrip(t, tok, text)
+}
@@ -0,0 +1,542 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// LoadHFTokenizer reads a Hugging Face tokenizer.json with a BPE model. The
+// normalizer, pre-tokenizer and decoder are applied as configured; added
+// tokens are treated as special tokens. Post-processors (such as adding a
+// BOS token) are not applied, so counts match encoding without special
+// tokens. WordPiece, Unigram and WordLevel models are not supported.
+func LoadHFTokenizer(filename string) (*Tokenizer, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		AddedTokens []struct {
+			ID      int    `json:"id"`
+			Content string `json:"content"`
+		} `json:"added_tokens"`
+		Normalizer   json.RawMessage `json:"normalizer"`
+		PreTokenizer json.RawMessage `json:"pre_tokenizer"`
+		Decoder      json.RawMessage `json:"decoder"`
+		Model        struct {
+			Type                    string          `json:"type"`
+			Vocab                   map[string]int  `json:"vocab"`
+			Merges                  json.RawMessage `json:"merges"`
+			UnkToken                *string         `json:"unk_token"`
+			ContinuingSubwordPrefix *string         `json:"continuing_subword_prefix"`
+			EndOfWordSuffix         *string         `json:"end_of_word_suffix"`
+			FuseUnk                 bool            `json:"fuse_unk"`
+			ByteFallback            bool            `json:"byte_fallback"`
+			IgnoreMerges            bool            `json:"ignore_merges"`
+		} `json:"model"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse tokenizer.json: %v", err)
+	}
+	if file.Model.Type != "" && file.Model.Type != "BPE" {
+		return nil, fmt.Errorf("unsupported tokenizer model %q (only BPE is supported)", file.Model.Type)
+	}
+	merges, err := parseHFMerges(file.Model.Merges)
+	if err != nil {
+		return nil, err
+	}
+
+	t := NewTokenizer(merges, "")
+	t.SpecialTokens = nil
+	t.Vocab = make(map[string]int, len(file.Model.Vocab)+len(file.AddedTokens))
+	for tok, id := range file.Model.Vocab {
+		t.Vocab[tok] = id
+	}
+	for _, a := range file.AddedTokens {
+		t.Vocab[a.Content] = a.ID
+		t.SpecialTokens = append(t.SpecialTokens, a.Content)
+	}
+	t.wholeWords = file.Model.IgnoreMerges
+	if p := file.Model.ContinuingSubwordPrefix; p != nil {
+		t.subwordPrefix = *p
+	}
+
+	if t.normalize, err = hfNormalizer(file.Normalizer); err != nil {
+		return nil, err
+	}
+	pre, err := hfPreTokenizer(file.PreTokenizer)
+	if err != nil {
+		return nil, err
+	}
+	t.preTokenize = func(text string) []string {
+		if pre == nil {
+			return []string{text}
+		}
+		return pre([]string{text})
+	}
+	dec, err := hfDecoder(file.Decoder)
+	if err != nil {
+		return nil, err
+	}
+	t.decode = func(tokens []string) string {
+		if dec != nil {
+			tokens = dec(tokens)
+		}
+		return strings.Join(tokens, "")
+	}
+
+	var unk, suffix string
+	if file.Model.UnkToken != nil {
+		unk = *file.Model.UnkToken
+	}
+	if file.Model.EndOfWordSuffix != nil {
+		suffix = *file.Model.EndOfWordSuffix
+	}
+	t.initial = func(word string) []string {
+		var symbols []string
+		runes := []rune(word)
+		for i, r := range runes {
+			s := string(r)
+			if i > 0 {
+				s = t.subwordPrefix + s
+			}
+			if i == len(runes)-1 {
+				s += suffix
+			}
+			if _, ok := t.Vocab[s]; ok {
+				symbols = append(symbols, s)
+				continue
+			}
+			if file.Model.ByteFallback {
+				if bytes, ok := byteFallback(t.Vocab, string(r)); ok {
+					symbols = append(symbols, bytes...)
+					continue
+				}
+			}
+			if unk == "" {
+				continue
+			}
+			if file.Model.FuseUnk && len(symbols) > 0 && symbols[len(symbols)-1] == unk {
+				continue
+			}
+			symbols = append(symbols, unk)
+		}
+		return symbols
+	}
+	return t, nil
+}
+
+// parseHFMerges accepts both merge formats: "a b" strings and ["a", "b"] pairs.
+func parseHFMerges(raw json.RawMessage) ([]Pair, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var lines []string
+	if err := json.Unmarshal(raw, &lines); err == nil {
+		merges := make([]Pair, 0, len(lines))
+		for _, l := range lines {
+			parts := strings.Split(l, " ")
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("invalid merge %q", l)
+			}
+			merges = append(merges, Pair{parts[0], parts[1]})
+		}
+		return merges, nil
+	}
+	var pairs [][2]string
+	if err := json.Unmarshal(raw, &pairs); err != nil {
+		return nil, fmt.Errorf("failed to parse merges: %v", err)
+	}
+	merges := make([]Pair, len(pairs))
+	for i, p := range pairs {
+		merges[i] = Pair{p[0], p[1]}
+	}
+	return merges, nil
+}
+
+// byteFallback returns the <0xXX> tokens of the bytes of s, if all exist.
+func byteFallback(vocab map[string]int, s string) ([]string, bool) {
+	tokens := make([]string, len(s))
+	for i := 0; i < len(s); i++ {
+		tokens[i] = fmt.Sprintf("<0x%02X>", s[i])
+		if _, ok := vocab[tokens[i]]; !ok {
+			return nil, false
+		}
+	}
+	return tokens, true
+}
+
+// hfComponent is the part of every normalizer, pre-tokenizer and decoder
+// object that is shared: its type and the nested components of a Sequence.
+type hfComponent struct {
+	Type           string            `json:"type"`
+	Normalizers    []json.RawMessage `json:"normalizers"`
+	PreTokenizers  []json.RawMessage `json:"pretokenizers"`
+	Decoders       []json.RawMessage `json:"decoders"`
+	Pattern        *hfPattern        `json:"pattern"`
+	Content        json.RawMessage   `json:"content"`
+	Prepend        string            `json:"prepend"`
+	Left           bool              `json:"left"`
+	Right          bool              `json:"right"`
+	Start          int               `json:"start"`
+	Stop           int               `json:"stop"`
+	Behavior       string            `json:"behavior"`
+	Invert         bool              `json:"invert"`
+	AddPrefixSpace *bool             `json:"add_prefix_space"`
+	UseRegex       *bool             `json:"use_regex"`
+	Replacement    string            `json:"replacement"`
+	PrependScheme  string            `json:"prepend_scheme"`
+	Split          *bool             `json:"split"`
+	Individual     bool              `json:"individual_digits"`
+	Prefix         string            `json:"prefix"`
+	Suffix         string            `json:"suffix"`
+}
+
+// hfPattern is a literal string or a regular expression.
+type hfPattern struct {
+	String *string `json:"String"`
+	Regex  *string `json:"Regex"`
+}
+
+func (p *hfPattern) compile() (*regexp.Regexp, error) {
+	switch {
+	case p == nil:
+		return nil, fmt.Errorf("missing pattern")
+	case p.String != nil:
+		return regexp.Compile(regexp.QuoteMeta(*p.String))
+	case p.Regex != nil:
+		return compilePattern(*p.Regex)
+	default:
+		return nil, fmt.Errorf("empty pattern")
+	}
+}
+
+func parseHFComponent(raw json.RawMessage) (*hfComponent, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var c hfComponent
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// hfNormalizer builds a normalizer; nil means none.
+func hfNormalizer(raw json.RawMessage) (func(string) string, error) {
+	c, err := parseHFComponent(raw)
+	if err != nil || c == nil {
+		return nil, err
+	}
+	switch c.Type {
+	case "Sequence":
+		var steps []func(string) string
+		for _, r := range c.Normalizers {
+			n, err := hfNormalizer(r)
+			if err != nil {
+				return nil, err
+			}
+			if n != nil {
+				steps = append(steps, n)
+			}
+		}
+		return func(s string) string {
+			for _, n := range steps {
+				s = n(s)
+			}
+			return s
+		}, nil
+	case "NFC":
+		return norm.NFC.String, nil
+	case "NFD":
+		return norm.NFD.String, nil
+	case "NFKC":
+		return norm.NFKC.String, nil
+	case "NFKD":
+		return norm.NFKD.String, nil
+	case "Lowercase":
+		return strings.ToLower, nil
+	case "StripAccents":
+		return func(s string) string {
+			return strings.Map(func(r rune) rune {
+				if unicode.Is(unicode.Mn, r) {
+					return -1
+				}
+				return r
+			}, s)
+		}, nil
+	case "Strip":
+		return func(s string) string {
+			if c.Left {
+				s = strings.TrimLeftFunc(s, unicode.IsSpace)
+			}
+			if c.Right {
+				s = strings.TrimRightFunc(s, unicode.IsSpace)
+			}
+			return s
+		}, nil
+	case "Prepend":
+		return func(s string) string {
+			if s == "" {
+				return s
+			}
+			return c.Prepend + s
+		}, nil
+	case "Replace":
+		re, err := c.Pattern.compile()
+		if err != nil {
+			return nil, err
+		}
+		var content string
+		json.Unmarshal(c.Content, &content)
+		return func(s string) string { return re.ReplaceAllLiteralString(s, content) }, nil
+	default:
+		return nil, fmt.Errorf("unsupported normalizer %q", c.Type)
+	}
+}
+
+// hfPreTokenizer builds a pre-tokenizer that refines a list of pieces; nil means none.
+func hfPreTokenizer(raw json.RawMessage) (func([]string) []string, error) {
+	c, err := parseHFComponent(raw)
+	if err != nil || c == nil {
+		return nil, err
+	}
+	each := func(f func(string) []string) func([]string) []string {
+		return func(pieces []string) []string {
+			var out []string
+			for _, p := range pieces {
+				out = append(out, f(p)...)
+			}
+			return out
+		}
+	}
+	switch c.Type {
+	case "Sequence":
+		var steps []func([]string) []string
+		for _, r := range c.PreTokenizers {
+			p, err := hfPreTokenizer(r)
+			if err != nil {
+				return nil, err
+			}
+			if p != nil {
+				steps = append(steps, p)
+			}
+		}
+		return func(pieces []string) []string {
+			for _, p := range steps {
+				pieces = p(pieces)
+			}
+			return pieces
+		}, nil
+	case "ByteLevel":
+		addPrefix := c.AddPrefixSpace != nil && *c.AddPrefixSpace
+		useRegex := c.UseRegex == nil || *c.UseRegex
+		return func(pieces []string) []string {
+			var out []string
+			for _, p := range pieces {
+				if addPrefix && !strings.HasPrefix(p, " ") {
+					p = " " + p
+				}
+				split := []string{p}
+				if useRegex {
+					split = PreTokenize(p)
+				}
+				for _, s := range split {
+					out = append(out, strings.Join(byteSymbols(s), ""))
+				}
+			}
+			return out
+		}, nil
+	case "Split":
+		re, err := c.Pattern.compile()
+		if err != nil {
+			return nil, err
+		}
+		return each(func(s string) []string { return splitPieces(re, s, c.Behavior, c.Invert) }), nil
+	case "Whitespace":
+		re := regexp.MustCompile(`\w+|[^\w\s]+`)
+		return each(func(s string) []string { return splitPieces(re, s, "Removed", true) }), nil
+	case "WhitespaceSplit":
+		return each(strings.Fields), nil
+	case "Punctuation":
+		re := regexp.MustCompile(`[\p{P}\p{S}]`)
+		behavior := c.Behavior
+		if behavior == "" {
+			behavior = "Isolated"
+		}
+		return each(func(s string) []string { return splitPieces(re, s, behavior, false) }), nil
+	case "Digits":
+		re := regexp.MustCompile(`\p{Nd}+`)
+		if c.Individual {
+			re = regexp.MustCompile(`\p{Nd}`)
+		}
+		return each(func(s string) []string { return splitPieces(re, s, "Isolated", false) }), nil
+	case "Metaspace":
+		replacement := c.Replacement
+		if replacement == "" {
+			replacement = "▁"
+		}
+		scheme := c.PrependScheme
+		if scheme == "" {
+			scheme = "always"
+			if c.AddPrefixSpace != nil && !*c.AddPrefixSpace {
+				scheme = "never"
+			}
+		}
+		split := c.Split == nil || *c.Split
+		re := regexp.MustCompile(regexp.QuoteMeta(replacement))
+		return func(pieces []string) []string {
+			var out []string
+			for i, p := range pieces {
+				p = strings.ReplaceAll(p, " ", replacement)
+				if (scheme == "always" || scheme == "first" && i == 0) && !strings.HasPrefix(p, replacement) {
+					p = replacement + p
+				}
+				if split {
+					out = append(out, splitPieces(re, p, "MergedWithNext", false)...)
+				} else {
+					out = append(out, p)
+				}
+			}
+			return out
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported pre-tokenizer %q", c.Type)
+	}
+}
+
+// hfDecoder builds a decoder that rewrites the token list before it is
+// joined; nil means plain concatenation.
+func hfDecoder(raw json.RawMessage) (func([]string) []string, error) {
+	c, err := parseHFComponent(raw)
+	if err != nil || c == nil {
+		return nil, err
+	}
+	each := func(f func(i int, tok string) string) func([]string) []string {
+		return func(tokens []string) []string {
+			out := make([]string, len(tokens))
+			for i, tok := range tokens {
+				out[i] = f(i, tok)
+			}
+			return out
+		}
+	}
+	switch c.Type {
+	case "Sequence":
+		var steps []func([]string) []string
+		for _, r := range c.Decoders {
+			d, err := hfDecoder(r)
+			if err != nil {
+				return nil, err
+			}
+			if d != nil {
+				steps = append(steps, d)
+			}
+		}
+		return func(tokens []string) []string {
+			for _, d := range steps {
+				tokens = d(tokens)
+			}
+			return tokens
+		}, nil
+	case "ByteLevel":
+		return func(tokens []string) []string {
+			return []string{decodeBytes(strings.Join(tokens, ""))}
+		}, nil
+	case "Metaspace":
+		replacement := c.Replacement
+		if replacement == "" {
+			replacement = "▁"
+		}
+		strip := c.PrependScheme != "never" && (c.AddPrefixSpace == nil || *c.AddPrefixSpace)
+		return each(func(i int, tok string) string {
+			tok = strings.ReplaceAll(tok, replacement, " ")
+			if i == 0 && strip {
+				tok = strings.TrimPrefix(tok, " ")
+			}
+			return tok
+		}), nil
+	case "ByteFallback":
+		return func(tokens []string) []string {
+			var out []string
+			var pending []byte
+			flush := func() {
+				if len(pending) == 0 {
+					return
+				}
+				if utf8.Valid(pending) {
+					out = append(out, string(pending))
+				} else {
+					out = append(out, strings.Repeat("�", len(pending)))
+				}
+				pending = nil
+			}
+			for _, tok := range tokens {
+				if len(tok) == 6 && strings.HasPrefix(tok, "<0x") && strings.HasSuffix(tok, ">") {
+					if b, err := strconv.ParseUint(tok[3:5], 16, 8); err == nil {
+						pending = append(pending, byte(b))
+						continue
+					}
+				}
+				flush()
+				out = append(out, tok)
+			}
+			flush()
+			return out
+		}, nil
+	case "Fuse":
+		return func(tokens []string) []string { return []string{strings.Join(tokens, "")} }, nil
+	case "Strip":
+		var content string
+		json.Unmarshal(c.Content, &content)
+		return each(func(_ int, tok string) string {
+			for n := 0; n < c.Start && strings.HasPrefix(tok, content); n++ {
+				tok = strings.TrimPrefix(tok, content)
+			}
+			for n := 0; n < c.Stop && strings.HasSuffix(tok, content); n++ {
+				tok = strings.TrimSuffix(tok, content)
+			}
+			return tok
+		}), nil
+	case "Replace":
+		re, err := c.Pattern.compile()
+		if err != nil {
+			return nil, err
+		}
+		var content string
+		json.Unmarshal(c.Content, &content)
+		return each(func(_ int, tok string) string { return re.ReplaceAllLiteralString(tok, content) }), nil
+	case "BPEDecoder":
+		suffix := c.Suffix
+		if suffix == "" {
+			suffix = "</w>"
+		}
+		return each(func(i int, tok string) string {
+			return strings.ReplaceAll(tok, suffix, " ")
+		}), nil
+	case "WordPiece":
+		prefix := c.Prefix
+		if prefix == "" {
+			prefix = "##"
+		}
+		return each(func(i int, tok string) string {
+			if strings.HasPrefix(tok, prefix) {
+				return strings.TrimPrefix(tok, prefix)
+			}
+			if i > 0 {
+				return " " + tok
+			}
+			return tok
+		}), nil
+	default:
+		return nil, fmt.Errorf("unsupported decoder %q", c.Type)
+	}
+}
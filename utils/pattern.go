@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// wsRunGroup names the group that stands in for \s+(?!\S).
+const wsRunGroup = "goetlwsrun"
+
+// compilePattern compiles a pre-tokenization pattern written for Python or
+// Rust regex engines. Possessive quantifiers become greedy ones, which
+// match the same pieces for these patterns, and the lookahead \s+(?!\S) is
+// emulated by matchSpans.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	p := stripPossessive(pattern)
+	p = strings.ReplaceAll(p, `\s+(?!\S)`, `(?P<`+wsRunGroup+`>\s+)`)
+	return regexp.Compile(p)
+}
+
+// stripPossessive drops the '+' that makes a quantifier possessive, outside
+// of escapes and character classes.
+func stripPossessive(p string) string {
+	var b strings.Builder
+	inClass, quantified := false, false
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case c == '\\' && i+1 < len(p):
+			// Copy escapes whole, including \p{...} classes, so that their
+			// braces are not taken for a repetition.
+			end := i + 2
+			if (p[i+1] == 'p' || p[i+1] == 'P') && end < len(p) && p[end] == '{' {
+				if j := strings.IndexByte(p[end:], '}'); j >= 0 {
+					end += j + 1
+				}
+			}
+			b.WriteString(p[i:end])
+			i = end - 1
+			quantified = false
+			continue
+		case inClass:
+			inClass = c != ']'
+		case c == '[':
+			inClass = true
+		case c == '+' && quantified:
+			quantified = false
+			continue
+		}
+		quantified = !inClass && (c == '*' || c == '+' || c == '}' || c == '?' && i > 0 && p[i-1] != '(')
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// matchSpans returns the [start, end) byte spans of the successive matches
+// of re in text. Where the \s+(?!\S) group matched a run of whitespace that
+// is followed by a non-space, the last whitespace character is left for the
+// next match, as the lookahead would.
+func matchSpans(re *regexp.Regexp, text string) [][2]int {
+	group := re.SubexpIndex(wsRunGroup)
+	var spans [][2]int
+	for i := 0; i < len(text); {
+		m := re.FindStringSubmatchIndex(text[i:])
+		if m == nil {
+			break
+		}
+		start, end := i+m[0], i+m[1]
+		if end == start {
+			_, size := utf8.DecodeRuneInString(text[start:])
+			i = start + size
+			continue
+		}
+		if group > 0 && m[2*group] >= 0 && end < len(text) {
+			if r, _ := utf8.DecodeRuneInString(text[end:]); !unicode.IsSpace(r) {
+				if _, size := utf8.DecodeLastRuneInString(text[start:end]); end-size > start {
+					end -= size
+				}
+			}
+		}
+		spans = append(spans, [2]int{start, end})
+		i = end
+	}
+	return spans
+}
+
+// splitPieces splits text at the matches of re. behavior is one of the
+// Hugging Face split behaviors: Isolated keeps matches as pieces of their
+// own, Removed drops them, MergedWithPrevious and MergedWithNext attach
+// them to the neighbouring piece and Contiguous joins adjacent matches.
+// With invert the text between matches is treated as the matches.
+func splitPieces(re *regexp.Regexp, text, behavior string, invert bool) []string {
+	type segment struct {
+		text  string
+		match bool
+	}
+	var segs []segment
+	last := 0
+	for _, sp := range matchSpans(re, text) {
+		if sp[0] > last {
+			segs = append(segs, segment{text[last:sp[0]], invert})
+		}
+		segs = append(segs, segment{text[sp[0]:sp[1]], !invert})
+		last = sp[1]
+	}
+	if last < len(text) {
+		segs = append(segs, segment{text[last:], invert})
+	}
+
+	var pieces []string
+	pending := ""
+	prevMatch := false
+	for i, s := range segs {
+		switch behavior {
+		case "Removed":
+			if !s.match {
+				pieces = append(pieces, s.text)
+			}
+		case "MergedWithPrevious":
+			if s.match && len(pieces) > 0 {
+				pieces[len(pieces)-1] += s.text
+			} else {
+				pieces = append(pieces, s.text)
+			}
+		case "MergedWithNext":
+			if s.match {
+				pending += s.text
+			} else {
+				pieces = append(pieces, pending+s.text)
+				pending = ""
+			}
+		case "Contiguous":
+			if s.match && prevMatch && i > 0 {
+				pieces[len(pieces)-1] += s.text
+			} else {
+				pieces = append(pieces, s.text)
+			}
+		default: // Isolated
+			pieces = append(pieces, s.text)
+		}
+		prevMatch = s.match
+	}
+	if pending != "" {
+		pieces = append(pieces, pending)
+	}
+	return pieces
+}
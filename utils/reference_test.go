@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// The fixtures in testdata are a small byte-level BPE vocabulary (256 bytes
+// and 150 merges) as a tiktoken rank file and as a Hugging Face
+// tokenizer.json whose merges are in rank order, with <|endoftext|> as
+// 50256. reference.json holds the IDs the tiktoken-go reference
+// implementation (v0.1.7) produces for them with the patterns and special
+// tokens of r50k_base and cl100k_base, with special tokens encoded as text
+// and allowed.
+
+type referenceCase struct {
+	Text       string           `json:"text"`
+	IDs        map[string][]int `json:"ids"`
+	Counts     map[string]int   `json:"counts"`
+	SpecialIDs map[string][]int `json:"special_ids"`
+}
+
+func loadReference(t *testing.T) []referenceCase {
+	t.Helper()
+	data, err := os.ReadFile("testdata/reference.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cases []referenceCase
+	if err := json.Unmarshal(data, &cases); err != nil {
+		t.Fatal(err)
+	}
+	return cases
+}
+
+// checkReference compares tok with the expected values of encoding.
+func checkReference(t *testing.T, tok *Tokenizer, encoding string, cases []referenceCase) {
+	t.Helper()
+	for _, c := range cases {
+		tok.MatchSpecial = false
+		ids, err := tok.Encode(c.Text)
+		if err != nil {
+			t.Fatalf("Encode(%q): %v", c.Text, err)
+		}
+		if !reflect.DeepEqual(ids, c.IDs[encoding]) {
+			t.Errorf("%s: Encode(%q) = %v, want %v", encoding, c.Text, ids, c.IDs[encoding])
+		}
+		if n := tok.CountTokens(c.Text); n != c.Counts[encoding] {
+			t.Errorf("%s: CountTokens(%q) = %d, want %d", encoding, c.Text, n, c.Counts[encoding])
+		}
+		if got, err := tok.Decode(ids); err != nil || got != c.Text {
+			t.Errorf("%s: Decode(Encode(%q)) = %q, %v", encoding, c.Text, got, err)
+		}
+
+		tok.MatchSpecial = true
+		if ids, _ = tok.Encode(c.Text); !reflect.DeepEqual(ids, c.SpecialIDs[encoding]) {
+			t.Errorf("%s with special tokens: Encode(%q) = %v, want %v", encoding, c.Text, ids, c.SpecialIDs[encoding])
+		}
+	}
+}
+
+func TestTiktokenReference(t *testing.T) {
+	cases := loadReference(t)
+	ranks, err := os.ReadFile("testdata/small.tiktoken")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The file name selects the pattern and special tokens.
+	for _, encoding := range []string{"r50k_base", "cl100k_base"} {
+		t.Run(encoding, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), encoding+".tiktoken")
+			if err := os.WriteFile(path, ranks, 0644); err != nil {
+				t.Fatal(err)
+			}
+			tok, err := LoadTiktoken(path)
+			if err != nil {
+				t.Fatalf("LoadTiktoken: %v", err)
+			}
+			checkReference(t, tok, encoding, cases)
+		})
+	}
+}
+
+func TestHFTokenizerReference(t *testing.T) {
+	tok, err := LoadHFTokenizer("testdata/tokenizer.json")
+	if err != nil {
+		t.Fatalf("LoadHFTokenizer: %v", err)
+	}
+	// A ByteLevel pre-tokenizer splits with the GPT-2 pattern and merges
+	// are in rank order, so the IDs are those of r50k_base.
+	checkReference(t, tok, "r50k_base", loadReference(t))
+}
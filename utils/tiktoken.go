@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// tiktokenEncoding is the pre-tokenization pattern and the special tokens
+// of a tiktoken encoding; .tiktoken files only hold the ranks.
+type tiktokenEncoding struct {
+	pattern string
+	special map[string]int
+}
+
+const gpt2Pattern = `'s|'t|'re|'ve|'m|'ll|'d| ?\p{L}+| ?\p{N}+| ?[^\s\p{L}\p{N}]+|\s+(?!\S)|\s+`
+
+// tiktokenEncodings are the encodings shipped with tiktoken, by file name.
+var tiktokenEncodings = map[string]tiktokenEncoding{
+	"r50k_base": {gpt2Pattern, map[string]int{EndOfTextToken: 50256}},
+	"p50k_base": {gpt2Pattern, map[string]int{EndOfTextToken: 50256}},
+	"cl100k_base": {
+		`'(?i:[sdmt]|ll|ve|re)|[^\r\n\p{L}\p{N}]?+\p{L}++|\p{N}{1,3}+| ?[^\s\p{L}\p{N}]++[\r\n]*+|\s++$|\s*[\r\n]|\s+(?!\S)|\s`,
+		map[string]int{EndOfTextToken: 100257, "<|fim_prefix|>": 100258, "<|fim_middle|>": 100259, "<|fim_suffix|>": 100260, "<|endofprompt|>": 100276},
+	},
+	"o200k_base": {
+		strings.Join([]string{
+			`[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?`,
+			`[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?`,
+			`\p{N}{1,3}`,
+			` ?[^\s\p{L}\p{N}]+[\r\n/]*`,
+			`\s*[\r\n]+`,
+			`\s+(?!\S)`,
+			`\s+`,
+		}, "|"),
+		map[string]int{EndOfTextToken: 199999, "<|endofprompt|>": 200018},
+	},
+}
+
+// LoadTiktoken reads a tiktoken rank file (one base64 token and its rank
+// per line). The pattern and special tokens are chosen from the file name
+// (r50k_base, p50k_base, cl100k_base or o200k_base); other names get those
+// of cl100k_base. Tokens are raw byte strings, so Detokenize(Tokenize(x))
+// == x for any input.
+func LoadTiktoken(filename string) (*Tokenizer, error) {
+	name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	enc, ok := tiktokenEncodings[name]
+	if !ok {
+		enc = tiktokenEncodings["cl100k_base"]
+	}
+	re, err := compilePattern(enc.pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile pattern: %v", err)
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	t := NewTokenizer(nil, "")
+	t.Vocab = make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid tiktoken line %q", scanner.Text())
+		}
+		tok, err := base64.StdEncoding.DecodeString(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid tiktoken token %q: %v", fields[0], err)
+		}
+		rank, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid tiktoken rank %q: %v", fields[1], err)
+		}
+		t.Vocab[string(tok)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	t.SpecialTokens = nil
+	for tok, id := range enc.special {
+		t.Vocab[tok] = id
+		t.SpecialTokens = append(t.SpecialTokens, tok)
+	}
+	t.mergeByRank = true
+	t.wholeWords = true
+	t.preTokenize = func(text string) []string { return splitPieces(re, text, "Isolated", false) }
+	t.initial = func(word string) []string {
+		symbols := make([]string, len(word))
+		for i := 0; i < len(word); i++ {
+			symbols[i] = word[i : i+1]
+		}
+		return symbols
+	}
+	t.decode = func(tokens []string) string { return strings.Join(tokens, "") }
+	return t, nil
+}
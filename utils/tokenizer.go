@@ -2,10 +2,11 @@ package utils
 
 import (
 	"bufio"
-	"errors"
 	"fmt"
+	"math"
 	"os"
 	"strings"
+	"sync"
 )
 
 // Pair represents a pair of tokens.
@@ -19,8 +20,51 @@ type Tokenizer struct {
 	Merges         []Pair
 	WordBoundary   string
 	MergeDict      map[Pair]struct{}
+	// ByteLevel selects GPT-2 style byte-level BPE: text is pre-tokenized
+	// with PreTokenize instead of split at whitespace, and merges work on
+	// bytes rather than characters. WordBoundary is not used.
+	ByteLevel bool
+	// SpecialTokens are reserved in the vocabulary and decode as
+	// themselves. They are only recognized in input text if MatchSpecial
+	// is set; otherwise a document that happens to contain
+	// "<|endoftext|>" is encoded like any other text rather than as a
+	// real end of text. Callers that need a special token, such as an EOS
+	// between documents, append its ID (see TokenID).
+	SpecialTokens []string
+	MatchSpecial  bool
+	// Vocab maps tokens to IDs. If nil it is built by BuildVocab on first use.
+	Vocab map[string]int
+	// Scores holds the log probabilities of the pieces of a Unigram
+	// tokenizer (see TrainUnigram). If set, words are segmented by Viterbi
+	// search over the pieces instead of by applying Merges.
+	Scores map[string]float64
+
+	ranks   map[Pair]int
+	unigram *unigramModel
+	mu      sync.Mutex
+	cache   map[string][]string
+	// idTokens is the inverse of Vocab.
+	idTokens []string
+
+	// The loaders of other formats (hf.go, tiktoken.go) replace the native
+	// normalization, pre-tokenization, initial symbols and decoding.
+	normalize   func(string) string
+	preTokenize func(string) []string
+	initial     func(string) []string
+	decode      func([]string) string
+	// mergeByRank ranks a pair by the vocabulary ID of the merged token, as
+	// tiktoken does, instead of by the position of the pair in Merges.
+	mergeByRank bool
+	// wholeWords returns a word that is in the vocabulary as one token.
+	wholeWords bool
+	// subwordPrefix marks symbols that continue a word; it is dropped from
+	// the second symbol of a merge.
+	subwordPrefix string
 }
 
+// maxCachedWord bounds the length of words kept in the encoding cache.
+const maxCachedWord = 256
+
 // NewTokenizer creates a new Tokenizer with optional merges and word boundary marker.
 func NewTokenizer(merges []Pair, wordBoundary string) *Tokenizer {
 	mergeDict := make(map[Pair]struct{})
@@ -28,9 +72,10 @@ func NewTokenizer(merges []Pair, wordBoundary string) *Tokenizer {
 		mergeDict[m] = struct{}{}
 	}
 	return &Tokenizer{
-		Merges:       merges,
-		WordBoundary: wordBoundary,
-		MergeDict:    mergeDict,
+		Merges:        merges,
+		WordBoundary:  wordBoundary,
+		MergeDict:     mergeDict,
+		SpecialTokens: DefaultSpecialTokens(),
 	}
 }
 
@@ -62,72 +107,215 @@ func (t *Tokenizer) GetPairFrequencies(tokens [][]string) map[Pair]int {
 func (t *Tokenizer) MergePair(tokens [][]string, pairToMerge Pair) [][]string {
 	merged := [][]string{}
 	for _, word := range tokens {
-		newWord := []string{}
-		i := 0
-		for i < len(word) {
-			if i < len(word)-1 && word[i] == pairToMerge.First && word[i+1] == pairToMerge.Second {
-				newWord = append(newWord, word[i]+word[i+1])
-				i += 2
-			} else {
-				newWord = append(newWord, word[i])
-				i++
-			}
+		merged = append(merged, mergeSymbols(word, pairToMerge))
+	}
+	return merged
+}
+
+// mergeSymbols replaces every occurrence of pair in word, scanning left to
+// right, with the concatenation of its two symbols.
+func mergeSymbols(word []string, pair Pair) []string {
+	merged := make([]string, 0, len(word))
+	for i := 0; i < len(word); i++ {
+		if i < len(word)-1 && word[i] == pair.First && word[i+1] == pair.Second {
+			merged = append(merged, pair.First+pair.Second)
+			i++
+		} else {
+			merged = append(merged, word[i])
 		}
-		merged = append(merged, newWord)
 	}
 	return merged
 }
 
-// TrainBPE trains a BPE tokenizer on the given corpus.
-func TrainBPE(corpus string, numMerges int, wordBoundary string) ([]Pair, error) {
-	if corpus == "" || numMerges <= 0 {
-		return nil, errors.New("invalid corpus or numMerges")
+// ApplyBPE encodes text using the learned merges.
+func (t *Tokenizer) ApplyBPE(text string) []string {
+	encoded := []string{}
+	for _, seg := range t.splitSpecial(text) {
+		if seg.special {
+			encoded = append(encoded, seg.text)
+			continue
+		}
+		for _, word := range t.words(seg.text) {
+			encoded = append(encoded, strings.Join(t.encodeWord(word), " "))
+		}
 	}
-	t := NewTokenizer(nil, wordBoundary)
-	tokens := t.InitialTokens(corpus)
-	merges := []Pair{}
-	for i := 0; i < numMerges; i++ {
-		pairFreqs := t.GetPairFrequencies(tokens)
-		if len(pairFreqs) == 0 {
-			break
+	return encoded
+}
+
+// Tokenize returns the tokens of text as one flat sequence. With
+// MatchSpecial, special tokens are kept whole.
+func (t *Tokenizer) Tokenize(text string) []string {
+	var tokens []string
+	for _, seg := range t.splitSpecial(text) {
+		if seg.special {
+			tokens = append(tokens, seg.text)
+			continue
 		}
-		var bestPair Pair
-		maxFreq := -1
-		for pair, freq := range pairFreqs {
-			if freq > maxFreq {
-				bestPair = pair
-				maxFreq = freq
-			}
+		for _, word := range t.words(seg.text) {
+			tokens = append(tokens, t.encodeWord(word)...)
 		}
-		tokens = t.MergePair(tokens, bestPair)
-		merges = append(merges, bestPair)
 	}
-	return merges, nil
+	return tokens
 }
 
-// ApplyBPE encodes text using the learned merges.
-func (t *Tokenizer) ApplyBPE(text string) []string {
-	tokens := t.InitialTokens(text)
-	for _, pair := range t.Merges {
-		tokens = t.MergePair(tokens, pair)
+// Detokenize joins tokens back into text. For a byte-level tokenizer this
+// is the exact inverse of Tokenize. Otherwise word boundary markers (or
+// MetaSpace, for a Unigram tokenizer) become spaces, so whitespace is
+// normalized and words whose boundary marker was not merged into a token
+// run together.
+func (t *Tokenizer) Detokenize(tokens []string) string {
+	var b strings.Builder
+	var run []string
+	for _, tok := range tokens {
+		if t.isSpecial(tok) {
+			b.WriteString(t.detokenizeRun(run, b.Len() == 0))
+			b.WriteString(tok)
+			run = nil
+			continue
+		}
+		run = append(run, tok)
 	}
-	encoded := []string{}
-	for _, word := range tokens {
-		cleaned := []string{}
-		for _, token := range word {
-			if token != t.WordBoundary {
-				cleaned = append(cleaned, token)
+	b.WriteString(t.detokenizeRun(run, b.Len() == 0))
+	return b.String()
+}
+
+// detokenizeRun joins tokens that contain no special token; first tells
+// whether they start the text.
+func (t *Tokenizer) detokenizeRun(tokens []string, first bool) string {
+	if t.decode != nil {
+		return t.decode(tokens)
+	}
+	joined := strings.Join(tokens, "")
+	if t.ByteLevel {
+		return decodeBytes(joined)
+	}
+	if t.Scores != nil {
+		text := strings.ReplaceAll(joined, MetaSpace, " ")
+		if first {
+			text = strings.TrimPrefix(text, " ")
+		}
+		return text
+	}
+	if t.WordBoundary == "" {
+		return joined
+	}
+	return strings.TrimSpace(strings.ReplaceAll(joined, t.WordBoundary, " "))
+}
+
+// CountTokens returns the number of tokens Tokenize produces for text.
+func (t *Tokenizer) CountTokens(text string) int {
+	n := 0
+	for _, seg := range t.splitSpecial(text) {
+		if seg.special {
+			n++
+			continue
+		}
+		for _, word := range t.words(seg.text) {
+			n += len(t.encodeWord(word))
+		}
+	}
+	return n
+}
+
+// words normalizes text and splits it into the units merges are applied within.
+func (t *Tokenizer) words(text string) []string {
+	if t.normalize != nil {
+		text = t.normalize(text)
+	}
+	if t.preTokenize != nil {
+		return t.preTokenize(text)
+	}
+	if t.Scores != nil {
+		return unigramWords(text)
+	}
+	if t.ByteLevel {
+		return PreTokenize(text)
+	}
+	return strings.Fields(text)
+}
+
+// encodeWord splits a word into characters plus the word boundary marker (or
+// into bytes, for a byte-level tokenizer) and repeatedly merges the leftmost
+// adjacent pair with the lowest merge rank. A boundary marker left on its
+// own is dropped. Results are cached per word.
+func (t *Tokenizer) encodeWord(word string) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ranks == nil {
+		t.ranks = make(map[Pair]int, len(t.Merges))
+		for i, m := range t.Merges {
+			if _, ok := t.ranks[m]; !ok {
+				t.ranks[m] = i
 			}
 		}
-		encoded = append(encoded, strings.Join(cleaned, " "))
+		t.cache = make(map[string][]string)
+		if t.Scores != nil {
+			t.unigram = newUnigramModel(t.Scores)
+		}
 	}
-	return encoded
+	if cached, ok := t.cache[word]; ok {
+		return cached
+	}
+	if t.unigram != nil {
+		symbols, _ := t.unigram.segment(word, "")
+		if len(word) <= maxCachedWord {
+			t.cache[word] = symbols
+		}
+		return symbols
+	}
+	if _, ok := t.Vocab[word]; ok && t.wholeWords {
+		return []string{word}
+	}
+
+	var symbols []string
+	switch {
+	case t.initial != nil:
+		symbols = t.initial(word)
+	case t.ByteLevel:
+		symbols = byteSymbols(word)
+	default:
+		symbols = append(strings.Split(word, ""), t.WordBoundary)
+	}
+	for len(symbols) > 1 {
+		best, bestRank := -1, math.MaxInt
+		for i := 0; i < len(symbols)-1; i++ {
+			if r, ok := t.pairRank(symbols[i], symbols[i+1]); ok && r < bestRank {
+				best, bestRank = i, r
+			}
+		}
+		if best < 0 {
+			break
+		}
+		merged := symbols[best] + strings.TrimPrefix(symbols[best+1], t.subwordPrefix)
+		symbols = append(append(symbols[:best:best], merged), symbols[best+2:]...)
+	}
+	if t.initial == nil && !t.ByteLevel && symbols[len(symbols)-1] == t.WordBoundary {
+		symbols = symbols[:len(symbols)-1]
+	}
+	if len(word) <= maxCachedWord {
+		t.cache[word] = symbols
+	}
+	return symbols
+}
+
+// pairRank returns the merge priority of two adjacent symbols; lower merges first.
+func (t *Tokenizer) pairRank(a, b string) (int, bool) {
+	if t.mergeByRank {
+		r, ok := t.Vocab[a+strings.TrimPrefix(b, t.subwordPrefix)]
+		return r, ok
+	}
+	r, ok := t.ranks[Pair{a, b}]
+	return r, ok
 }
 
 // DecodeBPE reconstructs original words from BPE-encoded tokens.
 func (t *Tokenizer) DecodeBPE(encoded []string) []string {
 	decoded := []string{}
 	for _, word := range encoded {
+		if t.ByteLevel {
+			decoded = append(decoded, decodeBytes(word))
+			continue
+		}
 		decoded = append(decoded, strings.ReplaceAll(strings.ReplaceAll(word, " ", ""), t.WordBoundary, ""))
 	}
 	return decoded
@@ -164,32 +352,3 @@ func LoadMerges(filename string) ([]Pair, error) {
 	}
 	return merges, scanner.Err()
 }
-
-// Example usage and test
-func main() {
-	corpus := "low lower lowest low lower lowest"
-	merges, err := TrainBPE(corpus, 10, "</w>")
-	if err != nil {
-		fmt.Println("Error:", err)
-		return
-	}
-	tokenizer := NewTokenizer(merges, "</w>")
-
-	fmt.Println("Learned merges:")
-	for i, m := range merges {
-		fmt.Printf("%d: (%s %s)\n", i+1, m.First, m.Second)
-	}
-
-	text := "lowest low"
-	encoded := tokenizer.ApplyBPE(text)
-	fmt.Println("\nEncoded output:")
-	for _, word := range encoded {
-		fmt.Println(word)
-	}
-
-	decoded := tokenizer.DecodeBPE(encoded)
-	fmt.Println("\nDecoded output:")
-	for _, word := range decoded {
-		fmt.Println(word)
-	}
-}
@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"container/heap"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// TrainBPE trains a BPE tokenizer on the given corpus.
+func TrainBPE(corpus string, numMerges int, wordBoundary string) ([]Pair, error) {
+	if corpus == "" || numMerges <= 0 {
+		return nil, errors.New("invalid corpus or numMerges")
+	}
+	return TrainBPEFromCounts(CountWords(corpus, false), numMerges, wordBoundary), nil
+}
+
+// TrainByteLevelBPE trains merges for a byte-level tokenizer (see
+// NewByteLevelTokenizer) on the given corpus.
+func TrainByteLevelBPE(corpus string, numMerges int) ([]Pair, error) {
+	if corpus == "" || numMerges <= 0 {
+		return nil, errors.New("invalid corpus or numMerges")
+	}
+	return TrainByteLevelBPEFromCounts(CountWords(corpus, true), numMerges), nil
+}
+
+// CountWords counts the words of text: whitespace-separated words, or the
+// pieces of PreTokenize when byteLevel is set. Counts of several texts can
+// be added up before training, so a corpus need not be held in memory.
+func CountWords(text string, byteLevel bool) map[string]int {
+	counts := make(map[string]int)
+	words := strings.Fields(text)
+	if byteLevel {
+		words = PreTokenize(text)
+	}
+	for _, w := range words {
+		counts[w]++
+	}
+	return counts
+}
+
+// TrainBPEFromCounts trains merges on a word frequency table as returned by
+// CountWords.
+func TrainBPEFromCounts(counts map[string]int, numMerges int, wordBoundary string) []Pair {
+	return trainMerges(counts, func(w string) []string {
+		return append(strings.Split(w, ""), wordBoundary)
+	}, numMerges)
+}
+
+// TrainByteLevelBPEFromCounts trains byte-level merges on a word frequency
+// table as returned by CountWords with byteLevel set.
+func TrainByteLevelBPEFromCounts(counts map[string]int, numMerges int) []Pair {
+	return trainMerges(counts, byteSymbols, numMerges)
+}
+
+// trainWord is a distinct training word, its frequency and its symbols
+// after the merges learned so far.
+type trainWord struct {
+	symbols []string
+	count   int
+}
+
+// pairCount is a heap entry. Entries are not updated in place: every change
+// of a pair's count pushes a new entry, and entries whose count no longer
+// matches the current one are skipped when popped.
+type pairCount struct {
+	pair  Pair
+	count int
+}
+
+// pairHeap pops the most frequent pair first; ties go to the pair that
+// sorts first, so training is deterministic.
+type pairHeap []pairCount
+
+func (h pairHeap) Len() int { return len(h) }
+func (h pairHeap) Less(i, j int) bool {
+	if h[i].count != h[j].count {
+		return h[i].count > h[j].count
+	}
+	if h[i].pair.First != h[j].pair.First {
+		return h[i].pair.First < h[j].pair.First
+	}
+	return h[i].pair.Second < h[j].pair.Second
+}
+func (h pairHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *pairHeap) Push(x interface{}) { *h = append(*h, x.(pairCount)) }
+func (h *pairHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// trainMerges learns up to numMerges merges. Pair counts are kept for the
+// distinct words only and updated incrementally: a merge touches just the
+// words that contain the merged pair, found through an index from pair to
+// words, and the next best pair comes from a priority queue.
+func trainMerges(counts map[string]int, split func(string) []string, numMerges int) []Pair {
+	keys := make([]string, 0, len(counts))
+	for w := range counts {
+		keys = append(keys, w)
+	}
+	sort.Strings(keys)
+
+	words := make([]trainWord, len(keys))
+	pairs := make(map[Pair]int)
+	where := make(map[Pair][]int)
+	index := func(p Pair, i int) {
+		if ws := where[p]; len(ws) == 0 || ws[len(ws)-1] != i {
+			where[p] = append(ws, i)
+		}
+	}
+	for i, k := range keys {
+		words[i] = trainWord{symbols: split(k), count: counts[k]}
+		s := words[i].symbols
+		for j := 0; j+1 < len(s); j++ {
+			p := Pair{s[j], s[j+1]}
+			pairs[p] += counts[k]
+			index(p, i)
+		}
+	}
+	h := make(pairHeap, 0, len(pairs))
+	for p, c := range pairs {
+		h = append(h, pairCount{p, c})
+	}
+	heap.Init(&h)
+
+	merges := []Pair{}
+	for len(merges) < numMerges && h.Len() > 0 {
+		top := heap.Pop(&h).(pairCount)
+		if top.count <= 0 || pairs[top.pair] != top.count {
+			continue
+		}
+		merges = append(merges, top.pair)
+
+		changed := make(map[Pair]bool)
+		for _, i := range where[top.pair] {
+			w := &words[i]
+			merged := mergeSymbols(w.symbols, top.pair)
+			if len(merged) == len(w.symbols) {
+				continue
+			}
+			for j := 0; j+1 < len(w.symbols); j++ {
+				p := Pair{w.symbols[j], w.symbols[j+1]}
+				pairs[p] -= w.count
+				changed[p] = true
+			}
+			for j := 0; j+1 < len(merged); j++ {
+				p := Pair{merged[j], merged[j+1]}
+				pairs[p] += w.count
+				changed[p] = true
+				index(p, i)
+			}
+			w.symbols = merged
+		}
+		delete(where, top.pair)
+		for p := range changed {
+			if c := pairs[p]; c > 0 {
+				heap.Push(&h, pairCount{p, c})
+			} else {
+				delete(pairs, p)
+				delete(where, p)
+			}
+		}
+	}
+	return merges
+}
@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// naiveMerges is the training loop trainMerges replaced: recount every pair
+// of every word occurrence, merge the most frequent one everywhere, repeat.
+// Ties go to the pair that sorts first, as in pairHeap.
+func naiveMerges(tokens [][]string, numMerges int) []Pair {
+	t := NewTokenizer(nil, "")
+	merges := []Pair{}
+	for len(merges) < numMerges {
+		freqs := t.GetPairFrequencies(tokens)
+		if len(freqs) == 0 {
+			break
+		}
+		var best Pair
+		bestFreq := 0
+		for p, f := range freqs {
+			if f > bestFreq || (f == bestFreq && (p.First < best.First || (p.First == best.First && p.Second < best.Second))) {
+				best, bestFreq = p, f
+			}
+		}
+		tokens = t.MergePair(tokens, best)
+		merges = append(merges, best)
+	}
+	return merges
+}
+
+const trainCorpus = `low lower lowest newer newest wider widest
+the lowest newer widest low low low newer newer
+aaaa aaa aa banana bandana ananas, "quoted" text: naïve café déjà vu
+tabs	and  spaces 12345 2024-06-01 don't we'll`
+
+func TestTrainMatchesNaive(t *testing.T) {
+	corpus := strings.Repeat(trainCorpus+"\n", 3) + "unique words appear once"
+	for _, numMerges := range []int{1, 10, 50, 500} {
+		merges, err := TrainBPE(corpus, numMerges, "</w>")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := naiveMerges(NewTokenizer(nil, "</w>").InitialTokens(corpus), numMerges)
+		if !reflect.DeepEqual(merges, want) {
+			t.Errorf("TrainBPE with %d merges = %v, want %v", numMerges, merges, want)
+		}
+
+		byteMerges, err := TrainByteLevelBPE(corpus, numMerges)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var tokens [][]string
+		for _, word := range PreTokenize(corpus) {
+			tokens = append(tokens, byteSymbols(word))
+		}
+		if want := naiveMerges(tokens, numMerges); !reflect.DeepEqual(byteMerges, want) {
+			t.Errorf("TrainByteLevelBPE with %d merges = %v, want %v", numMerges, byteMerges, want)
+		}
+	}
+}
+
+func TestTrainDeterministic(t *testing.T) {
+	corpus := strings.Repeat(trainCorpus+"\n", 2)
+	first, _ := TrainByteLevelBPE(corpus, 200)
+	for i := 0; i < 10; i++ {
+		// Word counts come from a map, so each run sees them in another order.
+		if again, _ := TrainByteLevelBPE(corpus, 200); !reflect.DeepEqual(again, first) {
+			t.Fatalf("run %d learned different merges", i)
+		}
+	}
+
+	// Every pair occurs once; ties go to the pair that sorts first, and
+	// the space symbol Ġ sorts after letters.
+	merges, _ := TrainByteLevelBPE("xy ab cd", 3)
+	if want := []Pair{{"a", "b"}, {"c", "d"}, {"x", "y"}}; !reflect.DeepEqual(merges, want) {
+		t.Errorf("TrainByteLevelBPE on ties = %v, want %v", merges, want)
+	}
+	// Training stops when no pairs are left.
+	if merges, _ := TrainByteLevelBPE("abab", 10); !reflect.DeepEqual(merges, []Pair{{"a", "b"}, {"ab", "ab"}}) {
+		t.Errorf("TrainByteLevelBPE ran out of pairs with %v", merges)
+	}
+	if _, err := TrainBPE("", 10, ""); err == nil {
+		t.Error("TrainBPE accepted an empty corpus")
+	}
+}
@@ -0,0 +1,361 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// MetaSpace replaces the space before each word in the pieces of a Unigram
+// tokenizer, as in SentencePiece.
+const MetaSpace = "▁"
+
+// Unigram training parameters, following the SentencePiece defaults.
+const (
+	maxPieceLength   = 16
+	seedSizeFactor   = 10
+	shrinkingFactor  = 0.75
+	emIterations     = 2
+	minExpectedCount = 0.5
+)
+
+// unigramModel segments words into the pieces of a Unigram language model.
+type unigramModel struct {
+	scores map[string]float64
+	// maxLen is the length in runes of the longest piece.
+	maxLen int
+	// unk is the score of a character that is not a piece.
+	unk float64
+}
+
+func newUnigramModel(scores map[string]float64) *unigramModel {
+	m := &unigramModel{scores: scores, maxLen: 1}
+	min := 0.0
+	for p, s := range scores {
+		if n := utf8.RuneCountInString(p); n > m.maxLen {
+			m.maxLen = n
+		}
+		if s < min {
+			min = s
+		}
+	}
+	m.unk = min - 10
+	return m
+}
+
+// segment returns the most likely segmentation of word (Viterbi). Characters
+// that are not pieces become tokens of their own. The piece exclude is not
+// used; pruning uses it to find the next best segmentation of a piece.
+func (m *unigramModel) segment(word, exclude string) ([]string, float64) {
+	runes := []rune(word)
+	n := len(runes)
+	best := make([]float64, n+1)
+	from := make([]int, n+1)
+	for i := 1; i <= n; i++ {
+		best[i] = math.Inf(-1)
+	}
+	for i := 0; i < n; i++ {
+		if math.IsInf(best[i], -1) {
+			continue
+		}
+		for j := i + 1; j <= n && j-i <= m.maxLen; j++ {
+			piece := string(runes[i:j])
+			s, ok := m.scores[piece]
+			if !ok || piece == exclude {
+				if j > i+1 {
+					continue
+				}
+				s = m.unk
+			}
+			if best[i]+s > best[j] {
+				best[j], from[j] = best[i]+s, i
+			}
+		}
+	}
+	var pieces []string
+	for j := n; j > 0; j = from[j] {
+		pieces = append(pieces, string(runes[from[j]:j]))
+	}
+	for i, j := 0, len(pieces)-1; i < j; i, j = i+1, j-1 {
+		pieces[i], pieces[j] = pieces[j], pieces[i]
+	}
+	return pieces, best[n]
+}
+
+// expect adds count times the expected number of occurrences of each piece
+// in word to counts (forward-backward over all segmentations) and returns
+// the log likelihood of word.
+func (m *unigramModel) expect(word string, count int, counts map[string]float64) float64 {
+	runes := []rune(word)
+	n := len(runes)
+	type edge struct {
+		start int
+		piece string
+		score float64
+	}
+	ends := make([][]edge, n+1)
+	for i := 0; i < n; i++ {
+		for j := i + 1; j <= n && j-i <= m.maxLen; j++ {
+			piece := string(runes[i:j])
+			if s, ok := m.scores[piece]; ok {
+				ends[j] = append(ends[j], edge{i, piece, s})
+			} else if j == i+1 {
+				ends[j] = append(ends[j], edge{i, piece, m.unk})
+			}
+		}
+	}
+	alpha := make([]float64, n+1)
+	for j := 1; j <= n; j++ {
+		alpha[j] = math.Inf(-1)
+		for _, e := range ends[j] {
+			alpha[j] = logAdd(alpha[j], alpha[e.start]+e.score)
+		}
+	}
+	beta := make([]float64, n+1)
+	for i := 0; i < n; i++ {
+		beta[i] = math.Inf(-1)
+	}
+	for j := n; j > 0; j-- {
+		for _, e := range ends[j] {
+			beta[e.start] = logAdd(beta[e.start], beta[j]+e.score)
+		}
+	}
+	z := alpha[n]
+	for j := 1; j <= n; j++ {
+		for _, e := range ends[j] {
+			if _, ok := m.scores[e.piece]; ok {
+				counts[e.piece] += float64(count) * math.Exp(alpha[e.start]+e.score+beta[j]-z)
+			}
+		}
+	}
+	return z
+}
+
+func logAdd(a, b float64) float64 {
+	if math.IsInf(a, -1) {
+		return b
+	}
+	if math.IsInf(b, -1) {
+		return a
+	}
+	if a < b {
+		a, b = b, a
+	}
+	return a + math.Log1p(math.Exp(b-a))
+}
+
+// unigramWords splits text into the words of a Unigram tokenizer: each
+// whitespace-separated word with MetaSpace in front.
+func unigramWords(text string) []string {
+	words := strings.Fields(text)
+	for i, w := range words {
+		words[i] = MetaSpace + w
+	}
+	return words
+}
+
+// TrainUnigram trains a Unigram tokenizer with up to vocabSize IDs
+// (including the special tokens) on the given corpus.
+func TrainUnigram(corpus string, vocabSize int) (*Tokenizer, error) {
+	if corpus == "" || vocabSize <= 0 {
+		return nil, errors.New("invalid corpus or vocabSize")
+	}
+	return TrainUnigramFromCounts(CountWords(corpus, false), vocabSize)
+}
+
+// TrainUnigramFromCounts trains a Unigram tokenizer on a word frequency
+// table as returned by CountWords. It starts from the most frequent
+// substrings of the words and alternates EM re-estimation of the piece
+// probabilities with pruning of the pieces whose removal costs the least
+// likelihood, until vocabSize IDs are left. Single characters are never
+// pruned, so the vocabulary can end up larger than vocabSize if the corpus
+// has more distinct characters. vocabSize must leave room for at least
+// one piece after the special tokens.
+func TrainUnigramFromCounts(counts map[string]int, vocabSize int) (*Tokenizer, error) {
+	t := NewTokenizer(nil, "")
+	if vocabSize <= len(t.SpecialTokens) {
+		return nil, fmt.Errorf("vocab size %d must exceed the %d special tokens", vocabSize, len(t.SpecialTokens))
+	}
+	words := make([]unigramWord, 0, len(counts))
+	for w, c := range counts {
+		words = append(words, unigramWord{MetaSpace + w, c})
+	}
+	// Sorting makes the floating point sums, and so training, deterministic.
+	sort.Slice(words, func(i, j int) bool { return words[i].text < words[j].text })
+	target := vocabSize - len(t.SpecialTokens)
+
+	scores := seedPieces(words, target*seedSizeFactor)
+	for {
+		for i := 0; i < emIterations; i++ {
+			scores = reestimate(words, scores)
+		}
+		if len(scores) <= target {
+			break
+		}
+		pruned := prunePieces(words, scores, target)
+		if len(pruned) == len(scores) {
+			break
+		}
+		scores = pruned
+	}
+
+	t.Scores = scores
+	t.BuildVocab(nil)
+	return t, nil
+}
+
+// unigramWord is a distinct training word and its frequency.
+type unigramWord struct {
+	text  string
+	count int
+}
+
+// seedPieces returns the initial pieces: every character, plus the size
+// most frequent substrings weighted by their length.
+func seedPieces(words []unigramWord, size int) map[string]float64 {
+	freq := make(map[string]int)
+	chars := make(map[string]int)
+	for _, w := range words {
+		runes, c := []rune(w.text), w.count
+		for i := range runes {
+			chars[string(runes[i])] += c
+			for j := i + 2; j <= len(runes) && j-i <= maxPieceLength; j++ {
+				freq[string(runes[i:j])] += c
+			}
+		}
+	}
+	type seed struct {
+		piece string
+		score int
+	}
+	var seeds []seed
+	for p, c := range freq {
+		if c > 1 {
+			seeds = append(seeds, seed{p, c * utf8.RuneCountInString(p)})
+		}
+	}
+	sort.Slice(seeds, func(i, j int) bool {
+		if seeds[i].score != seeds[j].score {
+			return seeds[i].score > seeds[j].score
+		}
+		return seeds[i].piece < seeds[j].piece
+	})
+	if len(seeds) > size {
+		seeds = seeds[:size]
+	}
+
+	total := 0.0
+	for _, c := range chars {
+		total += float64(c)
+	}
+	for _, s := range seeds {
+		total += float64(freq[s.piece])
+	}
+	scores := make(map[string]float64, len(chars)+len(seeds))
+	for p, c := range chars {
+		scores[p] = math.Log(float64(c) / total)
+	}
+	for _, s := range seeds {
+		scores[s.piece] = math.Log(float64(freq[s.piece]) / total)
+	}
+	return scores
+}
+
+// reestimate runs one EM step: it computes the expected count of every
+// piece and returns the new log probabilities, dropping multi-character
+// pieces that are expected less than minExpectedCount times.
+func reestimate(words []unigramWord, scores map[string]float64) map[string]float64 {
+	m := newUnigramModel(scores)
+	expected := make(map[string]float64, len(scores))
+	for _, w := range words {
+		m.expect(w.text, w.count, expected)
+	}
+	pieces := make([]string, 0, len(scores))
+	for p := range scores {
+		pieces = append(pieces, p)
+	}
+	sort.Strings(pieces)
+	next := make(map[string]float64, len(scores))
+	total := 0.0
+	for _, p := range pieces {
+		c := expected[p]
+		if utf8.RuneCountInString(p) > 1 && c < minExpectedCount {
+			continue
+		}
+		if c < minExpectedCount {
+			c = minExpectedCount
+		}
+		next[p] = c
+		total += c
+	}
+	for p, c := range next {
+		next[p] = math.Log(c / total)
+	}
+	return next
+}
+
+// prunePieces drops the multi-character pieces whose removal lowers the
+// likelihood of the corpus least, keeping shrinkingFactor of them but no
+// fewer than target pieces in all. The loss of a piece is approximated by
+// replacing each of its uses in the best segmentations with the best
+// segmentation of the piece itself without it.
+func prunePieces(words []unigramWord, scores map[string]float64, target int) map[string]float64 {
+	m := newUnigramModel(scores)
+	used := make(map[string]int)
+	for _, w := range words {
+		pieces, _ := m.segment(w.text, "")
+		for _, p := range pieces {
+			used[p] += w.count
+		}
+	}
+
+	type candidate struct {
+		piece string
+		loss  float64
+	}
+	var candidates []candidate
+	chars := 0
+	for p, s := range scores {
+		if utf8.RuneCountInString(p) == 1 {
+			chars++
+			continue
+		}
+		loss := 0.0
+		if n := used[p]; n > 0 {
+			_, alt := m.segment(p, p)
+			loss = float64(n) * (s - alt)
+		}
+		candidates = append(candidates, candidate{p, loss})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].loss != candidates[j].loss {
+			return candidates[i].loss > candidates[j].loss
+		}
+		return candidates[i].piece < candidates[j].piece
+	})
+	keep := int(float64(len(scores)) * shrinkingFactor)
+	if keep < target {
+		keep = target
+	}
+	keep -= chars
+	if keep < 0 {
+		keep = 0
+	}
+	if keep > len(candidates) {
+		keep = len(candidates)
+	}
+
+	pruned := make(map[string]float64, chars+keep)
+	for p, s := range scores {
+		if utf8.RuneCountInString(p) == 1 {
+			pruned[p] = s
+		}
+	}
+	for _, c := range candidates[:keep] {
+		pruned[c.piece] = scores[c.piece]
+	}
+	return pruned
+}
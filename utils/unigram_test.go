@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+const unigramCorpus = `the quick brown fox jumps over the lazy dog
+the dog sleeps while the fox runs through the forest
+a quick brown dog and a lazy fox share the forest
+foxes and dogs are quick and lazy in turns`
+
+func TestUnigramRoundTrip(t *testing.T) {
+	tok, err := TrainUnigram(unigramCorpus, 60)
+	if err != nil {
+		t.Fatalf("TrainUnigram: %v", err)
+	}
+	for _, text := range []string{
+		"the quick brown fox",
+		"lazy dogs sleep in the forest",
+		"unseen words like zebra",
+		"  extra   whitespace\tis normalized ",
+	} {
+		ids, err := tok.Encode(text)
+		if err != nil {
+			t.Fatalf("Encode(%q): %v", text, err)
+		}
+		if n := tok.CountTokens(text); n != len(ids) {
+			t.Errorf("CountTokens(%q) = %d, Encode gave %d IDs", text, n, len(ids))
+		}
+		got, err := tok.Decode(ids)
+		if err != nil {
+			t.Fatalf("Decode(%q): %v", text, err)
+		}
+		if want := strings.Join(strings.Fields(text), " "); got != want {
+			t.Errorf("Decode(Encode(%q)) = %q, want %q", text, got, want)
+		}
+	}
+	// Special tokens in the text are only kept whole when asked for.
+	tok.MatchSpecial = true
+	text := EndOfTextToken + " after a special token"
+	ids, err := tok.Encode(text)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if eos, _ := tok.TokenID(EndOfTextToken); ids[0] != eos {
+		t.Errorf("Encode(%q) starts with %d, want EOS %d", text, ids[0], eos)
+	}
+	if got, _ := tok.Decode(ids); got != text {
+		t.Errorf("Decode(Encode(%q)) = %q", text, got)
+	}
+	tok.MatchSpecial = false
+
+	// Characters never seen in training decode as the unknown token.
+	ids, err = tok.Encode("fox: é")
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if got, _ := tok.Decode(ids); got != "fox"+UnknownToken+" "+UnknownToken {
+		t.Errorf("Decode(Encode(fox: é)) = %q", got)
+	}
+	// Frequent words become single pieces.
+	if got := tok.Tokenize("the"); len(got) != 1 {
+		t.Errorf("Tokenize(the) = %q, want one piece", got)
+	}
+}
+
+func TestTrainUnigramVocabTooSmall(t *testing.T) {
+	specials := len(DefaultSpecialTokens())
+	for _, size := range []int{1, 5, specials} {
+		if _, err := TrainUnigram(unigramCorpus, size); err == nil {
+			t.Errorf("TrainUnigram with vocab size %d succeeded", size)
+		}
+	}
+	if _, err := TrainUnigram(unigramCorpus, specials+1); err != nil {
+		t.Errorf("TrainUnigram with vocab size %d: %v", specials+1, err)
+	}
+}
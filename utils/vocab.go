@@ -0,0 +1,292 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Reserved special tokens. They always map to a single ID; with
+// Tokenizer.MatchSpecial they are also matched in the input before any
+// other processing and never split.
+const (
+	EndOfTextToken = "<|endoftext|>"
+	PadToken       = "<|pad|>"
+	UnknownToken   = "<|unk|>"
+	SystemToken    = "<|system|>"
+	UserToken      = "<|user|>"
+	AssistantToken = "<|assistant|>"
+)
+
+// DefaultSpecialTokens returns the special tokens new tokenizers reserve,
+// in the order of their IDs.
+func DefaultSpecialTokens() []string {
+	return []string{EndOfTextToken, PadToken, UnknownToken, SystemToken, UserToken, AssistantToken}
+}
+
+// BuildVocab assigns IDs to the special tokens, then to the base symbols,
+// then to the result of every merge in merge order. A Unigram tokenizer
+// instead gets the special tokens followed by its pieces from most to least
+// likely. For a byte-level
+// tokenizer the base symbols are all 256 bytes; otherwise they are the
+// printable ASCII characters, the given alphabet and every single character
+// and word boundary marker that occur in the merges. Tokens not in the
+// vocabulary encode as UnknownToken.
+func (t *Tokenizer) BuildVocab(alphabet []string) {
+	vocab := make(map[string]int)
+	add := func(tok string) {
+		if _, ok := vocab[tok]; !ok {
+			vocab[tok] = len(vocab)
+		}
+	}
+	for _, s := range t.SpecialTokens {
+		add(s)
+	}
+	if t.Scores != nil {
+		pieces := make([]string, 0, len(t.Scores))
+		for p := range t.Scores {
+			pieces = append(pieces, p)
+		}
+		sort.Slice(pieces, func(i, j int) bool {
+			if t.Scores[pieces[i]] != t.Scores[pieces[j]] {
+				return t.Scores[pieces[i]] > t.Scores[pieces[j]]
+			}
+			return pieces[i] < pieces[j]
+		})
+		for _, p := range pieces {
+			add(p)
+		}
+	} else if t.ByteLevel {
+		for b := 0; b < 256; b++ {
+			add(string(byteEncoder[b]))
+		}
+	} else {
+		base := append([]string(nil), alphabet...)
+		for r := '!'; r <= '~'; r++ {
+			base = append(base, string(r))
+		}
+		for _, m := range t.Merges {
+			for _, s := range []string{m.First, m.Second} {
+				if utf8.RuneCountInString(s) == 1 || s == t.WordBoundary {
+					base = append(base, s)
+				}
+			}
+		}
+		sort.Strings(base)
+		for _, s := range base {
+			add(s)
+		}
+	}
+	for _, m := range t.Merges {
+		add(m.First + m.Second)
+	}
+	t.mu.Lock()
+	t.Vocab, t.idTokens = vocab, nil
+	t.mu.Unlock()
+}
+
+// vocabulary returns the vocabulary and its inverse, building the
+// vocabulary from the merges on first use if none was set.
+func (t *Tokenizer) vocabulary() (map[string]int, []string) {
+	t.mu.Lock()
+	built := t.Vocab != nil
+	t.mu.Unlock()
+	if !built {
+		t.BuildVocab(nil)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.idTokens == nil {
+		max := -1
+		for _, id := range t.Vocab {
+			if id > max {
+				max = id
+			}
+		}
+		t.idTokens = make([]string, max+1)
+		for tok, id := range t.Vocab {
+			t.idTokens[id] = tok
+		}
+	}
+	return t.Vocab, t.idTokens
+}
+
+// VocabSize returns the number of IDs in the vocabulary.
+func (t *Tokenizer) VocabSize() int {
+	_, tokens := t.vocabulary()
+	return len(tokens)
+}
+
+// TokenID returns the ID of a token and whether it is in the vocabulary.
+func (t *Tokenizer) TokenID(token string) (int, bool) {
+	vocab, _ := t.vocabulary()
+	id, ok := vocab[token]
+	return id, ok
+}
+
+// Encode returns the token IDs of text. Tokens missing from the vocabulary
+// become the ID of UnknownToken, or an error if that is not reserved.
+func (t *Tokenizer) Encode(text string) ([]int, error) {
+	vocab, _ := t.vocabulary()
+	tokens := t.Tokenize(text)
+	ids := make([]int, 0, len(tokens))
+	for _, tok := range tokens {
+		id, ok := vocab[tok]
+		if !ok {
+			if id, ok = vocab[UnknownToken]; !ok {
+				return nil, fmt.Errorf("token %q is not in the vocabulary", tok)
+			}
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// Decode turns token IDs back into text. Special tokens are written out
+// verbatim; see Detokenize for how the other tokens are joined.
+func (t *Tokenizer) Decode(ids []int) (string, error) {
+	_, idTokens := t.vocabulary()
+	tokens := make([]string, len(ids))
+	for i, id := range ids {
+		if id < 0 || id >= len(idTokens) || idTokens[id] == "" {
+			return "", fmt.Errorf("token ID %d is not in the vocabulary", id)
+		}
+		tokens[i] = idTokens[id]
+	}
+	return t.Detokenize(tokens), nil
+}
+
+func (t *Tokenizer) isSpecial(tok string) bool {
+	for _, s := range t.SpecialTokens {
+		if s == tok {
+			return true
+		}
+	}
+	return false
+}
+
+// textSegment is a piece of input that is either a special token or plain text.
+type textSegment struct {
+	text    string
+	special bool
+}
+
+// splitSpecial cuts text at every occurrence of a special token, preferring
+// the longest special token where several match. Without MatchSpecial the
+// text is returned whole.
+func (t *Tokenizer) splitSpecial(text string) []textSegment {
+	if !t.MatchSpecial || len(t.SpecialTokens) == 0 {
+		return []textSegment{{text: text}}
+	}
+	var starts []byte
+	for _, s := range t.SpecialTokens {
+		if s != "" {
+			starts = append(starts, s[0])
+		}
+	}
+	var segments []textSegment
+	start := 0
+	for i := 0; i < len(text); {
+		match := ""
+		if bytes.IndexByte(starts, text[i]) >= 0 {
+			for _, s := range t.SpecialTokens {
+				if len(s) > len(match) && strings.HasPrefix(text[i:], s) {
+					match = s
+				}
+			}
+		}
+		if match == "" {
+			i++
+			continue
+		}
+		if start < i {
+			segments = append(segments, textSegment{text: text[start:i]})
+		}
+		segments = append(segments, textSegment{text: match, special: true})
+		i += len(match)
+		start = i
+	}
+	if start < len(text) {
+		segments = append(segments, textSegment{text: text[start:]})
+	}
+	return segments
+}
+
+// tokenizerFile is the JSON layout written by SaveTokenizer.
+type tokenizerFile struct {
+	Version       int                `json:"version"`
+	Model         string             `json:"model,omitempty"`
+	ByteLevel     bool               `json:"byte_level"`
+	WordBoundary  string             `json:"word_boundary,omitempty"`
+	SpecialTokens []string           `json:"special_tokens"`
+	Vocab         map[string]int     `json:"vocab"`
+	Merges        []string           `json:"merges"`
+	Scores        map[string]float64 `json:"scores,omitempty"`
+}
+
+// SaveTokenizer writes the vocabulary, special tokens and merges (or piece
+// scores, with model "unigram") of t to a JSON file that LoadTokenizer
+// reads back.
+func SaveTokenizer(filename string, t *Tokenizer) error {
+	vocab, _ := t.vocabulary()
+	f := tokenizerFile{
+		Version:       1,
+		ByteLevel:     t.ByteLevel,
+		WordBoundary:  t.WordBoundary,
+		SpecialTokens: t.SpecialTokens,
+		Vocab:         vocab,
+		Merges:        make([]string, len(t.Merges)),
+	}
+	if t.Scores != nil {
+		f.Model, f.Scores = "unigram", t.Scores
+	}
+	for i, m := range t.Merges {
+		f.Merges[i] = m.First + " " + m.Second
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// LoadTokenizer reads a tokenizer written by SaveTokenizer.
+func LoadTokenizer(filename string) (*Tokenizer, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var f tokenizerFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse tokenizer file: %v", err)
+	}
+	if f.Version != 1 {
+		return nil, fmt.Errorf("unsupported tokenizer file version %d", f.Version)
+	}
+	if f.Model != "" && f.Model != "bpe" && f.Model != "unigram" {
+		return nil, fmt.Errorf("unsupported tokenizer model %q", f.Model)
+	}
+	merges := make([]Pair, 0, len(f.Merges))
+	for _, line := range f.Merges {
+		parts := strings.Split(line, " ")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid merge %q", line)
+		}
+		merges = append(merges, Pair{parts[0], parts[1]})
+	}
+	t := NewTokenizer(merges, f.WordBoundary)
+	t.ByteLevel = f.ByteLevel
+	t.SpecialTokens = f.SpecialTokens
+	t.Vocab = f.Vocab
+	if f.Model == "unigram" {
+		t.Scores = f.Scores
+		if t.Scores == nil {
+			t.Scores = make(map[string]float64)
+		}
+	}
+	return t, nil
+}
@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const vocabCorpus = `the quick brown fox jumps over the lazy dog
+the dog sleeps while the fox runs through the forest
+a quick brown dog and a lazy fox share the forest`
+
+// vocabTokenizers trains one tokenizer of every model on vocabCorpus.
+func vocabTokenizers(t *testing.T) map[string]*Tokenizer {
+	t.Helper()
+	bpeMerges, err := TrainBPE(vocabCorpus, 40, "</w>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	byteMerges, err := TrainByteLevelBPE(vocabCorpus, 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unigram, err := TrainUnigram(vocabCorpus, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bpe := NewTokenizer(bpeMerges, "</w>")
+	bpe.BuildVocab(nil)
+	bytebpe := NewByteLevelTokenizer(byteMerges)
+	bytebpe.BuildVocab(nil)
+	return map[string]*Tokenizer{"bpe": bpe, "bytebpe": bytebpe, "unigram": unigram}
+}
+
+func TestSaveLoadTokenizer(t *testing.T) {
+	texts := []string{
+		"the quick brown fox",
+		"a lazy dog sleeps in the forest",
+		"unseen words like zebra",
+	}
+	for name, tok := range vocabTokenizers(t) {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tokenizer.json")
+			if err := SaveTokenizer(path, tok); err != nil {
+				t.Fatalf("SaveTokenizer: %v", err)
+			}
+			loaded, err := LoadTokenizer(path)
+			if err != nil {
+				t.Fatalf("LoadTokenizer: %v", err)
+			}
+			if !reflect.DeepEqual(loaded.Vocab, tok.Vocab) {
+				t.Errorf("vocabulary changed in the round trip: %d tokens, was %d", len(loaded.Vocab), len(tok.Vocab))
+			}
+			if loaded.VocabSize() != tok.VocabSize() {
+				t.Errorf("VocabSize = %d, was %d", loaded.VocabSize(), tok.VocabSize())
+			}
+			if !reflect.DeepEqual(loaded.SpecialTokens, tok.SpecialTokens) {
+				t.Errorf("special tokens = %q, were %q", loaded.SpecialTokens, tok.SpecialTokens)
+			}
+			if len(loaded.Merges)+len(tok.Merges) > 0 && !reflect.DeepEqual(loaded.Merges, tok.Merges) {
+				t.Errorf("merges changed in the round trip")
+			}
+			if !reflect.DeepEqual(loaded.Scores, tok.Scores) {
+				t.Errorf("scores changed in the round trip")
+			}
+			if loaded.ByteLevel != tok.ByteLevel || loaded.WordBoundary != tok.WordBoundary {
+				t.Errorf("byte level %v, word boundary %q; were %v, %q", loaded.ByteLevel, loaded.WordBoundary, tok.ByteLevel, tok.WordBoundary)
+			}
+			for _, text := range texts {
+				want, err := tok.Encode(text)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got, err := loaded.Encode(text); err != nil || !reflect.DeepEqual(got, want) {
+					t.Errorf("loaded Encode(%q) = %v, %v; want %v", text, got, err, want)
+				}
+			}
+
+			// Saving the loaded tokenizer again gives the same vocabulary.
+			again := filepath.Join(t.TempDir(), "again.json")
+			if err := SaveTokenizer(again, loaded); err != nil {
+				t.Fatal(err)
+			}
+			if reloaded, err := LoadTokenizer(again); err != nil || !reflect.DeepEqual(reloaded.Vocab, tok.Vocab) {
+				t.Errorf("second round trip changed the vocabulary: %v", err)
+			}
+		})
+	}
+}
+
+func TestSpecialTokenIDs(t *testing.T) {
+	for name, tok := range vocabTokenizers(t) {
+		// Special tokens take the first IDs, in order, whatever was trained.
+		for want, special := range DefaultSpecialTokens() {
+			if id, ok := tok.TokenID(special); !ok || id != want {
+				t.Errorf("%s: %s has ID %d (%v), want %d", name, special, id, ok, want)
+			}
+		}
+
+		tok.MatchSpecial = true
+		text := "the fox" + EndOfTextToken + "the dog " + PadToken + PadToken + " " + AssistantToken
+		ids, err := tok.Encode(text)
+		if err != nil {
+			t.Fatalf("%s: Encode: %v", name, err)
+		}
+		var specials []int
+		for _, id := range ids {
+			if id < len(DefaultSpecialTokens()) {
+				specials = append(specials, id)
+			}
+		}
+		eos, _ := tok.TokenID(EndOfTextToken)
+		pad, _ := tok.TokenID(PadToken)
+		assistant, _ := tok.TokenID(AssistantToken)
+		if want := []int{eos, pad, pad, assistant}; !reflect.DeepEqual(specials, want) {
+			t.Errorf("%s: special IDs in Encode(%q) = %v, want %v", name, text, specials, want)
+		}
+		if got, err := tok.Decode(ids); err != nil || (tok.ByteLevel && got != text) {
+			t.Errorf("%s: Decode(Encode(%q)) = %q, %v", name, text, got, err)
+		}
+		tok.MatchSpecial = false
+	}
+}
+
+// A special token that is a prefix of another never splits the longer one.
+func TestSpecialTokensLongestMatch(t *testing.T) {
+	tok := NewByteLevelTokenizer(nil)
+	tok.SpecialTokens = []string{"<|end|>", "<|endoftext|>", "<|end"}
+	tok.MatchSpecial = true
+	tok.BuildVocab(nil)
+	text := "a<|endoftext|>b<|end|>c<|end"
+	ids, err := tok.Encode(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := tok.Tokenize(text)
+	want := []string{"a", "<|endoftext|>", "b", "<|end|>", "c", "<|end"}
+	if !reflect.DeepEqual(tokens, want) {
+		t.Errorf("Tokenize(%q) = %q, want %q", text, tokens, want)
+	}
+	if want := []int{3 + 'a', 1, 3 + 'b', 0, 3 + 'c', 2}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("Encode(%q) = %v, want %v", text, ids, want)
+	}
+	if got, _ := tok.Decode(ids); got != text {
+		t.Errorf("Decode(Encode(%q)) = %q", text, got)
+	}
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	toks := vocabTokenizers(t)
+	for _, text := range []string{
+		"the quick brown fox jumps over the lazy dog",
+		"a forest",
+		"",
+	} {
+		for name, tok := range toks {
+			ids, err := tok.Encode(text)
+			if err != nil {
+				t.Fatalf("%s: Encode(%q): %v", name, text, err)
+			}
+			if got, err := tok.Decode(ids); err != nil || got != text {
+				t.Errorf("%s: Decode(Encode(%q)) = %q, %v", name, text, got, err)
+			}
+		}
+	}
+	// Byte-level tokenizers keep any text exactly, seen in training or not.
+	for _, text := range []string{
+		"  leading and trailing spaces  ",
+		"tabs\tand\nnewlines\r\n",
+		"Ünïcödé, emoji 🦊, CJK 日本語 and <|endoftext|> as text",
+	} {
+		ids, err := toks["bytebpe"].Encode(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := toks["bytebpe"].Decode(ids); err != nil || got != text {
+			t.Errorf("bytebpe: Decode(Encode(%q)) = %q, %v", text, got, err)
+		}
+	}
+	if _, err := toks["bpe"].Decode([]int{-1}); err == nil {
+		t.Error("Decode accepted an ID outside the vocabulary")
+	}
+}